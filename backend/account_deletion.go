@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// deletedUserName replaces the name of an anonymized user everywhere it was stored
+const deletedUserName = "Deleted user"
+
+// accountDeletionInterval is how often the worker looks for accounts whose grace period has ended
+const accountDeletionInterval = time.Minute
+
+// InitAccountDeletion starts the worker that deletes accounts once their grace period ends
+func InitAccountDeletion() {
+	startBackgroundJob("account_deletion", accountDeletionInterval, deleteDueAccounts)
+}
+
+// DELETE /user - Schedule deletion of the caller's account after ACCOUNT_DELETION_GRACE
+func DeleteCurrentUser(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+
+	// Asking again keeps the original date
+	if user.DeletionScheduledAt == nil {
+		scheduledAt := time.Now().Add(config.AccountDeletionGrace)
+		err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Updates(map[string]interface{}{"deletion_scheduled_at": scheduledAt, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, auditEntry{Action: "user_deletion_scheduled", TargetType: "user", TargetID: user.ID, After: gin.H{"deletion_scheduled_at": scheduledAt}})
+		})
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "error.delete_user_failed")
+			return
+		}
+		user.DeletionScheduledAt = &scheduledAt
+		accountDeletions.WithLabelValues("scheduled").Inc()
+	}
+
+	c.JSON(http.StatusAccepted, AccountDeletionResponse{
+		Message:             tr(c, "message.account_deletion_scheduled", MessageArgs{"date": user.DeletionScheduledAt.Format("2006-01-02 15:04 MST")}),
+		DeletionScheduledAt: *user.DeletionScheduledAt,
+	})
+}
+
+// POST /user/restore - Cancel a scheduled account deletion during the grace period
+func RestoreCurrentUser(c *gin.Context) {
+	user, err := currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+	if user.DeletionScheduledAt == nil {
+		respondError(c, http.StatusConflict, "error.no_deletion_scheduled")
+		return
+	}
+
+	before := *user.DeletionScheduledAt
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{"deletion_scheduled_at": nil, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "user_deletion_cancelled", TargetType: "user", TargetID: user.ID, Before: gin.H{"deletion_scheduled_at": before}})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.update_user_failed")
+		return
+	}
+	user.DeletionScheduledAt = nil
+	accountDeletions.WithLabelValues("restored").Inc()
+
+	c.JSON(http.StatusOK, user)
+}
+
+// deleteDueAccounts deletes every account whose grace period has ended, one transaction each
+func deleteDueAccounts(ctx context.Context) {
+	var users []User
+	if err := DB.WithContext(ctx).Where("deletion_scheduled_at <= ? AND deleted_at IS NULL", time.Now()).Order("id").Find(&users).Error; err != nil {
+		slog.ErrorContext(ctx, "failed to find accounts due for deletion", "error", err)
+		return
+	}
+
+	for _, user := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := deleteAccount(ctx, user); err != nil {
+			slog.ErrorContext(ctx, "account deletion failed", "user_id", user.ID, "error", err)
+			accountDeletions.WithLabelValues("failed").Inc()
+			continue
+		}
+		slog.InfoContext(ctx, "account deleted", "user_id", user.ID)
+		accountDeletions.WithLabelValues("completed").Inc()
+	}
+}
+
+// deleteAccount cancels the rides user leads, takes them out of the upcoming
+// rides they joined, drops their open requests and then anonymizes what is
+// left: the user row, past participations and closed requests are kept so
+// history still resolves, but without name, contact details or Firebase UID
+func deleteAccount(ctx context.Context, user User) error {
+	tx := DB.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.Rollback()
+
+	// Re-check inside the transaction in case the user restored the account meanwhile
+	err := tx.First(&user, "id = ? AND deletion_scheduled_at <= ? AND deleted_at IS NULL", user.ID, time.Now()).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	uid := user.FirebaseUID
+	anonUID := fmt.Sprintf("deleted-%d", user.ID)
+	today := time.Now().Format("2006-01-02")
+	payload := NotificationPayload{ActorUserID: user.ID, ActorName: deletedUserName}
+
+	// 1. Cancel upcoming rides they lead and notify the participants, as DeleteRide does.
+	// Past rides are left for the expired ride cleanup.
+	var rides []Ride
+	if err := tx.Where("leader_id = ? AND date >= ?", user.ID, today).Find(&rides).Error; err != nil {
+		return fmt.Errorf("error finding rides: %v", err)
+	}
+	for _, ride := range rides {
+		var participants []Participant
+		if err := tx.Where("ride_id = ?", ride.ID).Find(&participants).Error; err != nil {
+			return fmt.Errorf("error finding participants of ride %d: %v", ride.ID, err)
+		}
+		var requests []Request
+		if err := tx.Where("ride_id = ?", ride.ID).Find(&requests).Error; err != nil {
+			return fmt.Errorf("error finding requests of ride %d: %v", ride.ID, err)
+		}
+		if err := tx.Where("ride_id = ?", ride.ID).Delete(&Notification{}).Error; err != nil {
+			return fmt.Errorf("error deleting notifications of ride %d: %v", ride.ID, err)
+		}
+		if err := tx.Where("ride_id = ?", ride.ID).Delete(&Participant{}).Error; err != nil {
+			return fmt.Errorf("error deleting participants of ride %d: %v", ride.ID, err)
+		}
+		if err := tx.Where("ride_id = ?", ride.ID).Delete(&Request{}).Error; err != nil {
+			return fmt.Errorf("error deleting requests of ride %d: %v", ride.ID, err)
+		}
+		if err := tx.Where("ride_id = ?", ride.ID).Delete(&LeadershipTransfer{}).Error; err != nil {
+			return fmt.Errorf("error deleting leadership transfers of ride %d: %v", ride.ID, err)
+		}
+		if err := tx.Delete(&ride).Error; err != nil {
+			return fmt.Errorf("error deleting ride %d: %v", ride.ID, err)
+		}
+
+		if err := recordAudit(tx, nil, auditEntry{
+			Action:     "ride_deleted",
+			TargetType: "ride",
+			TargetID:   ride.ID,
+			RideID:     ride.ID,
+			SubjectUID: uid,
+			Before: gin.H{
+				"ride":         ride,
+				"participants": participantSnapshots(participants),
+				"requests":     requestSnapshots(requests),
+			},
+		}); err != nil {
+			return fmt.Errorf("error auditing deletion of ride %d: %v", ride.ID, err)
+		}
+
+		cancelled := payload
+		cancelled.Ride, cancelled.Reason = newRideSnapshot(ride), reasonLeaderDeletedAccount
+		for _, participant := range participants {
+			if err := createNotification(tx, participant.UserID, "ride_cancelled", ride.ID, cancelled); err != nil {
+				slog.ErrorContext(ctx, "failed to create notification", "recipient_uid", participant.UserID, "error", err)
+			}
+		}
+		cancellations.WithLabelValues("ride").Inc()
+	}
+
+	// 2. Leave the upcoming rides they joined, notifying the leaders as
+	// CancelRideParticipation does. Past participations are anonymized below.
+	var participations []Participant
+	if err := tx.Joins("JOIN rides ON rides.id = participants.ride_id").
+		Where("participants.user_id = ? AND rides.date >= ?", uid, today).Find(&participations).Error; err != nil {
+		return fmt.Errorf("error finding participations: %v", err)
+	}
+	for _, participant := range participations {
+		if err := tx.Delete(&participant).Error; err != nil {
+			return fmt.Errorf("error leaving ride %d: %v", participant.RideID, err)
+		}
+
+		var ride Ride
+		if err := tx.First(&ride, "id = ?", participant.RideID).Error; err != nil {
+			continue // Ride already gone, nothing to update
+		}
+		if err := tx.Model(&ride).Update("seats_filled", gorm.Expr("seats_filled - 1")).Error; err != nil {
+			return fmt.Errorf("error updating seats of ride %d: %v", ride.ID, err)
+		}
+
+		if err := recordAudit(tx, nil, auditEntry{Action: "participant_left", TargetType: "participant", TargetID: participant.ID, RideID: ride.ID, SubjectUID: uid, Before: participantSnapshot(participant)}); err != nil {
+			return fmt.Errorf("error auditing leaving ride %d: %v", ride.ID, err)
+		}
+
+		var leader User
+		if err := tx.First(&leader, "id = ?", ride.LeaderID).Error; err != nil {
+			continue
+		}
+		payload.Ride = newRideSnapshot(ride)
+		if err := createNotification(tx, leader.FirebaseUID, "participant_cancelled", ride.ID, payload); err != nil {
+			slog.ErrorContext(ctx, "failed to create notification", "recipient_uid", leader.FirebaseUID, "error", err)
+		}
+		cancellations.WithLabelValues("participation").Inc()
+	}
+
+	// 3. Drop pending and approved requests. Rejected and revoked ones stay as
+	// ride history, as do past participations; both follow the new Firebase
+	// UID in step 5 through their foreign keys' ON UPDATE CASCADE.
+	if err := tx.Where("user_id = ? AND status IN ?", uid, []string{"pending", "approved"}).Delete(&Request{}).Error; err != nil {
+		return fmt.Errorf("error deleting requests: %v", err)
+	}
+
+	// 4. Delete what only they could see
+	if err := tx.Where("user_id = ?", uid).Delete(&Notification{}).Error; err != nil {
+		return fmt.Errorf("error deleting notifications: %v", err)
+	}
+	if err := tx.Where("user_uid = ?", uid).Delete(&DataExport{}).Error; err != nil {
+		return fmt.Errorf("error deleting data exports: %v", err)
+	}
+	if err := tx.Where("user_uid = ?", uid).Delete(&PhoneVerification{}).Error; err != nil {
+		return fmt.Errorf("error deleting phone verifications: %v", err)
+	}
+	if err := tx.Where("user_uid = ?", uid).Delete(&IdempotencyRecord{}).Error; err != nil {
+		return fmt.Errorf("error deleting idempotency keys: %v", err)
+	}
+	if err := tx.Where("user_uid = ?", uid).Delete(&Membership{}).Error; err != nil {
+		return fmt.Errorf("error deleting memberships: %v", err)
+	}
+	if err := tx.Where("to_user_uid = ? AND status = ?", uid, "pending").Delete(&LeadershipTransfer{}).Error; err != nil {
+		return fmt.Errorf("error deleting leadership offers: %v", err)
+	}
+
+	// Looked up in the audit log in step 6, taken before the row is overwritten
+	email := user.Email
+
+	// 5. Anonymize the user row and remove the Firebase UID mapping
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"name":                  deletedUserName,
+		"email":                 anonUID + "@deleted.invalid",
+		"phone":                 "",
+		"phone_verified_at":     nil,
+		"gender":                "",
+		"firebase_uid":          anonUID,
+		"is_admin":              false,
+		"deletion_scheduled_at": nil,
+		"deleted_at":            time.Now(),
+		"updated_at":            time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("error anonymizing user: %v", err)
+	}
+	if err := recordAudit(tx, nil, auditEntry{Action: "user_deleted", TargetType: "user", TargetID: user.ID, SubjectUID: anonUID}); err != nil {
+		return fmt.Errorf("error auditing deletion: %v", err)
+	}
+
+	// 6. Scrub their name and contact details from other users' notifications and the audit log
+	if err := anonymizeNotifications(tx, user.ID); err != nil {
+		return fmt.Errorf("error anonymizing notifications: %v", err)
+	}
+	if err := anonymizeAuditEvents(tx, user.ID, uid, anonUID, email); err != nil {
+		return fmt.Errorf("error anonymizing audit events: %v", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	// Their rides were deleted or lost a passenger, and their memberships are gone
+	rideCache.clear()
+	invalidateRideFilters()
+	return nil
+}
+
+// anonymizeNotifications replaces the actor name in notifications about something userID did
+func anonymizeNotifications(tx *gorm.DB, userID uint) error {
+	var notifications []Notification
+	if err := tx.Where("payload LIKE ?", fmt.Sprintf(`%%"actor_user_id":%d%%`, userID)).Find(&notifications).Error; err != nil {
+		return err
+	}
+	for _, n := range notifications {
+		payload := n.decodePayload()
+		if payload.ActorUserID != userID || payload.ActorName == deletedUserName {
+			continue
+		}
+		payload.ActorName = deletedUserName
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&n).Update("payload", string(encoded)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// anonymizeAuditEvents rewrites the audit events that mention the user:
+// their UID in the actor, subject and "*_uid" snapshot fields, and the
+// profile fields of snapshots of their user row. uid and email are what the
+// row held before it was anonymized. This is the one exception to audit
+// events being append-only, so it bypasses the AuditEvent hooks and, through
+// a row in audit_anonymizations that only tx sees, the database trigger.
+func anonymizeAuditEvents(tx *gorm.DB, userID uint, uid, anonUID, email string) error {
+	var events []AuditEvent
+	err := tx.Where("actor_uid = ? OR subject_uid = ? OR (target_type = ? AND target_id = ?)", uid, uid, "user", userID).
+		Or("before LIKE ? OR after LIKE ?", "%"+uid+"%", "%"+uid+"%").
+		Or("before LIKE ? OR after LIKE ?", "%"+email+"%", "%"+email+"%").
+		Find(&events).Error
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Exec("INSERT INTO audit_anonymizations (user_id) VALUES (?)", userID).Error; err != nil {
+		return err
+	}
+	unhooked := tx.Session(&gorm.Session{SkipHooks: true})
+	for _, event := range events {
+		updates := map[string]interface{}{}
+		if event.ActorUID == uid {
+			updates["actor_uid"] = anonUID
+		}
+		if event.SubjectUID == uid {
+			updates["subject_uid"] = anonUID
+		}
+		if before, changed := anonymizeSnapshot(event.Before, userID, uid, anonUID); changed {
+			updates["before"] = before
+		}
+		if after, changed := anonymizeSnapshot(event.After, userID, uid, anonUID); changed {
+			updates["after"] = after
+		}
+		if len(updates) == 0 {
+			continue
+		}
+		if err := unhooked.Model(&AuditEvent{}).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Exec("DELETE FROM audit_anonymizations WHERE user_id = ?", userID).Error
+}
+
+// anonymizeSnapshot replaces uid with anonUID in the "*_uid" fields of the
+// JSON snapshot raw, and the profile fields of snapshots of user userID with
+// the placeholders deleteAccount stores, reporting whether anything changed
+func anonymizeSnapshot(raw string, userID uint, uid, anonUID string) (string, bool) {
+	if raw == "" {
+		return raw, false
+	}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return raw, false
+	}
+
+	id := json.Number(strconv.FormatUint(uint64(userID), 10))
+	profile := map[string]interface{}{"name": deletedUserName, "email": anonUID + "@deleted.invalid", "phone": "", "gender": "", "phone_verified_at": nil}
+	changed := false
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			// User snapshots are the only ones with an email
+			if _, ok := v["email"]; ok && v["id"] == id {
+				for key, anonymized := range profile {
+					if _, ok := v[key]; ok {
+						v[key] = anonymized
+						changed = true
+					}
+				}
+			}
+			for key, item := range v {
+				if s, ok := item.(string); ok && s == uid && strings.HasSuffix(key, "_uid") {
+					v[key] = anonUID
+					changed = true
+					continue
+				}
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(value)
+	if !changed {
+		return raw, false
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return raw, false
+	}
+	return string(encoded), true
+}
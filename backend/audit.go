@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuditEvent is an append-only record of a state change made through the API
+type AuditEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActorUID   string    `gorm:"type:varchar(100);index" json:"actor_uid"`   // Firebase UID of the caller, "system" for background jobs
+	SubjectUID string    `gorm:"type:varchar(100);index" json:"subject_uid"` // Firebase UID of the user affected, if different from the actor
+	Action     string    `gorm:"type:varchar(50);not null;index" json:"action"`
+	TargetType string    `gorm:"type:varchar(30);not null" json:"target_type"` // "ride", "request", "participant", "user"
+	TargetID   uint      `json:"target_id"`
+	RideID     uint      `gorm:"index" json:"ride_id,omitempty"`
+	Before     string    `gorm:"type:text" json:"before,omitempty"` // JSON snapshot before the change
+	After      string    `gorm:"type:text" json:"after,omitempty"`  // JSON snapshot after the change
+	RequestID  string    `gorm:"type:varchar(64)" json:"request_id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// errAuditImmutable is returned when code tries to modify or remove an audit event
+var errAuditImmutable = errors.New("audit events are append-only")
+
+// BeforeUpdate keeps audit events immutable
+func (AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return errAuditImmutable
+}
+
+// BeforeDelete keeps audit events immutable
+func (AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return errAuditImmutable
+}
+
+// auditEntry describes a state change to be recorded with recordAudit
+type auditEntry struct {
+	Action     string
+	TargetType string
+	TargetID   uint
+	RideID     uint
+	SubjectUID string
+	Before     interface{}
+	After      interface{}
+}
+
+// systemActor is the ActorUID recorded for changes made by background jobs
+const systemActor = "system"
+
+// requestIDFor returns the ID of the current request, taken from X-Request-ID or generated once per request
+func requestIDFor(c *gin.Context) string {
+	if id, exists := c.Get("request_id"); exists {
+		return id.(string)
+	}
+
+	id := c.GetHeader("X-Request-ID")
+	if id == "" || len(id) > 64 {
+		buf := make([]byte, 8)
+		rand.Read(buf)
+		id = hex.EncodeToString(buf)
+	}
+	c.Set("request_id", id)
+	return id
+}
+
+// recordAudit appends an audit event using db, which should be the
+// transaction making the change so that neither is kept without the other.
+// c may be nil for background jobs.
+func recordAudit(db *gorm.DB, c *gin.Context, entry auditEntry) error {
+	event := AuditEvent{
+		ActorUID:   systemActor,
+		SubjectUID: entry.SubjectUID,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		RideID:     entry.RideID,
+		Before:     auditSnapshot(entry.Before),
+		After:      auditSnapshot(entry.After),
+		CreatedAt:  time.Now(),
+	}
+	if c != nil {
+		if uid, exists := c.Get("uid"); exists {
+			event.ActorUID = uid.(string)
+		}
+		event.RequestID = requestIDFor(c)
+	}
+
+	if err := db.Create(&event).Error; err != nil {
+		slog.ErrorContext(db.Statement.Context, "failed to record audit event", "action", entry.Action, "target_type", entry.TargetType, "target_id", entry.TargetID, "error", err)
+		return err
+	}
+	return nil
+}
+
+// auditSnapshot encodes a before/after value as JSON, returning "" for nil
+func auditSnapshot(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		slog.Error("failed to encode audit snapshot", "error", err)
+		return ""
+	}
+	return string(encoded)
+}
+
+// GET /admin/audit?user_id=&ride_id=&from=&to=&limit= - Query the audit log (admins only)
+func GetAuditEvents(c *gin.Context) {
+	query := dbFor(c).Model(&AuditEvent{})
+
+	if userParam := c.Query("user_id"); userParam != "" {
+		// Accept either a database user ID or a Firebase UID
+		uid := userParam
+		if numericID, err := strconv.ParseUint(userParam, 10, 64); err == nil {
+			user, err := getUser(uint(numericID))
+			if err != nil {
+				respondError(c, http.StatusNotFound, "error.user_not_found")
+				return
+			}
+			uid = user.FirebaseUID
+		}
+		query = query.Where("actor_uid = ? OR subject_uid = ?", uid, uid)
+	}
+
+	if rideParam := c.Query("ride_id"); rideParam != "" {
+		rideID, err := strconv.Atoi(rideParam)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
+			return
+		}
+		query = query.Where("ride_id = ?", rideID)
+	}
+
+	for param, op := range map[string]string{"from": ">=", "to": "<="} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "error.invalid_timestamp", MessageArgs{"param": param})
+			return
+		}
+		query = query.Where("created_at "+op+" ?", parsed)
+	}
+
+	limit := 100
+	if limitParam := c.Query("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			respondError(c, http.StatusBadRequest, "error.invalid_limit")
+			return
+		}
+		limit = parsed
+	}
+
+	events := []AuditEvent{}
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&events).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_audit_failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// requestSnapshot exposes the requester's UID, which the model hides from API JSON
+func requestSnapshot(r Request) gin.H {
+	return gin.H{
+		"id":         r.ID,
+		"ride_id":    r.RideID,
+		"user_uid":   r.UserID,
+		"status":     r.Status,
+		"revoked_at": r.RevokedAt,
+		"created_at": r.CreatedAt,
+	}
+}
+
+// participantSnapshot exposes the participant's UID, which the model hides from API JSON
+func participantSnapshot(p Participant) gin.H {
+	return gin.H{
+		"id":        p.ID,
+		"ride_id":   p.RideID,
+		"user_uid":  p.UserID,
+		"joined_at": p.JoinedAt,
+	}
+}
+
+// participantSnapshots snapshots every participant of a ride
+func participantSnapshots(participants []Participant) []gin.H {
+	snapshots := make([]gin.H, 0, len(participants))
+	for _, p := range participants {
+		snapshots = append(snapshots, participantSnapshot(p))
+	}
+	return snapshots
+}
+
+// requestSnapshots snapshots a list of join requests
+func requestSnapshots(requests []Request) []gin.H {
+	snapshots := make([]gin.H, 0, len(requests))
+	for _, r := range requests {
+		snapshots = append(snapshots, requestSnapshot(r))
+	}
+	return snapshots
+}
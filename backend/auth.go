@@ -2,15 +2,18 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/auth"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/codes"
 	"google.golang.org/api/option"
+	"gorm.io/gorm"
 )
 
 var firebaseApp *firebase.App
@@ -18,19 +21,23 @@ var authClient *auth.Client
 
 // Initialize Firebase Admin SDK
 func InitFirebase() error {
+	var firebaseConfig *firebase.Config
 	var opt option.ClientOption
 
-	// Check if we're running in production (Render) with credentials in env var
-	firebaseCredentials := os.Getenv("FIREBASE_CREDENTIALS")
-	if firebaseCredentials != "" {
-		// Use credentials from environment variable
-		opt = option.WithCredentialsJSON([]byte(firebaseCredentials))
-	} else {
-		// Fallback to file for local development
-		opt = option.WithCredentialsFile("brocab-1c545-firebase-adminsdk-fbsvc-e3e6893c15.json")
+	// Production (Render) passes the credentials inline, local development points at the downloaded file
+	switch {
+	case config.FirebaseCredentials != "":
+		opt = option.WithCredentialsJSON([]byte(config.FirebaseCredentials))
+	case config.FirebaseCredentialsFile != "":
+		opt = option.WithCredentialsFile(config.FirebaseCredentialsFile)
+	default:
+		// Verifying ID tokens only needs Google's public keys and the project ID
+		firebaseConfig = &firebase.Config{ProjectID: config.FirebaseProjectID}
+		opt = option.WithoutAuthentication()
+		slog.Warn("no Firebase credentials set, only verifying ID tokens", "project_id", config.FirebaseProjectID)
 	}
 
-	app, err := firebase.NewApp(context.Background(), nil, opt)
+	app, err := firebase.NewApp(context.Background(), firebaseConfig, opt)
 	if err != nil {
 		return fmt.Errorf("error initializing firebase app: %v", err)
 	}
@@ -42,39 +49,84 @@ func InitFirebase() error {
 
 	firebaseApp = app
 	authClient = client
-	fmt.Println("✅ Firebase initialized")
+	slog.Info("firebase initialized")
 	return nil
 }
 
 // Middleware to verify Firebase token
 func FirebaseAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get the Authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing"})
-			c.Abort()
-			return
+		if authenticate(c) {
+			c.Next()
 		}
+	}
+}
 
-		// Format: "Bearer <token>"
-		idToken := strings.TrimPrefix(authHeader, "Bearer ")
-		if idToken == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
-			return
+// Middleware for routes that also serve anonymous callers: a token is
+// verified if one is sent, and "uid" is only set then
+func OptionalFirebaseAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" || authenticate(c) {
+			c.Next()
 		}
+	}
+}
+
+// authenticate verifies the Firebase ID token of the request and stores its
+// UID and email claims in the context. It responds with 401 and returns
+// false if the token is missing or invalid.
+func authenticate(c *gin.Context) bool {
+	// Get the Authorization header
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		respondError(c, http.StatusUnauthorized, "error.auth_header_missing")
+		return false
+	}
+
+	// Format: "Bearer <token>"
+	idToken := strings.TrimPrefix(authHeader, "Bearer ")
+	if idToken == authHeader {
+		respondError(c, http.StatusUnauthorized, "error.invalid_auth_header")
+		return false
+	}
+
+	// Verify token
+	ctx, span := tracer.Start(c.Request.Context(), "firebase.VerifyIDToken")
+	token, err := authClient.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid token")
+	}
+	span.End()
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, "error.invalid_token")
+		return false
+	}
+
+	// Store UID and the verified email claims in context
+	c.Set("uid", token.UID)
+	if email, ok := token.Claims["email"].(string); ok {
+		c.Set("email", email)
+	}
+	if verified, ok := token.Claims["email_verified"].(bool); ok {
+		c.Set("email_verified", verified)
+	}
+
+	// Resolve the user once for the whole request; before sign-up there is none yet
+	if _, err := currentUser(c); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		slog.WarnContext(c.Request.Context(), "failed to load signed-in user", "error", err)
+	}
+	return true
+}
 
-		// Verify token
-		token, err := authClient.VerifyIDToken(context.Background(), idToken)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+// Middleware that only lets admins through. Must run after FirebaseAuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := currentUser(c)
+		if err != nil || !user.IsAdmin {
+			respondError(c, http.StatusForbidden, "error.admin_required")
 			return
 		}
-
-		// Store UID in context
-		c.Set("uid", token.UID)
 		c.Next()
 	}
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// cacheMaxEntries bounds each cache; once full, new values aren't stored until entries expire
+const cacheMaxEntries = 10000
+
+// ttlCache is a small in-process cache whose entries live for CACHE_TTL.
+// Writers invalidate what they change, so within one process reads are never
+// stale; other replicas see changes once the TTL passes.
+type ttlCache[K comparable, V any] struct {
+	name       string // Metric label
+	mu         sync.Mutex
+	entries    map[K]cacheEntry[V]
+	generation uint64 // Bumped by every invalidation, see load
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[K comparable, V any](name string) *ttlCache[K, V] {
+	return &ttlCache[K, V]{name: name, entries: make(map[K]cacheEntry[V])}
+}
+
+// load returns the cached value for key, or calls fetch and caches its result.
+// A value fetched while the cache was invalidated isn't stored, since it may
+// predate the write that invalidated it.
+func (c *ttlCache[K, V]) load(key K, fetch func() (V, error)) (V, error) {
+	if config.CacheTTL <= 0 {
+		return fetch()
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		cacheLookups.WithLabelValues(c.name, "hit").Inc()
+		return entry.value, nil
+	}
+	cacheLookups.WithLabelValues(c.name, "miss").Inc()
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		if len(c.entries) >= cacheMaxEntries {
+			c.dropExpired(now)
+		}
+		if len(c.entries) < cacheMaxEntries {
+			c.entries[key] = cacheEntry[V]{value: value, expires: now.Add(config.CacheTTL)}
+		}
+	}
+	return value, nil
+}
+
+// invalidate drops key from the cache
+func (c *ttlCache[K, V]) invalidate(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	c.generation++
+}
+
+// clear drops every entry
+func (c *ttlCache[K, V]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.entries)
+	c.generation++
+}
+
+// dropExpired removes entries past their TTL. Call with mu held.
+func (c *ttlCache[K, V]) dropExpired(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+var (
+	// rideCache holds rides by ID for read-only lookups. Code that checks
+	// seats before changing them must read the database instead.
+	rideCache = newTTLCache[uint, Ride]("ride")
+	// rideFilterCache holds /ride/filter results by caller and query, see rideFilterKey
+	rideFilterCache = newTTLCache[string, []Ride]("ride_filter")
+)
+
+// cachedRide returns the ride with rideID, from rideCache when possible
+func cachedRide(db *gorm.DB, rideID uint) (Ride, error) {
+	return rideCache.load(rideID, func() (Ride, error) {
+		var ride Ride
+		err := db.First(&ride, "id = ?", rideID).Error
+		return ride, err
+	})
+}
+
+// rideFilterKey identifies a /ride/filter result. It includes the caller
+// because organization rides are only listed to members.
+func rideFilterKey(uid, origin, destination, date string, orgID *uint) string {
+	org := ""
+	if orgID != nil {
+		org = fmt.Sprint(*orgID)
+	}
+	return fmt.Sprintf("%q|%q|%q|%q|%s", uid, origin, destination, date, org)
+}
+
+// invalidateRide drops a created, changed or deleted ride from the caches
+func invalidateRide(rideID uint) {
+	rideCache.invalidate(rideID)
+	rideFilterCache.clear()
+}
+
+// invalidateRideFilters drops every cached /ride/filter result, for changes
+// to organization membership, which decides whose results include which rides
+func invalidateRideFilters() {
+	rideFilterCache.clear()
+}
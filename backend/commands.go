@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// command is one subcommand of the binary. Every command shares the
+// configuration, logging, tracing and database setup done in main.
+type command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(args []string) error
+	Schema  bool // Needs the migrations applied before running
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because "help" lists the table it is part of
+	commands = []command{
+		{Name: "serve", Usage: "serve", Summary: "Run the HTTP API (the default)", Run: runServe},
+		{Name: "migrate", Usage: "migrate up | down [steps] | status", Summary: "Apply, revert or list schema migrations", Run: runMigrateCommand},
+		{Name: "cleanup", Usage: "cleanup [--date YYYY-MM-DD] [--dry-run]", Summary: "Delete rides dated before --date (default today), notifying their passengers", Run: runCleanupCommand, Schema: true},
+		{Name: "seed", Usage: "seed [--users n] [--rides n] [--seed n] [--yes]", Summary: "Fill the database with fake users, rides, requests and participants", Run: runSeedCommand, Schema: true},
+		{Name: "export", Usage: "export --user <id|email|firebase-uid> | --ride <id> [--out file]", Summary: "Write everything stored about a user or a ride as JSON", Run: runExportCommand, Schema: true},
+		{Name: "help", Usage: "help", Summary: "List commands", Run: func([]string) error { printCommands(os.Stdout); return nil }},
+	}
+}
+
+// findCommand returns the command called name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// printCommands writes the command list shown by "help" and --help
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [flags] <command> [command flags]\n\nCommands:\n", os.Args[0])
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.Usage, cmd.Summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\nRun %s --help to list the configuration flags.\n", os.Args[0])
+}
+
+// requireSchema applies pending migrations when MIGRATE_ON_START allows it and
+// otherwise refuses to run against an outdated schema
+func requireSchema() error {
+	if err := applyMigrationsOnStart(); err != nil {
+		return err
+	}
+	if !migrationsApplied.Load() {
+		return errors.New("migrations are pending, run \"migrate up\" first")
+	}
+	return nil
+}
+
+// runCleanupCommand implements "cleanup", the same cleanup /ping triggers, for any cutoff date
+func runCleanupCommand(args []string) error {
+	flags := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	date := flags.String("date", time.Now().Format("2006-01-02"), "delete rides dated before this day")
+	dryRun := flags.Bool("dry-run", false, "only list the rides that would be deleted")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if _, err := time.Parse("2006-01-02", *date); err != nil {
+		return fmt.Errorf("invalid --date %q, expected YYYY-MM-DD", *date)
+	}
+	// A later date would delete rides that haven't happened yet
+	if today := time.Now().Format("2006-01-02"); *date > today && !*dryRun {
+		return fmt.Errorf("--date %s is after today, only allowed with --dry-run", *date)
+	}
+
+	report, err := cleanupRidesBefore(context.Background(), *date, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RIDE\tDATE\tTIME\tROUTE\tPARTICIPANTS\tREQUESTS")
+	for _, r := range report.Rides {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s -> %s\t%d\t%d\n", r.Ride.ID, r.Ride.Date, r.Ride.Time, r.Ride.Origin, r.Ride.Destination, r.Participants, r.Requests)
+	}
+	tw.Flush()
+	if *dryRun {
+		fmt.Printf("\n%d rides dated before %s would be deleted (dry run, nothing changed)\n", len(report.Rides), *date)
+	} else {
+		fmt.Printf("\n%d of %d rides dated before %s deleted\n", report.Deleted, len(report.Rides), *date)
+	}
+	return nil
+}
+
+// runSeedCommand implements "seed". Seeding Postgres needs --yes so it isn't run against production by accident.
+func runSeedCommand(args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	users := flags.Int("users", 20, "number of users to create")
+	rides := flags.Int("rides", 30, "number of rides to create")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "random seed, to reproduce a data set")
+	yes := flags.Bool("yes", false, "confirm seeding a Postgres database")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *users < 0 || *rides < 0 {
+		return errors.New("--users and --rides must not be negative")
+	}
+	if config.DBDriver == "postgres" && !*yes {
+		return errors.New("refusing to seed a Postgres database without --yes")
+	}
+
+	summary, err := seedDatabase(context.Background(), seedOptions{Users: *users, Rides: *rides, Seed: *seed})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %d users, %d rides, %d requests and %d participants (seed %d)\n",
+		summary.Users, summary.Rides, summary.Requests, summary.Participants, *seed)
+	return nil
+}
+
+// runExportCommand implements "export", writing a user's or a ride's data as indented JSON
+func runExportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	userRef := flags.String("user", "", "user database ID, email or Firebase UID")
+	rideRef := flags.String("ride", "", "ride ID")
+	out := flags.String("out", "", "file to write instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if (*userRef == "") == (*rideRef == "") {
+		return errors.New("export needs exactly one of --user or --ride")
+	}
+
+	db := DB.WithContext(context.Background())
+	var export interface{}
+	if *userRef != "" {
+		user, err := findUserByRef(db, *userRef)
+		if err != nil {
+			return err
+		}
+		if export, err = buildUserExport(db, *user); err != nil {
+			return err
+		}
+	} else {
+		rideID, err := strconv.ParseUint(*rideRef, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid --ride %q", *rideRef)
+		}
+		if export, err = buildRideExport(db, uint(rideID)); err != nil {
+			return err
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
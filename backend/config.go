@@ -0,0 +1,369 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config holds every setting of the backend. Each field is read from, in
+// order of precedence: a command-line flag (the env name in lower case with
+// dashes, e.g. --request-cooldown), the environment, the config file (.env
+// by default, or --config), and finally the default below.
+type Config struct {
+	Port           string   `env:"PORT" default:"8080" help:"HTTP port"`
+	CORSOrigins    []string `env:"CORS_ALLOWED_ORIGINS" help:"Comma-separated origins allowed by CORS, * for any (default: the Vite dev server with DB_DRIVER=sqlite, required otherwise)"`
+	TrustedProxies []string `env:"TRUSTED_PROXIES" default:"127.0.0.1,::1" help:"Comma-separated proxies trusted for X-Forwarded-For"`
+
+	// Database. DB_DRIVER defaults to postgres when DATABASE_URL or any POSTGRES_*
+	// setting is given and to sqlite otherwise. DATABASE_URL takes precedence over POSTGRES_*.
+	DBDriver          string        `env:"DB_DRIVER" help:"postgres or sqlite (default: postgres if DATABASE_URL or any POSTGRES_* is set)"`
+	SQLitePath        string        `env:"SQLITE_PATH" default:"brocab.db" help:"SQLite database file, or :memory: for a throwaway database"`
+	DatabaseURL       string        `env:"DATABASE_URL" secret:"true" help:"Postgres connection URL"`
+	PostgresHost      string        `env:"POSTGRES_HOST" help:"Postgres host"`
+	PostgresPort      string        `env:"POSTGRES_PORT" default:"5432" help:"Postgres port, 6543 for the Supabase transaction pooler"`
+	PostgresUser      string        `env:"POSTGRES_USER" help:"Postgres user"`
+	PostgresPassword  string        `env:"POSTGRES_PASSWORD" secret:"true" help:"Postgres password"`
+	PostgresDB        string        `env:"POSTGRES_DB" default:"postgres" help:"Postgres database name"`
+	DBMaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" default:"25" help:"Maximum open database connections"`
+	DBMaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" default:"10" help:"Maximum idle database connections"`
+	DBConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" default:"30m" help:"Maximum lifetime of a database connection"`
+	DBConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" default:"5m" help:"Maximum idle time of a database connection"`
+	MigrateOnStart    bool          `env:"MIGRATE_ON_START" default:"true" help:"Apply pending migrations when the server starts"`
+
+	// Firebase. Without credentials ID tokens are still verified, against FIREBASE_PROJECT_ID.
+	FirebaseCredentials     string `env:"FIREBASE_CREDENTIALS" secret:"true" help:"Firebase service account JSON"`
+	FirebaseCredentialsFile string `env:"FIREBASE_CREDENTIALS_FILE" help:"Path to the Firebase service account JSON file"`
+	FirebaseProjectID       string `env:"FIREBASE_PROJECT_ID" default:"brocab-1c545" help:"Firebase project whose ID tokens are accepted"`
+
+	// Sign-up. Admins can allow or deny single addresses through /admin/email-access.
+	AllowedEmailDomains  []string `env:"ALLOWED_EMAIL_DOMAINS" help:"Comma-separated email domains (and their subdomains) that can sign up, any if empty"`
+	RequireVerifiedEmail bool     `env:"REQUIRE_VERIFIED_EMAIL" default:"true" help:"Only accept sign-ups whose ID token has email_verified"`
+
+	// Personal data exports
+	DataExportTTL        time.Duration `env:"DATA_EXPORT_TTL" default:"24h" help:"How long a finished data export can be downloaded"`
+	DataExportSigningKey string        `env:"DATA_EXPORT_SIGNING_KEY" secret:"true" help:"Key for signing download links; required with Postgres, random per process with SQLite if unset"`
+
+	// Phone verification. SMS_PROVIDER=log writes codes to the log instead of
+	// sending them, so it is only allowed, and the default, with DB_DRIVER=sqlite.
+	SMSProvider             string        `env:"SMS_PROVIDER" help:"log or twilio (default: log with DB_DRIVER=sqlite, required otherwise)"`
+	TwilioAccountSID        string        `env:"TWILIO_ACCOUNT_SID" help:"Twilio account SID, for SMS_PROVIDER=twilio"`
+	TwilioAuthToken         string        `env:"TWILIO_AUTH_TOKEN" secret:"true" help:"Twilio auth token, for SMS_PROVIDER=twilio"`
+	TwilioFrom              string        `env:"TWILIO_FROM" help:"Sender number or messaging service SID, for SMS_PROVIDER=twilio"`
+	PhoneDefaultCountryCode string        `env:"PHONE_DEFAULT_COUNTRY_CODE" default:"91" help:"Country calling code assumed for numbers entered without one"`
+	PhoneOTPTTL             time.Duration `env:"PHONE_OTP_TTL" default:"10m" help:"How long a phone verification code is valid"`
+	PhoneOTPMaxAttempts     int           `env:"PHONE_OTP_MAX_ATTEMPTS" default:"5" help:"Wrong codes allowed before a verification has to be restarted"`
+
+	// Account deletion
+	AccountDeletionGrace time.Duration `env:"ACCOUNT_DELETION_GRACE" default:"168h" help:"How long a deleted account can still be restored before it is anonymized"`
+
+	// Rides
+	RequestCooldown time.Duration `env:"REQUEST_COOLDOWN" default:"30m" help:"How long a removed passenger waits before requesting the same ride again"`
+	ContactWindow   time.Duration `env:"CONTACT_WINDOW" default:"24h" help:"Leaders and participants see each other's phone numbers from this long before departure until this long after"`
+
+	// Operations
+	LogLevel         string        `env:"LOG_LEVEL" default:"info" help:"debug, info, warn or error"`
+	LogFormat        string        `env:"LOG_FORMAT" default:"json" help:"json or text"`
+	RateLimitBackend string        `env:"RATE_LIMIT_BACKEND" default:"memory" help:"memory, postgres or off"`
+	IdempotencyTTL   time.Duration `env:"IDEMPOTENCY_TTL" default:"24h" help:"How long Idempotency-Key responses are kept"`
+	CacheTTL         time.Duration `env:"CACHE_TTL" default:"30s" help:"How long rides and /ride/filter results are cached in memory, 0 disables the cache"`
+	OpenAPIValidate  bool          `env:"OPENAPI_VALIDATE" default:"false" help:"Validate requests against the OpenAPI spec"`
+	ShutdownTimeout  time.Duration `env:"SHUTDOWN_TIMEOUT" default:"15s" help:"Time allowed for draining on shutdown"`
+	TracesExporter   string        `env:"OTEL_TRACES_EXPORTER" default:"none" help:"none, otlp or stdout"`
+	OTLPEndpoint     string        `env:"OTEL_EXPORTER_OTLP_ENDPOINT" help:"OTLP/HTTP collector URL"`
+
+	// RateLimits overrides budgets from RATE_LIMIT_<BUDGET>=<capacity>/<window>, e.g. RATE_LIMIT_RIDE_CREATE=10/1h
+	RateLimits map[string]RateLimit `env:"-"`
+}
+
+// config is the configuration loaded at startup
+var config Config
+
+// devFrontendOrigin is where the frontend's dev server runs, see Frontend/vite.config.js
+const devFrontendOrigin = "http://localhost:5173"
+
+// LoadConfig reads the configuration from flags in args, the environment and
+// the config file, validates it and stores it in config. It returns the
+// arguments left after the flags.
+func LoadConfig(name string, args []string) ([]string, error) {
+	fields := configFields()
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flags.String("config", ".env", "KEY=VALUE file with settings")
+	flagValues := make(map[string]*string)
+	for _, field := range fields {
+		flagValues[field.env] = flags.String(field.flag, field.fallback, field.help)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	setFlags := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	fileValues, err := godotenv.Read(*configFile)
+	if err != nil {
+		if setFlags["config"] || !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error reading config file %s: %v", *configFile, err)
+		}
+		fileValues = map[string]string{}
+	}
+
+	lookup := func(env, flagName string) (string, bool) {
+		if setFlags[flagName] {
+			return *flagValues[env], true
+		}
+		if value, ok := os.LookupEnv(env); ok {
+			return value, true
+		}
+		value, ok := fileValues[env]
+		return value, ok
+	}
+
+	var loaded Config
+	target := reflect.ValueOf(&loaded).Elem()
+	var errs []error
+	for _, field := range fields {
+		value, ok := lookup(field.env, field.flag)
+		if !ok {
+			value = field.fallback
+		}
+		if err := setConfigField(target.Field(field.index), value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", field.env, err))
+		}
+	}
+
+	loaded.RateLimits = make(map[string]RateLimit)
+	for budget := range rateLimitBudgets {
+		env := "RATE_LIMIT_" + strings.ToUpper(budget)
+		if value, ok := lookup(env, ""); ok && value != "" {
+			limit, err := parseRateLimit(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", env, err))
+				continue
+			}
+			loaded.RateLimits[budget] = limit
+		}
+	}
+
+	// Any Postgres setting means Postgres, so a partial setup fails validation
+	// instead of quietly running on a fresh SQLite file
+	if loaded.DBDriver == "" {
+		loaded.DBDriver = "sqlite"
+		if loaded.DatabaseURL != "" {
+			loaded.DBDriver = "postgres"
+		}
+		for _, field := range fields {
+			if value, ok := lookup(field.env, field.flag); ok && value != "" && strings.HasPrefix(field.env, "POSTGRES_") {
+				loaded.DBDriver = "postgres"
+			}
+		}
+	}
+
+	// Local development on SQLite needs no SMS account and serves the
+	// frontend from the Vite dev server
+	if loaded.DBDriver == "sqlite" {
+		if loaded.SMSProvider == "" {
+			loaded.SMSProvider = "log"
+		}
+		if len(loaded.CORSOrigins) == 0 {
+			loaded.CORSOrigins = []string{devFrontendOrigin}
+		}
+	}
+
+	if len(errs) == 0 {
+		errs = loaded.validate()
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%v", err)
+	}
+
+	config = loaded
+	return flags.Args(), nil
+}
+
+// validate checks settings that parse fine on their own but are out of range or inconsistent
+func (c Config) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	oneOf := func(value string, allowed ...string) bool {
+		for _, a := range allowed {
+			if value == a {
+				return true
+			}
+		}
+		return false
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: %q is not a valid port", c.Port))
+	}
+	check(oneOf(c.DBDriver, "postgres", "sqlite"), "DB_DRIVER: %q is not postgres or sqlite", c.DBDriver)
+	if c.DBDriver == "postgres" && c.DatabaseURL == "" {
+		check(c.PostgresHost != "", "POSTGRES_HOST: required when DATABASE_URL is not set")
+		check(c.PostgresUser != "", "POSTGRES_USER: required when DATABASE_URL is not set")
+		check(c.PostgresPassword != "", "POSTGRES_PASSWORD: required when DATABASE_URL is not set")
+	}
+	if c.DBDriver == "sqlite" {
+		check(c.SQLitePath != "", "SQLITE_PATH: required when DB_DRIVER is sqlite")
+		check(c.RateLimitBackend != "postgres", "RATE_LIMIT_BACKEND: postgres needs DB_DRIVER=postgres")
+	}
+	check(c.DBMaxOpenConns > 0, "DB_MAX_OPEN_CONNS: must be positive")
+	check(c.DBMaxIdleConns >= 0 && c.DBMaxIdleConns <= c.DBMaxOpenConns, "DB_MAX_IDLE_CONNS: must be between 0 and DB_MAX_OPEN_CONNS")
+	check(c.DBConnMaxLifetime > 0, "DB_CONN_MAX_LIFETIME: must be positive")
+	check(c.DBConnMaxIdleTime > 0, "DB_CONN_MAX_IDLE_TIME: must be positive")
+	check(c.FirebaseCredentials != "" || c.FirebaseCredentialsFile != "" || c.FirebaseProjectID != "",
+		"FIREBASE_PROJECT_ID: required when no Firebase credentials are set")
+	check(len(c.CORSOrigins) > 0, "CORS_ALLOWED_ORIGINS: required when DB_DRIVER is postgres")
+	// Replicas have to agree on the key, or links signed by one fail on the others
+	check(c.DBDriver != "postgres" || c.DataExportSigningKey != "", "DATA_EXPORT_SIGNING_KEY: required when DB_DRIVER is postgres")
+	check(c.RequestCooldown >= 0, "REQUEST_COOLDOWN: must not be negative")
+	check(oneOf(c.LogLevel, "debug", "info", "warn", "error"), "LOG_LEVEL: %q is not debug, info, warn or error", c.LogLevel)
+	check(oneOf(c.LogFormat, "json", "text"), "LOG_FORMAT: %q is not json or text", c.LogFormat)
+	check(oneOf(c.RateLimitBackend, "memory", "postgres", "off"), "RATE_LIMIT_BACKEND: %q is not memory, postgres or off", c.RateLimitBackend)
+	for _, domain := range c.AllowedEmailDomains {
+		check(!strings.ContainsAny(strings.TrimPrefix(domain, "@"), "@ /"), "ALLOWED_EMAIL_DOMAINS: %q is not a domain", domain)
+	}
+	check(c.DataExportTTL > 0, "DATA_EXPORT_TTL: must be positive")
+	check(c.ContactWindow > 0, "CONTACT_WINDOW: must be positive")
+	check(c.SMSProvider != "", "SMS_PROVIDER: required when DB_DRIVER is postgres")
+	if c.SMSProvider != "" {
+		check(oneOf(c.SMSProvider, "log", "twilio"), "SMS_PROVIDER: %q is not log or twilio", c.SMSProvider)
+		check(c.SMSProvider != "log" || c.DBDriver == "sqlite", "SMS_PROVIDER: log needs DB_DRIVER=sqlite")
+	}
+	if c.SMSProvider == "twilio" {
+		check(c.TwilioAccountSID != "", "TWILIO_ACCOUNT_SID: required when SMS_PROVIDER is twilio")
+		check(c.TwilioAuthToken != "", "TWILIO_AUTH_TOKEN: required when SMS_PROVIDER is twilio")
+		check(c.TwilioFrom != "", "TWILIO_FROM: required when SMS_PROVIDER is twilio")
+	}
+	check(countryCodePattern.MatchString(c.PhoneDefaultCountryCode), "PHONE_DEFAULT_COUNTRY_CODE: %q is not a calling code like 91", c.PhoneDefaultCountryCode)
+	check(c.PhoneOTPTTL > 0, "PHONE_OTP_TTL: must be positive")
+	check(c.PhoneOTPMaxAttempts > 0, "PHONE_OTP_MAX_ATTEMPTS: must be positive")
+	check(c.AccountDeletionGrace >= 0, "ACCOUNT_DELETION_GRACE: must not be negative")
+	check(c.IdempotencyTTL > 0, "IDEMPOTENCY_TTL: must be positive")
+	check(c.CacheTTL >= 0, "CACHE_TTL: must not be negative")
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT: must be positive")
+	check(oneOf(c.TracesExporter, "none", "otlp", "stdout"), "OTEL_TRACES_EXPORTER: %q is not none, otlp or stdout", c.TracesExporter)
+	return errs
+}
+
+// LogValue prints the configuration with secrets redacted, so it is safe to log
+func (c Config) LogValue() slog.Value {
+	value := reflect.ValueOf(c)
+	var attrs []slog.Attr
+	for _, field := range configFields() {
+		var shown string
+		switch v := value.Field(field.index).Interface().(type) {
+		case []string:
+			shown = strings.Join(v, ",")
+		default:
+			shown = fmt.Sprint(v)
+		}
+		if field.secret && shown != "" {
+			shown = "[REDACTED]"
+		}
+		attrs = append(attrs, slog.String(field.env, shown))
+	}
+	budgets := make([]string, 0, len(c.RateLimits))
+	for budget := range c.RateLimits {
+		budgets = append(budgets, budget)
+	}
+	sort.Strings(budgets)
+	for _, budget := range budgets {
+		limit := c.RateLimits[budget]
+		attrs = append(attrs, slog.String("RATE_LIMIT_"+strings.ToUpper(budget), fmt.Sprintf("%d/%s", limit.Capacity, limit.Window)))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// String is the redacted configuration as KEY=VALUE lines
+func (c Config) String() string {
+	var b strings.Builder
+	for _, attr := range c.LogValue().Group() {
+		fmt.Fprintf(&b, "%s=%s\n", attr.Key, attr.Value.String())
+	}
+	return b.String()
+}
+
+// configField describes one tagged field of Config
+type configField struct {
+	index    int
+	env      string
+	flag     string
+	fallback string
+	help     string
+	secret   bool
+}
+
+func configFields() []configField {
+	t := reflect.TypeOf(Config{})
+	var fields []configField
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag
+		env := tag.Get("env")
+		if env == "" || env == "-" {
+			continue
+		}
+		fields = append(fields, configField{
+			index:    i,
+			env:      env,
+			flag:     strings.ReplaceAll(strings.ToLower(env), "_", "-"),
+			fallback: tag.Get("default"),
+			help:     tag.Get("help"),
+			secret:   tag.Get("secret") == "true",
+		})
+	}
+	return fields
+}
+
+// setConfigField parses value into a Config field of any supported type
+func setConfigField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(value)
+	case bool:
+		if value == "" {
+			value = "false"
+		}
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(parsed)
+	case int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetInt(int64(parsed))
+	case time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration such as 30m or 24h", value)
+		}
+		field.SetInt(int64(parsed))
+	case []string:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config type %s", field.Type())
+	}
+	return nil
+}
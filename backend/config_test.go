@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	// A production setup on Postgres, with every other setting at its default
+	if _, err := LoadConfig("test", []string{"--config", os.DevNull,
+		"--database-url", "postgres://brocab@db/brocab",
+		"--cors-allowed-origins", "https://brocab.example",
+		"--data-export-signing-key", "secret",
+		"--sms-provider", "twilio", "--twilio-account-sid", "AC1", "--twilio-auth-token", "token", "--twilio-from", "+15550100",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	base := config
+
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []string
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{"sqlite", func(c *Config) {
+			c.DBDriver, c.DatabaseURL, c.SMSProvider, c.DataExportSigningKey = "sqlite", "", "log", ""
+		}, nil},
+		{"unknown driver", func(c *Config) { c.DBDriver = "mysql" },
+			[]string{`DB_DRIVER: "mysql" is not postgres or sqlite`}},
+		{"postgres without connection settings", func(c *Config) { c.DatabaseURL = "" }, []string{
+			"POSTGRES_HOST: required when DATABASE_URL is not set",
+			"POSTGRES_USER: required when DATABASE_URL is not set",
+			"POSTGRES_PASSWORD: required when DATABASE_URL is not set",
+		}},
+		{"postgres without CORS origins", func(c *Config) { c.CORSOrigins = nil },
+			[]string{"CORS_ALLOWED_ORIGINS: required when DB_DRIVER is postgres"}},
+		{"postgres without signing key", func(c *Config) { c.DataExportSigningKey = "" },
+			[]string{"DATA_EXPORT_SIGNING_KEY: required when DB_DRIVER is postgres"}},
+		{"postgres without SMS provider", func(c *Config) { c.SMSProvider = "" },
+			[]string{"SMS_PROVIDER: required when DB_DRIVER is postgres"}},
+		{"postgres with SMS log", func(c *Config) { c.SMSProvider = "log" },
+			[]string{"SMS_PROVIDER: log needs DB_DRIVER=sqlite"}},
+		{"twilio without token", func(c *Config) { c.TwilioAuthToken = "" },
+			[]string{"TWILIO_AUTH_TOKEN: required when SMS_PROVIDER is twilio"}},
+		{"sqlite with postgres rate limits", func(c *Config) { c.DBDriver, c.RateLimitBackend = "sqlite", "postgres" },
+			[]string{"RATE_LIMIT_BACKEND: postgres needs DB_DRIVER=postgres"}},
+		{"port out of range", func(c *Config) { c.Port = "70000" },
+			[]string{`PORT: "70000" is not a valid port`}},
+		{"unknown log level", func(c *Config) { c.LogLevel = "verbose" },
+			[]string{`LOG_LEVEL: "verbose" is not debug, info, warn or error`}},
+		{"more idle than open connections", func(c *Config) { c.DBMaxIdleConns = c.DBMaxOpenConns + 1 },
+			[]string{"DB_MAX_IDLE_CONNS: must be between 0 and DB_MAX_OPEN_CONNS"}},
+		{"email domain with @ prefix", func(c *Config) { c.AllowedEmailDomains = []string{"@iitd.ac.in"} }, nil},
+		{"email address as domain", func(c *Config) { c.AllowedEmailDomains = []string{"admin@iitd.ac.in"} },
+			[]string{`ALLOWED_EMAIL_DOMAINS: "admin@iitd.ac.in" is not a domain`}},
+		{"country code with plus", func(c *Config) { c.PhoneDefaultCountryCode = "+91" },
+			[]string{`PHONE_DEFAULT_COUNTRY_CODE: "+91" is not a calling code like 91`}},
+		{"no OTP attempts", func(c *Config) { c.PhoneOTPMaxAttempts = 0 },
+			[]string{"PHONE_OTP_MAX_ATTEMPTS: must be positive"}},
+		{"negative request cooldown", func(c *Config) { c.RequestCooldown = -time.Minute },
+			[]string{"REQUEST_COOLDOWN: must not be negative"}},
+		{"cache disabled", func(c *Config) { c.CacheTTL = 0 }, nil},
+		{"no Firebase project", func(c *Config) { c.FirebaseProjectID = "" },
+			[]string{"FIREBASE_PROJECT_ID: required when no Firebase credentials are set"}},
+		{"Firebase credentials instead of project", func(c *Config) { c.FirebaseProjectID, c.FirebaseCredentialsFile = "", "firebase.json" }, nil},
+		{"several problems", func(c *Config) { c.LogFormat, c.IdempotencyTTL = "xml", 0 }, []string{
+			`LOG_FORMAT: "xml" is not json or text`,
+			"IDEMPOTENCY_TTL: must be positive",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base
+			tt.change(&c)
+			var got []string
+			for _, err := range c.validate() {
+				got = append(got, err.Error())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DataExport is a user's request for a copy of their data. The worker builds
+// the archive in the background; it can then be downloaded once, through a
+// signed link, until it expires.
+type DataExport struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserUID      string `gorm:"type:varchar(100);not null;index"`
+	Status       string `gorm:"type:varchar(20);not null;index"` // "pending", "running", "ready", "failed", "downloaded" or "expired"
+	Error        string `gorm:"type:text"`                       // Why the export failed, for operators only
+	Archive      []byte // Zip file, cleared once downloaded or expired
+	Size         int
+	ExpiresAt    *time.Time
+	DownloadedAt *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+const (
+	// dataExportPollInterval is how often the worker looks for pending exports
+	dataExportPollInterval = 5 * time.Second
+	// dataExportStaleAfter returns a running export to the queue if its worker died
+	dataExportStaleAfter = 10 * time.Minute
+)
+
+// dataExportKey signs download links, from DATA_EXPORT_SIGNING_KEY
+var dataExportKey []byte
+
+// InitDataExports sets up link signing and starts the worker that builds
+// pending archives and expires old ones
+func InitDataExports() error {
+	dataExportKey = []byte(config.DataExportSigningKey)
+	if len(dataExportKey) == 0 {
+		dataExportKey = make([]byte, 32)
+		if _, err := rand.Read(dataExportKey); err != nil {
+			return fmt.Errorf("error generating export signing key: %v", err)
+		}
+		// Only SQLite gets here, config validation requires the key with Postgres
+		slog.Warn("DATA_EXPORT_SIGNING_KEY not set, download links only work until the server restarts")
+	}
+
+	startBackgroundJob("data_exports", dataExportPollInterval, func(ctx context.Context) {
+		processDataExports(ctx)
+		expireDataExports(ctx)
+	})
+	return nil
+}
+
+// GET /user/export - Start an export of the caller's data, or return the one already in progress
+func RequestDataExport(c *gin.Context) {
+	uid := c.MustGet("uid").(string)
+	if _, err := currentUser(c); err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+
+	// Reuse an export that is queued, running or still downloadable
+	var export DataExport
+	err := dbFor(c).Omit("archive").
+		Where("user_uid = ? AND (status IN ? OR (status = ? AND expires_at > ?))", uid, []string{"pending", "running"}, "ready", time.Now()).
+		Order("id DESC").First(&export).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusInternalServerError, "error.data_export_failed")
+		return
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		export = DataExport{UserUID: uid, Status: "pending"}
+		err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&export).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, auditEntry{
+				Action:     "data_export_requested",
+				TargetType: "data_export",
+				TargetID:   export.ID,
+				SubjectUID: uid,
+			})
+		})
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "error.data_export_failed")
+			return
+		}
+		dataExports.WithLabelValues("requested").Inc()
+	}
+
+	c.JSON(http.StatusAccepted, newDataExportResponse(c, export))
+}
+
+// GET /user/export/:exportID - Status of one of the caller's exports, with a download link once ready
+func GetDataExport(c *gin.Context) {
+	uid := c.MustGet("uid").(string)
+	exportID, err := strconv.Atoi(c.Param("exportID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_export_id")
+		return
+	}
+
+	var export DataExport
+	if err := dbFor(c).Omit("archive").Where("id = ? AND user_uid = ?", exportID, uid).First(&export).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.data_export_not_found")
+		return
+	}
+	c.JSON(http.StatusOK, newDataExportResponse(c, export))
+}
+
+// errDataExportClaimed is returned when another request already downloaded the export
+var errDataExportClaimed = errors.New("data export already downloaded")
+
+// GET /user/export/:exportID/download - Download a finished export once, through the signed link
+// from the status endpoint. The link is the credential, so no token is needed.
+func DownloadDataExport(c *gin.Context) {
+	exportID, err := strconv.Atoi(c.Param("exportID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_export_id")
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(c.Query("signature")), []byte(signDataExportLink(uint(exportID), expires))) {
+		respondError(c, http.StatusForbidden, "error.data_export_link_invalid")
+		return
+	}
+	if time.Now().Unix() > expires {
+		respondError(c, http.StatusGone, "error.data_export_link_expired")
+		return
+	}
+
+	var export DataExport
+	if err := dbFor(c).First(&export, "id = ?", exportID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.data_export_not_found")
+		return
+	}
+	if export.Status != "ready" {
+		respondError(c, http.StatusGone, "error.data_export_unavailable")
+		return
+	}
+
+	// Claim the download; only one request can move the export out of "ready"
+	now := time.Now()
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&DataExport{}).Where("id = ? AND status = ?", export.ID, "ready").
+			Updates(map[string]interface{}{"status": "downloaded", "downloaded_at": now, "archive": nil, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errDataExportClaimed
+		}
+		return recordAudit(tx, c, auditEntry{
+			Action:     "data_export_downloaded",
+			TargetType: "data_export",
+			TargetID:   export.ID,
+			SubjectUID: export.UserUID,
+		})
+	})
+	if errors.Is(err, errDataExportClaimed) {
+		respondError(c, http.StatusGone, "error.data_export_unavailable")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.data_export_failed")
+		return
+	}
+	dataExports.WithLabelValues("downloaded").Inc()
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="brocab-data-%d.zip"`, export.ID))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", export.Archive)
+}
+
+// newDataExportResponse describes export, signing a download link when it is ready
+func newDataExportResponse(c *gin.Context, export DataExport) DataExportResponse {
+	response := DataExportResponse{
+		ID:        export.ID,
+		Status:    export.Status,
+		StatusURL: fmt.Sprintf("%s/user/export/%d", apiPrefix(c), export.ID),
+		CreatedAt: export.CreatedAt,
+	}
+	if export.Status == "ready" && export.ExpiresAt != nil {
+		expires := export.ExpiresAt.Unix()
+		response.Size = export.Size
+		response.ExpiresAt = export.ExpiresAt
+		response.DownloadURL = fmt.Sprintf("%s/user/export/%d/download?expires=%d&signature=%s",
+			apiPrefix(c), export.ID, expires, signDataExportLink(export.ID, expires))
+	}
+	return response
+}
+
+// apiPrefix is "/v1" for versioned requests and "" on the deprecated aliases
+func apiPrefix(c *gin.Context) string {
+	if c.GetBool("legacy_api") {
+		return ""
+	}
+	return "/v1"
+}
+
+// signDataExportLink authenticates a download link for exportID valid until expires (Unix seconds)
+func signDataExportLink(exportID uint, expires int64) string {
+	mac := hmac.New(sha256.New, dataExportKey)
+	fmt.Fprintf(mac, "%d.%d", exportID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// processDataExports builds every pending archive. Exports are claimed with a
+// conditional update so several replicas can run the worker side by side.
+func processDataExports(ctx context.Context) {
+	db := DB.WithContext(ctx)
+	for ctx.Err() == nil {
+		var export DataExport
+		queued := db.Where("status = ? OR (status = ? AND updated_at < ?)", "pending", "running", time.Now().Add(-dataExportStaleAfter))
+		err := db.Omit("archive").Where(queued).Order("id").First(&export).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to find pending data exports", "error", err)
+			return
+		}
+
+		claim := db.Model(&DataExport{}).Where("id = ?", export.ID).Where(queued).
+			Updates(map[string]interface{}{"status": "running", "updated_at": time.Now()})
+		if claim.Error != nil {
+			slog.ErrorContext(ctx, "failed to claim data export", "export_id", export.ID, "error", claim.Error)
+			return
+		}
+		if claim.RowsAffected == 0 {
+			continue // Another worker got there first
+		}
+
+		archive, err := buildDataExportArchive(db, export.UserUID)
+		now := time.Now()
+		updates := map[string]interface{}{"updated_at": now}
+		if err != nil {
+			slog.ErrorContext(ctx, "data export failed", "export_id", export.ID, "error", err)
+			updates["status"] = "failed"
+			updates["error"] = err.Error()
+			dataExports.WithLabelValues("failed").Inc()
+		} else {
+			updates["status"] = "ready"
+			updates["archive"] = archive
+			updates["size"] = len(archive)
+			updates["expires_at"] = now.Add(config.DataExportTTL)
+			dataExports.WithLabelValues("ready").Inc()
+		}
+		if err := db.Model(&DataExport{}).Where("id = ?", export.ID).Updates(updates).Error; err != nil {
+			slog.ErrorContext(ctx, "failed to save data export", "export_id", export.ID, "error", err)
+		}
+	}
+}
+
+// expireDataExports drops archives that were never downloaded in time
+func expireDataExports(ctx context.Context) {
+	result := DB.WithContext(ctx).Model(&DataExport{}).Where("status = ? AND expires_at < ?", "ready", time.Now()).
+		Updates(map[string]interface{}{"status": "expired", "archive": nil, "updated_at": time.Now()})
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "failed to expire data exports", "error", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		dataExports.WithLabelValues("expired").Add(float64(result.RowsAffected))
+	}
+}
+
+// buildDataExportArchive zips everything stored about the user as data.json
+// plus one CSV per table, for spreadsheets
+func buildDataExportArchive(db *gorm.DB, uid string) ([]byte, error) {
+	user, err := findUserByRef(db, uid)
+	if err != nil {
+		return nil, err
+	}
+	export, err := buildUserExport(db, *user)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	file, err := archive.Create("data.json")
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return nil, err
+	}
+
+	formatTime := func(t time.Time) string { return t.UTC().Format(time.RFC3339) }
+	u := export.User
+	tables := []struct {
+		name string
+		rows [][]string
+	}{
+		{"profile.csv", [][]string{
+			{"id", "name", "email", "phone", "gender", "locale", "created_at", "updated_at"},
+			{strconv.Itoa(int(u.ID)), u.Name, u.Email, u.Phone, u.Gender, u.Locale, formatTime(u.CreatedAt), formatTime(u.UpdatedAt)},
+		}},
+		{"rides_led.csv", [][]string{{"ride_id", "origin", "destination", "date", "time", "seats", "seats_filled", "price", "created_at"}}},
+		{"participations.csv", [][]string{{"ride_id", "joined_at"}}},
+		{"requests.csv", [][]string{{"request_id", "ride_id", "status", "revoked_at", "created_at", "updated_at"}}},
+		{"notifications.csv", [][]string{{"notification_id", "ride_id", "type", "title", "message", "is_read", "created_at"}}},
+	}
+	for _, r := range export.RidesPosted {
+		tables[1].rows = append(tables[1].rows, []string{strconv.Itoa(int(r.ID)), r.Origin, r.Destination, r.Date, r.Time,
+			strconv.Itoa(r.Seats), strconv.Itoa(r.SeatsFilled), strconv.FormatFloat(r.Price, 'f', -1, 64), formatTime(r.CreatedAt)})
+	}
+	for _, p := range export.Participations {
+		tables[2].rows = append(tables[2].rows, []string{strconv.Itoa(int(p.RideID)), formatTime(p.JoinedAt)})
+	}
+	for _, r := range export.Requests {
+		revokedAt := ""
+		if r.RevokedAt != nil {
+			revokedAt = formatTime(*r.RevokedAt)
+		}
+		tables[3].rows = append(tables[3].rows, []string{strconv.Itoa(int(r.ID)), strconv.Itoa(int(r.RideID)), r.Status,
+			revokedAt, formatTime(r.CreatedAt), formatTime(r.UpdatedAt)})
+	}
+	for _, n := range export.Notifications {
+		tables[4].rows = append(tables[4].rows, []string{strconv.Itoa(int(n.ID)), strconv.Itoa(int(n.RideID)), n.Type,
+			n.Title, n.Message, strconv.FormatBool(n.IsRead), formatTime(n.CreatedAt)})
+	}
+
+	for _, table := range tables {
+		file, err := archive.Create(table.name)
+		if err != nil {
+			return nil, err
+		}
+		if err := csv.NewWriter(file).WriteAll(table.rows); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
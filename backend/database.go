@@ -2,49 +2,94 @@ package main
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"strings"
 	"time"
 
-	"github.com/joho/godotenv"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 // Global DB instance
 var DB *gorm.DB
 
-// InitDatabase connects to the DB and migrates tables
+// InitDatabase connects to the DB named by DB_DRIVER. The schema is managed by the migrations in migrate.go.
 func InitDatabase() {
-	// Load environment variables from .env file
-	envVars := make(map[string]string)
-	if err := godotenv.Load(); err != nil {
-		log.Println("⚠️  No .env file found, using system environment variables")
+	var dialector gorm.Dialector
+	var gormConfig *gorm.Config
+	var connectionType string
+	if config.DBDriver == "sqlite" {
+		dialector, gormConfig, connectionType = sqliteDialector()
 	} else {
-		// Read .env file variables into a map for priority handling
-		file, err := os.ReadFile(".env")
-		if err == nil {
-			lines := strings.Split(string(file), "\n")
-			for _, line := range lines {
-				if strings.Contains(line, "=") && !strings.HasPrefix(line, "#") {
-					parts := strings.SplitN(line, "=", 2)
-					if len(parts) == 2 {
-						key := strings.TrimSpace(parts[0])
-						value := strings.TrimSpace(parts[1])
-						envVars[key] = value
-					}
-				}
-			}
+		dialector, gormConfig, connectionType = postgresDialector()
+	}
+
+	db, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		slog.Warn("first connection attempt failed, retrying in 2 seconds", "error", err)
+		time.Sleep(2 * time.Second)
+
+		// Retry once more
+		db, err = gorm.Open(dialector, gormConfig)
+		if err != nil {
+			fatal("failed to connect to database after retry", "error", err)
 		}
 	}
 
+	// Trace every query
+	if err := db.Use(gormTracing{}); err != nil {
+		fatal("failed to register query tracing", "error", err)
+	}
+
+	// Test the connection
+	sqlDB, err := db.DB()
+	if err != nil {
+		fatal("failed to get database instance", "error", err)
+	}
+
+	if config.DBDriver == "sqlite" {
+		// SQLite allows one writer at a time, and every connection to :memory:
+		// opens a new empty database, so share one connection that never expires
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	} else {
+		// Pool sizes come from DB_MAX_OPEN_CONNS and friends; keep them below the
+		// pooler's client limit when connecting through Supabase
+		sqlDB.SetMaxOpenConns(config.DBMaxOpenConns)
+		sqlDB.SetMaxIdleConns(config.DBMaxIdleConns)
+		sqlDB.SetConnMaxLifetime(config.DBConnMaxLifetime)
+		sqlDB.SetConnMaxIdleTime(config.DBConnMaxIdleTime)
+	}
+
+	if err := sqlDB.Ping(); err != nil {
+		fatal("failed to ping database", "error", err)
+	}
+	DB = db
+	slog.Info("database connected", "driver", config.DBDriver, "connection_type", connectionType)
+}
+
+// sqliteDialector opens SQLITE_PATH with foreign keys enforced, for local development
+func sqliteDialector() (gorm.Dialector, *gorm.Config, string) {
+	dsn := config.SQLitePath + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	connectionType := "In-memory SQLite"
+	if config.SQLitePath != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+		connectionType = "SQLite file"
+	}
+	slog.Info("using SQLite", "path", config.SQLitePath)
+	return sqlite.Open(dsn), &gorm.Config{Logger: newGormLogger(time.Second)}, connectionType
+}
+
+// postgresDialector builds the Postgres connection from DATABASE_URL or the POSTGRES_* settings
+func postgresDialector() (gorm.Dialector, *gorm.Config, string) {
 	var dsn string
 	var connectionType string
 
 	// Check if DATABASE_URL is provided (preferred method for Supabase)
-	if databaseURL := getEnvFromFile("DATABASE_URL", "", envVars); databaseURL != "" {
+	if databaseURL := config.DatabaseURL; databaseURL != "" {
 		// Ensure SSL is enabled in DATABASE_URL
 		if !strings.Contains(databaseURL, "sslmode=") {
 			if strings.Contains(databaseURL, "?") {
@@ -67,18 +112,14 @@ func InitDatabase() {
 			connectionType = "Custom"
 		}
 
-		fmt.Printf("🔗 Using DATABASE_URL for Supabase %s with SSL enforcement\n", connectionType)
+		slog.Info("using DATABASE_URL with SSL enforcement", "connection_type", connectionType)
 	} else {
-		// Fallback to individual parameters - prioritize .env file over system variables
-		host := getEnvFromFile("POSTGRES_HOST", "aws-0-ap-south-1.pooler.supabase.com", envVars)
-		user := getEnvFromFile("POSTGRES_USER", "postgres.lwghhgzhlyrourvbssjk", envVars)
-		password := getEnvFromFile("POSTGRES_PASSWORD", "", envVars)
-		dbname := getEnvFromFile("POSTGRES_DB", "postgres", envVars)
-		port := getEnvFromFile("POSTGRES_PORT", "6543", envVars)
-
-		if password == "" {
-			log.Fatal("❌ POSTGRES_PASSWORD environment variable is required for Supabase connection")
-		}
+		// Fallback to individual parameters, all required by config validation
+		host := config.PostgresHost
+		user := config.PostgresUser
+		password := config.PostgresPassword
+		dbname := config.PostgresDB
+		port := config.PostgresPort
 
 		// Determine connection type based on host and port
 		if strings.Contains(host, "pooler.supabase.com") {
@@ -108,7 +149,7 @@ func InitDatabase() {
 			)
 		}
 
-		fmt.Printf("🔗 Connecting to Supabase %s: %s@%s:%s/%s\n", connectionType, user, host, port, dbname)
+		slog.Info("connecting to Supabase", "connection_type", connectionType, "user", user, "host", host, "port", port, "database", dbname)
 	}
 
 	// Set connection timeout and retry logic with better prepared statement handling
@@ -119,105 +160,18 @@ func InitDatabase() {
 	if strings.Contains(connectionType, "Transaction Pooler") || strings.Contains(dsn, ":6543") {
 		// Transaction Pooler doesn't support prepared statements - DISABLE COMPLETELY
 		gormConfig = &gorm.Config{
-			PrepareStmt:                              false, // Required for Transaction Pooler
-			DisableForeignKeyConstraintWhenMigrating: true,  // Avoid constraint conflicts
-			Logger: logger.New(
-				log.New(os.Stdout, "\r\n", log.LstdFlags),
-				logger.Config{
-					SlowThreshold: 2 * time.Second, // More lenient for pooled connections
-					LogLevel:      logger.Warn,     // Reduce log noise
-					Colorful:      true,
-				},
-			),
+			PrepareStmt: false,                          // Required for Transaction Pooler
+			Logger:      newGormLogger(2 * time.Second), // More lenient for pooled connections
 		}
 	} else {
 		// Direct or Session Pooler can use prepared statements
 		gormConfig = &gorm.Config{
 			PrepareStmt: true, // Can use prepared statements for better performance
-			Logger: logger.New(
-				log.New(os.Stdout, "\r\n", log.LstdFlags),
-				logger.Config{
-					SlowThreshold: time.Second,
-					LogLevel:      logger.Info,
-					Colorful:      true,
-				},
-			),
+			Logger:      newGormLogger(time.Second),
 		}
 	}
 
-	db, err := gorm.Open(postgres.Open(dsn), gormConfig)
-	if err != nil {
-		log.Printf("❌ First connection attempt failed: %v", err)
-		log.Println("🔄 Retrying connection in 2 seconds...")
-		time.Sleep(2 * time.Second)
-
-		// Retry once more
-		db, err = gorm.Open(postgres.Open(dsn), gormConfig)
-		if err != nil {
-			log.Fatal("❌ Failed to connect to Supabase database after retry:", err)
-		}
-	}
-
-	// Test the connection
-	sqlDB, err := db.DB()
-	if err != nil {
-		log.Fatal("❌ Failed to get database instance:", err)
-	}
-
-	// Configure connection pool settings based on connection type
-	if strings.Contains(connectionType, "Transaction Pooler") {
-		// Optimized for stateless, short-lived connections - maximum capacity
-		sqlDB.SetMaxOpenConns(200)                // Maximum for Transaction Pooler
-		sqlDB.SetMaxIdleConns(50)                 // High idle connections for quick reuse
-		sqlDB.SetConnMaxLifetime(5 * time.Minute) // Optimal lifetime for pooled connections
-		sqlDB.SetConnMaxIdleTime(1 * time.Minute) // Quick cleanup of unused connections
-	} else {
-		// Optimized for persistent connections - maximum capacity
-		sqlDB.SetMaxOpenConns(500)                 // Maximum for direct connections
-		sqlDB.SetMaxIdleConns(100)                 // High idle pool for performance
-		sqlDB.SetConnMaxLifetime(30 * time.Minute) // Longer lifetime for persistent connections
-		sqlDB.SetConnMaxIdleTime(10 * time.Minute) // Reasonable idle timeout
-	}
-
-	if err := sqlDB.Ping(); err != nil {
-		log.Fatal("❌ Failed to ping Supabase database:", err)
-	}
-	DB = db
-	fmt.Printf("✅ Supabase database connected successfully via %s!\n", connectionType)
-
-	// Run migrations for all tables with error suppression for prepared statement conflicts
-	err = db.AutoMigrate(
-		&User{},
-		&Ride{},
-		&Request{},
-		&Participant{},
-		&Notification{},
-	)
-	if err != nil {
-		// Check if it's just a table already exists error or prepared statement conflict
-		if strings.Contains(err.Error(), "already exists") ||
-			strings.Contains(err.Error(), "prepared statement") {
-			fmt.Println("ℹ️  Tables already exist or migration completed with cache conflicts, continuing...")
-		} else {
-			log.Fatal("❌ Failed to migrate database:", err)
-		}
-	} else {
-		fmt.Println("✅ Database tables migrated successfully!")
-	}
-}
-
-// getEnvFromFile prioritizes .env file over system environment variables
-func getEnvFromFile(key, defaultVal string, envVars map[string]string) string {
-	// First check .env file variables
-	if value, exists := envVars[key]; exists && value != "" {
-		return value
-	}
-	// Then check system environment variables
-	if value, exists := os.LookupEnv(key); exists {
-		return value
-	}
-	// Finally return default value
-	return defaultVal
+	return postgres.Open(dsn), gormConfig, connectionType
 }
 
 // SafeQuery executes a GORM query with retry logic for prepared statement conflicts
@@ -0,0 +1,231 @@
+package main
+
+import "time"
+
+// HealthResponse is returned by /healthz and /readyz
+type HealthResponse struct {
+	Status string            `json:"status"`           // "ok" or "unavailable"
+	Checks map[string]string `json:"checks,omitempty"` // Check name to "ok" or the failure reason
+}
+
+// MessageResponse is returned by actions that only report success
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// RideCreatedResponse is returned by POST /ride
+type RideCreatedResponse struct {
+	Message string `json:"message"`
+	Ride    Ride   `json:"ride"`
+}
+
+// DeleteRideResponse is returned by DELETE /ride/:rideID
+type DeleteRideResponse struct {
+	Message              string `json:"message"`
+	ParticipantsNotified int    `json:"participants_notified"`
+	RideID               int    `json:"ride_id"`
+}
+
+// RideUpdatedResponse is returned by PATCH /ride/:rideID
+type RideUpdatedResponse struct {
+	Message              string       `json:"message"`
+	Ride                 Ride         `json:"ride"`
+	Changes              []RideChange `json:"changes"`
+	Significant          bool         `json:"significant"` // See RideChange.Significant
+	ParticipantsNotified int          `json:"participants_notified"`
+	ParticipantsDropped  int          `json:"participants_dropped"`
+	RequestsWithdrawn    int          `json:"requests_withdrawn"` // Requests of users busy on a new date
+}
+
+// LeadershipTransferResponse is returned when a leadership offer is made,
+// withdrawn or declined
+type LeadershipTransferResponse struct {
+	Message       string             `json:"message"`
+	Transfer      LeadershipTransfer `json:"transfer"`
+	ParticipantID uint               `json:"participant_id,omitempty"` // Participant offered the ride, when making the offer
+}
+
+// LeadershipTransferredResponse is returned by POST /ride/:rideID/accept-leadership
+type LeadershipTransferredResponse struct {
+	Message              string `json:"message"`
+	Ride                 Ride   `json:"ride"`
+	ParticipantsNotified int    `json:"participants_notified"`
+}
+
+// JoinRideResponse is returned by POST /ride/:rideID/join-ride
+type JoinRideResponse struct {
+	Message string `json:"message"`
+	RideID  int    `json:"ride_id"`
+}
+
+// CancelParticipationResponse is returned by DELETE /user/cancel-ride/:rideID
+type CancelParticipationResponse struct {
+	Message string `json:"message"`
+	Type    string `json:"type"` // "request_cancelled" or "participation_cancelled"
+}
+
+// ClearInvolvementResponse is returned by DELETE /user/clear-involvement/:date
+type ClearInvolvementResponse struct {
+	Message             string `json:"message"`
+	CancelledRequests   int    `json:"cancelled_requests"`
+	CancelledPrivileges int    `json:"cancelled_privileges"`
+	TotalCancelled      int    `json:"total_cancelled"`
+	Date                string `json:"date"`
+}
+
+// UnreadCountResponse is returned by GET /user/notifications/unread-count
+type UnreadCountResponse struct {
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// MarkAllReadResponse is returned by PUT /user/notifications/mark-all-read
+type MarkAllReadResponse struct {
+	Message      string `json:"message"`
+	UpdatedCount int64  `json:"updated_count"`
+}
+
+// UserBasicResponse is the part of another user's profile they share with the caller;
+// hidden fields are empty
+type UserBasicResponse struct {
+	Name   string `json:"name"`
+	Gender string `json:"gender"`
+}
+
+// RideLeaderResponse describes the leader of a ride
+type RideLeaderResponse struct {
+	Name        string `json:"name"`
+	Gender      string `json:"gender"`
+	PhoneNumber string `json:"phone"` // Only for the ride's participants, within CONTACT_WINDOW of departure
+}
+
+// JoinRequestResponse is a pending request as seen by the ride leader
+type JoinRequestResponse struct {
+	RequestID uint   `json:"request_id"`
+	Name      string `json:"name"`
+	Gender    string `json:"gender"`
+	Status    string `json:"status"`
+}
+
+// ParticipantResponse is a ride participant; Phone is only filled in for the
+// leader, within CONTACT_WINDOW of departure
+type ParticipantResponse struct {
+	ParticipantID uint      `json:"participant_id"`
+	Name          string    `json:"name"`
+	Gender        string    `json:"gender"`
+	JoinedAt      time.Time `json:"joined_at"`
+	Phone         string    `json:"phone,omitempty"`
+}
+
+// CooldownInfo tells a user when a rejected request can be sent again
+type CooldownInfo struct {
+	CanResend        bool `json:"can_resend"`
+	RemainingMinutes int  `json:"remaining_minutes"`
+}
+
+// SentRequestResponse is a join request as seen by the user who sent it
+type SentRequestResponse struct {
+	RequestID      uint          `json:"request_id"`
+	RideID         uint          `json:"ride_id"`
+	Origin         string        `json:"origin"`
+	Destination    string        `json:"destination"`
+	Date           string        `json:"date"`
+	Time           string        `json:"time"`
+	Price          float64       `json:"price"`
+	SeatsAvailable int           `json:"seats_available"`
+	TotalSeats     int           `json:"total_seats"`
+	Status         string        `json:"status"`
+	LeaderName     string        `json:"leader_name"`
+	RequestedAt    time.Time     `json:"requested_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	CanCancel      bool          `json:"can_cancel"`
+	CanJoin        bool          `json:"can_join"`
+	Cooldown       *CooldownInfo `json:"cooldown,omitempty"` // Only for revoked requests
+}
+
+// PrivilegeResponse is an approved request the user can use to join a ride
+type PrivilegeResponse struct {
+	RequestID      uint      `json:"request_id"`
+	RideID         uint      `json:"ride_id"`
+	Origin         string    `json:"origin"`
+	Destination    string    `json:"destination"`
+	Date           string    `json:"date"`
+	Time           string    `json:"time"`
+	Price          float64   `json:"price"`
+	SeatsAvailable int       `json:"seats_available"`
+	TotalSeats     int       `json:"total_seats"`
+	CanJoin        bool      `json:"can_join"`
+	ApprovedAt     time.Time `json:"approved_at"`
+}
+
+// NotificationResponse is a notification rendered for the reader's locale
+type NotificationResponse struct {
+	ID             uint                          `json:"id"`
+	Title          string                        `json:"title"`
+	Message        string                        `json:"message"`
+	Type           string                        `json:"type"`
+	TemplateKey    string                        `json:"template_key"`
+	Payload        NotificationPayload           `json:"payload"`
+	Actions        map[string]NotificationAction `json:"actions,omitempty"`
+	RideID         uint                          `json:"ride_id"`
+	OrganizationID *uint                         `json:"organization_id"` // nil for public pool rides
+	IsRead         bool                          `json:"is_read"`
+	CreatedAt      time.Time                     `json:"created_at"`
+	Origin         string                        `json:"origin"`
+	Destination    string                        `json:"destination"`
+	Date           string                        `json:"date"`
+	Time           string                        `json:"time"`
+	RideStatus     string                        `json:"ride_status"` // "active" or "deleted"
+}
+
+// DataExportResponse is returned by GET /user/export and GET /user/export/:exportID
+type DataExportResponse struct {
+	ID          uint       `json:"id"`
+	Status      string     `json:"status"` // "pending", "running", "ready", "failed", "downloaded" or "expired"
+	StatusURL   string     `json:"status_url"`
+	DownloadURL string     `json:"download_url,omitempty"` // Signed, works once until expires_at
+	Size        int        `json:"size,omitempty"`         // Archive size in bytes
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// FlaggedUserResponse is an entry of GET /admin/users/flagged
+type FlaggedUserResponse struct {
+	ID        uint       `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Flag      string     `json:"flag"` // "domain_not_allowed", "email_not_verified" or "denied"
+	FlaggedAt *time.Time `json:"flagged_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// UserOrganizationResponse is an entry of GET /user/organizations
+type UserOrganizationResponse struct {
+	ID       uint      `json:"id"`
+	Slug     string    `json:"slug"`
+	Name     string    `json:"name"`
+	Role     string    `json:"role"` // "member" or "admin"
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// OrganizationMemberResponse is a member as listed by GET /organization/:orgID/members
+type OrganizationMemberResponse struct {
+	ID       uint      `json:"id"` // Membership ID
+	UserID   uint      `json:"user_id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"` // "member" or "admin"
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// AccountDeletionResponse is returned by DELETE /user
+type AccountDeletionResponse struct {
+	Message             string    `json:"message"`
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"` // Until then POST /user/restore undoes the deletion
+}
+
+// PhoneVerificationResponse is returned by POST /user/phone/verify/start
+type PhoneVerificationResponse struct {
+	Message   string    `json:"message"`
+	Phone     string    `json:"phone"` // The number the code was sent to, in E.164
+	ExpiresAt time.Time `json:"expires_at"`
+}
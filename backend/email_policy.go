@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Admin overrides of the email policy for individual addresses
+const (
+	emailAccessAllow = "allow" // Can sign up whatever ALLOWED_EMAIL_DOMAINS says
+	emailAccessDeny  = "deny"  // Can't sign up, and an existing account is flagged
+)
+
+// Reasons an account breaks the email policy, stored in users.email_flag
+const (
+	emailFlagDomain     = "domain_not_allowed"
+	emailFlagUnverified = "email_not_verified"
+	emailFlagDenied     = "denied"
+)
+
+const (
+	// emailPolicyInterval is how often existing accounts are checked against the policy
+	emailPolicyInterval = 24 * time.Hour
+	// emailPolicyBatchSize is how many users are looked up in Firebase at once, its limit for GetUsers
+	emailPolicyBatchSize = 100
+)
+
+// EmailAccessOverride lets an admin allow or deny one email address regardless
+// of ALLOWED_EMAIL_DOMAINS
+type EmailAccessOverride struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Email     string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"email"` // Lower case
+	Access    string    `gorm:"type:varchar(10);not null" json:"access"`             // "allow" or "deny"
+	Reason    string    `gorm:"type:text" json:"reason,omitempty"`
+	CreatedBy string    `gorm:"type:varchar(100)" json:"created_by"` // Firebase UID of the admin
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Request body for PUT /admin/email-access
+type EmailAccessRequest struct {
+	Email  string `json:"email" binding:"required,email"`
+	Access string `json:"access" binding:"required"` // "allow" or "deny"
+	Reason string `json:"reason"`
+}
+
+// InitEmailPolicy starts the job that flags accounts breaking the email
+// policy, and runs it once right away so a changed ALLOWED_EMAIL_DOMAINS
+// takes effect on restart
+func InitEmailPolicy() {
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		flagNonCompliantUsers(backgroundCtx)
+	}()
+	startBackgroundJob("email_policy", emailPolicyInterval, flagNonCompliantUsers)
+}
+
+// emailDomainAllowed reports whether email is in ALLOWED_EMAIL_DOMAINS or a
+// subdomain of one. An empty list allows every domain.
+func emailDomainAllowed(email string) bool {
+	return len(config.AllowedEmailDomains) == 0 || emailInDomains(email, config.AllowedEmailDomains)
+}
+
+// emailInDomains reports whether email is in one of domains or a subdomain of one
+func emailInDomains(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range domains {
+		allowed = strings.ToLower(strings.TrimPrefix(allowed, "@"))
+		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// emailPolicyViolation returns why email may not be used for an account, or
+// "" if it may. An admin override decides before ALLOWED_EMAIL_DOMAINS does;
+// the address has to be verified either way, since the override only vouches
+// for its owner. Organization domains don't count, as they can only be ones
+// ALLOWED_EMAIL_DOMAINS already allows.
+func emailPolicyViolation(db *gorm.DB, email string, verified bool) (string, error) {
+	if config.RequireVerifiedEmail && !verified {
+		return emailFlagUnverified, nil
+	}
+
+	var override EmailAccessOverride
+	err := db.Where("email = ?", strings.ToLower(email)).First(&override).Error
+	switch {
+	case err == nil && override.Access == emailAccessDeny:
+		return emailFlagDenied, nil
+	case err == nil:
+		return "", nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return "", err
+	}
+
+	if emailDomainAllowed(email) {
+		return "", nil
+	}
+	return emailFlagDomain, nil
+}
+
+// flagNonCompliantUsers checks every account against the email policy and
+// sets or clears users.email_flag. Flagged users keep working; admins find
+// them through GET /admin/users/flagged. Whether an email is verified is
+// only known to Firebase, so without credentials that part is skipped.
+func flagNonCompliantUsers(ctx context.Context) {
+	var users []User
+	if err := DB.WithContext(ctx).Where("deleted_at IS NULL").Find(&users).Error; err != nil {
+		slog.ErrorContext(ctx, "email policy check failed", "error", err)
+		return
+	}
+
+	verified := firebaseEmailVerified(ctx, users)
+	flagged := 0
+	for _, user := range users {
+		isVerified, known := verified[user.FirebaseUID]
+		violation, err := emailPolicyViolation(DB.WithContext(ctx), user.Email, isVerified || !known)
+		if err != nil {
+			slog.ErrorContext(ctx, "email policy check failed", "user_id", user.ID, "error", err)
+			return
+		}
+		if violation != "" {
+			flagged++
+		}
+		if err := setEmailFlag(DB.WithContext(ctx), user, violation); err != nil {
+			slog.ErrorContext(ctx, "failed to flag user", "user_id", user.ID, "error", err)
+		}
+	}
+	emailPolicyFlagged.Set(float64(flagged))
+	slog.InfoContext(ctx, "email policy check finished", "users", len(users), "flagged", flagged)
+}
+
+// firebaseEmailVerified looks up whether each user's email is verified in
+// Firebase. Users missing from the result are unknown.
+func firebaseEmailVerified(ctx context.Context, users []User) map[string]bool {
+	verified := make(map[string]bool)
+	if authClient == nil || (config.FirebaseCredentials == "" && config.FirebaseCredentialsFile == "") {
+		return verified
+	}
+	for start := 0; start < len(users); start += emailPolicyBatchSize {
+		end := min(start+emailPolicyBatchSize, len(users))
+		ids := make([]auth.UserIdentifier, 0, end-start)
+		for _, user := range users[start:end] {
+			ids = append(ids, auth.UIDIdentifier{UID: user.FirebaseUID})
+		}
+		result, err := authClient.GetUsers(ctx, ids)
+		if err != nil {
+			slog.WarnContext(ctx, "could not look up email verification in Firebase", "error", err)
+			return verified
+		}
+		for _, record := range result.Users {
+			verified[record.UID] = record.EmailVerified
+		}
+	}
+	return verified
+}
+
+// setEmailFlag stores violation on user, keeping the original flag time while it stays flagged
+func setEmailFlag(db *gorm.DB, user User, violation string) error {
+	if user.EmailFlag == violation {
+		return nil
+	}
+	updates := map[string]interface{}{"email_flag": violation, "email_flagged_at": nil}
+	if violation != "" {
+		updates["email_flagged_at"] = time.Now()
+		if user.EmailFlaggedAt != nil {
+			updates["email_flagged_at"] = *user.EmailFlaggedAt
+		}
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, nil, auditEntry{Action: "email_flag_changed", TargetType: "user", TargetID: user.ID, SubjectUID: user.FirebaseUID,
+			Before: gin.H{"email_flag": user.EmailFlag}, After: gin.H{"email_flag": violation}})
+	})
+}
+
+// reflagEmail re-checks the account using email, if any, after its override changed
+func reflagEmail(c *gin.Context, email string) {
+	var user User
+	if err := dbFor(c).Where("LOWER(email) = ? AND deleted_at IS NULL", email).First(&user).Error; err != nil {
+		return
+	}
+	// Verification is only known to Firebase, so keep what the last full check found
+	violation, err := emailPolicyViolation(dbFor(c), user.Email, user.EmailFlag != emailFlagUnverified)
+	if err == nil {
+		err = setEmailFlag(dbFor(c), user, violation)
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to re-check email policy", "user_id", user.ID, "error", err)
+	}
+}
+
+// GET /admin/email-access - List email overrides (admins only)
+func GetEmailAccessOverrides(c *gin.Context) {
+	overrides := []EmailAccessOverride{}
+	if err := dbFor(c).Order("email").Find(&overrides).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_email_access_failed")
+		return
+	}
+	c.JSON(http.StatusOK, overrides)
+}
+
+// PUT /admin/email-access - Allow or deny an email address (admins only)
+func PutEmailAccessOverride(c *gin.Context) {
+	var req EmailAccessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_request_data")
+		return
+	}
+	if req.Access != emailAccessAllow && req.Access != emailAccessDeny {
+		respondError(c, http.StatusBadRequest, "error.invalid_email_access", MessageArgs{"access": req.Access})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	var before interface{}
+	override := EmailAccessOverride{Email: email}
+	err := dbFor(c).Where("email = ?", email).First(&override).Error
+	if err == nil {
+		before = override
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusInternalServerError, "error.update_email_access_failed")
+		return
+	}
+
+	override.Access = req.Access
+	override.Reason = req.Reason
+	override.CreatedBy = c.MustGet("uid").(string)
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&override).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "email_access_set", TargetType: "email_access", TargetID: override.ID, Before: before, After: override})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.update_email_access_failed")
+		return
+	}
+	reflagEmail(c, email)
+
+	c.JSON(http.StatusOK, override)
+}
+
+// DELETE /admin/email-access/:overrideID - Remove an email override (admins only)
+func DeleteEmailAccessOverride(c *gin.Context) {
+	overrideID, err := strconv.Atoi(c.Param("overrideID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_override_id")
+		return
+	}
+
+	var override EmailAccessOverride
+	if err := dbFor(c).First(&override, overrideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.email_access_not_found")
+		return
+	}
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&override).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "email_access_removed", TargetType: "email_access", TargetID: override.ID, Before: override})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.update_email_access_failed")
+		return
+	}
+	reflagEmail(c, override.Email)
+
+	c.JSON(http.StatusOK, MessageResponse{Message: tr(c, "message.email_access_removed", MessageArgs{"email": override.Email})})
+}
+
+// GET /admin/users/flagged - Accounts that break the email policy (admins only)
+func GetFlaggedUsers(c *gin.Context) {
+	var users []User
+	if err := dbFor(c).Where("email_flag <> '' AND deleted_at IS NULL").Order("email_flagged_at").Find(&users).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_flagged_users_failed")
+		return
+	}
+
+	response := make([]FlaggedUserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, FlaggedUserResponse{
+			ID:        user.ID,
+			Name:      user.Name,
+			Email:     user.Email,
+			Flag:      user.EmailFlag,
+			FlaggedAt: user.EmailFlaggedAt,
+			CreatedAt: user.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
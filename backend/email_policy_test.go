@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestEmailPolicyViolation(t *testing.T) {
+	setupTestDatabase(t, "--allowed-email-domains", "iitd.ac.in")
+	for _, override := range []EmailAccessOverride{
+		{Email: "banned@iitd.ac.in", Access: emailAccessDeny},
+		{Email: "guest@gmail.com", Access: emailAccessAllow},
+	} {
+		if err := DB.Create(&override).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Organization domains are checked against ALLOWED_EMAIL_DOMAINS when
+	// saved; one that slipped through must not widen the policy
+	if err := DB.Create(&Organization{Slug: "partner", Name: "Partner", EmailDomains: []string{"partner.org"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		verified bool
+		want     string
+	}{
+		{"allowed domain", "rider@iitd.ac.in", true, ""},
+		{"subdomain", "rider@cse.iitd.ac.in", true, ""},
+		{"domain in upper case", "Rider@IITD.AC.IN", true, ""},
+		{"other domain", "rider@gmail.com", true, emailFlagDomain},
+		{"lookalike domain", "rider@notiitd.ac.in", true, emailFlagDomain},
+		{"organization domain", "rider@partner.org", true, emailFlagDomain},
+		{"unverified", "rider@iitd.ac.in", false, emailFlagUnverified},
+		{"denied", "banned@iitd.ac.in", true, emailFlagDenied},
+		{"denied in upper case", "Banned@iitd.ac.in", true, emailFlagDenied},
+		{"allowed by override", "guest@gmail.com", true, ""},
+		{"allowed by override but unverified", "guest@gmail.com", false, emailFlagUnverified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := emailPolicyViolation(DB, tt.email, tt.verified)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("emailPolicyViolation(%q, %v) = %q, want %q", tt.email, tt.verified, got, tt.want)
+			}
+		})
+	}
+}
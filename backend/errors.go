@@ -0,0 +1,170 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIError is the single error shape returned by every route
+type APIError struct {
+	Code      string                 `json:"code"`              // Machine-readable, e.g. "RIDE_FULL"
+	Message   string                 `json:"message"`           // Localized, human-readable
+	Details   map[string]interface{} `json:"details,omitempty"` // Extra context, e.g. involvement counts
+	RequestID string                 `json:"request_id"`
+}
+
+// errorCodes maps catalog message keys to machine-readable error codes.
+// Keys not listed here fall back to the generic code for the HTTP status.
+var errorCodes = map[string]string{
+	"error.user_not_found":                  "USER_NOT_FOUND",
+	"error.ride_not_found":                  "RIDE_NOT_FOUND",
+	"error.ride_leader_not_found":           "LEADER_NOT_FOUND",
+	"error.leader_not_found":                "LEADER_NOT_FOUND",
+	"error.not_ride_leader":                 "NOT_RIDE_LEADER",
+	"error.involvement_conflict":            "INVOLVEMENT_CONFLICT",
+	"error.request_revoked_cooldown":        "COOLDOWN_ACTIVE",
+	"error.ride_full":                       "RIDE_FULL",
+	"error.request_already_pending":         "REQUEST_ALREADY_PENDING",
+	"error.already_approved":                "REQUEST_ALREADY_APPROVED",
+	"error.already_participant":             "ALREADY_PARTICIPANT",
+	"error.no_privilege":                    "NO_PRIVILEGE",
+	"error.join_request_not_found":          "REQUEST_NOT_FOUND",
+	"error.no_pending_request":              "REQUEST_NOT_FOUND",
+	"error.participant_not_found":           "PARTICIPANT_NOT_FOUND",
+	"error.notification_not_found":          "NOTIFICATION_NOT_FOUND",
+	"error.invalid_phone":                   "INVALID_PHONE",
+	"error.phone_not_verified":              "PHONE_NOT_VERIFIED",
+	"error.phone_verification_not_found":    "VERIFICATION_NOT_FOUND",
+	"error.phone_verification_expired":      "VERIFICATION_EXPIRED",
+	"error.phone_verification_locked":       "VERIFICATION_LOCKED",
+	"error.phone_verification_code_invalid": "VERIFICATION_CODE_INVALID",
+	"error.sms_send_failed":                 "SMS_SEND_FAILED",
+	"error.email_missing":                   "EMAIL_MISSING",
+	"error.email_not_verified":              "EMAIL_NOT_VERIFIED",
+	"error.email_not_allowed":               "EMAIL_NOT_ALLOWED",
+	"error.email_access_not_found":          "EMAIL_ACCESS_NOT_FOUND",
+	"error.organization_not_found":          "ORGANIZATION_NOT_FOUND",
+	"error.membership_not_found":            "MEMBERSHIP_NOT_FOUND",
+	"error.not_organization_member":         "NOT_ORGANIZATION_MEMBER",
+	"error.organization_admin_required":     "ORGANIZATION_ADMIN_REQUIRED",
+	"error.organization_slug_taken":         "ORGANIZATION_SLUG_TAKEN",
+	"error.location_not_allowed":            "LOCATION_NOT_ALLOWED",
+	"error.no_deletion_scheduled":           "NO_DELETION_SCHEDULED",
+	"error.data_export_not_found":           "EXPORT_NOT_FOUND",
+	"error.data_export_link_invalid":        "EXPORT_LINK_INVALID",
+	"error.data_export_link_expired":        "EXPORT_LINK_EXPIRED",
+	"error.data_export_unavailable":         "EXPORT_UNAVAILABLE",
+	"error.no_involvement_with_ride":        "NO_INVOLVEMENT",
+	"error.invalid_token":                   "INVALID_TOKEN",
+	"error.admin_required":                  "ADMIN_REQUIRED",
+	"error.rate_limited":                    "RATE_LIMITED",
+	"error.idempotency_key_mismatch":        "IDEMPOTENCY_KEY_MISMATCH",
+	"error.idempotency_in_progress":         "IDEMPOTENCY_IN_PROGRESS",
+	"error.unsupported_locale":              "UNSUPPORTED_LOCALE",
+	"error.seats_below_filled":              "SEATS_BELOW_FILLED",
+	"error.participants_date_conflict":      "PARTICIPANTS_DATE_CONFLICT",
+	"error.invalid_seats":                   "INVALID_SEATS",
+	"error.invalid_price":                   "INVALID_PRICE",
+	"error.ride_date_past":                  "RIDE_DATE_PAST",
+	"error.update_ride_failed":              "UPDATE_RIDE_FAILED",
+	"error.leadership_transfer_pending":     "LEADERSHIP_TRANSFER_PENDING",
+	"error.no_leadership_offer":             "NO_LEADERSHIP_OFFER",
+	"error.leadership_involvement_conflict": "LEADERSHIP_INVOLVEMENT_CONFLICT",
+	"error.leadership_transfer_failed":      "LEADERSHIP_TRANSFER_FAILED",
+	"error.invalid_date_format":             "INVALID_DATE",
+	"error.invalid_time_format":             "INVALID_TIME",
+	"error.invalid_input":                   "VALIDATION_FAILED",
+	"error.invalid_request_data":            "VALIDATION_FAILED",
+	"error.invalid_email_access":            "VALIDATION_FAILED",
+	"error.invalid_organization_slug":       "VALIDATION_FAILED",
+	"error.invalid_organization_role":       "VALIDATION_FAILED",
+	"error.invalid_email_domain":            "VALIDATION_FAILED",
+	"error.email_domain_not_allowed":        "VALIDATION_FAILED",
+	"error.public_email_domain":             "VALIDATION_FAILED",
+	"error.invalid_cooldown":                "VALIDATION_FAILED",
+	"error.invalid_visibility":              "VALIDATION_FAILED",
+	"error.idempotency_key_too_long":        "VALIDATION_FAILED",
+	"error.request_validation_failed":       "VALIDATION_FAILED",
+	"error.auth_header_missing":             "UNAUTHENTICATED",
+	"error.invalid_auth_header":             "UNAUTHENTICATED",
+	"error.unauthorized":                    "UNAUTHENTICATED",
+	"error.not_authenticated":               "UNAUTHENTICATED",
+	"error.fetch_updated_user_failed":       "INTERNAL_ERROR",
+}
+
+// statusCodes are the generic error codes used when a message key has no specific code
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          "INVALID_REQUEST",
+	http.StatusUnauthorized:        "UNAUTHENTICATED",
+	http.StatusForbidden:           "FORBIDDEN",
+	http.StatusNotFound:            "NOT_FOUND",
+	http.StatusConflict:            "CONFLICT",
+	http.StatusGone:                "GONE",
+	http.StatusUnprocessableEntity: "UNPROCESSABLE",
+	http.StatusTooManyRequests:     "RATE_LIMITED",
+}
+
+// errorCodeFor returns the machine-readable code for a message key and status
+func errorCodeFor(key string, status int) string {
+	if code, ok := errorCodes[key]; ok {
+		return code
+	}
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	return "INTERNAL_ERROR"
+}
+
+// respondError localizes key and aborts the request with the standard error envelope
+func respondError(c *gin.Context, status int, key string, args ...MessageArgs) {
+	writeError(c, status, errorCodeFor(key, status), tr(c, key, args...), nil)
+}
+
+// respondErrorWithDetails is respondError with extra machine-readable context
+func respondErrorWithDetails(c *gin.Context, status int, key string, details gin.H, args ...MessageArgs) {
+	writeError(c, status, errorCodeFor(key, status), tr(c, key, args...), details)
+}
+
+// writeError aborts the request with an already-localized error. On deprecated
+// unversioned routes the legacy "error" string and detail fields are also set
+// at the top level so older clients keep working.
+func writeError(c *gin.Context, status int, code, message string, details gin.H) {
+	apiErr := APIError{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: requestIDFor(c),
+	}
+
+	if !c.GetBool("legacy_api") {
+		c.AbortWithStatusJSON(status, apiErr)
+		return
+	}
+
+	body := gin.H{
+		"code":       apiErr.Code,
+		"message":    apiErr.Message,
+		"request_id": apiErr.RequestID,
+		"error":      apiErr.Message,
+	}
+	if len(details) > 0 {
+		body["details"] = details
+		for field, value := range details {
+			body[field] = value
+		}
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+// DeprecatedAliasMiddleware marks the unversioned routes kept for older clients
+// and points them at the /v1 equivalent
+func DeprecatedAliasMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("legacy_api", true)
+		c.Header("Deprecation", "true")
+		c.Header("Link", "</v1"+strings.TrimSuffix(c.Request.URL.Path, "/")+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
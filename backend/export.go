@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ExportedUser is a user profile including the fields hidden from API responses
+type ExportedUser struct {
+	ID              uint       `json:"id"`
+	FirebaseUID     string     `json:"firebase_uid"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Phone           string     `json:"phone"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty"`
+	Gender          string     `json:"gender,omitempty"`
+	Locale          string     `json:"locale"`
+	IsAdmin         bool       `json:"is_admin"`
+	Visibility      gin.H      `json:"visibility"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// ExportedRequest is a join request as stored
+type ExportedRequest struct {
+	ID        uint       `json:"id"`
+	RideID    uint       `json:"ride_id"`
+	UserUID   string     `json:"user_uid"`
+	Status    string     `json:"status"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ExportedParticipant is a seat taken in a ride
+type ExportedParticipant struct {
+	ID       uint      `json:"id"`
+	RideID   uint      `json:"ride_id"`
+	UserUID  string    `json:"user_uid"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// ExportedNotification is a notification rendered in its recipient's language
+type ExportedNotification struct {
+	ID          uint      `json:"id"`
+	UserUID     string    `json:"user_uid"`
+	RideID      uint      `json:"ride_id"`
+	Type        string    `json:"type"`
+	TemplateKey string    `json:"template_key,omitempty"`
+	Title       string    `json:"title"`
+	Message     string    `json:"message"`
+	IsRead      bool      `json:"is_read"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UserExport is everything stored about one user
+type UserExport struct {
+	ExportedAt     time.Time              `json:"exported_at"`
+	User           ExportedUser           `json:"user"`
+	RidesPosted    []Ride                 `json:"rides_posted"`
+	Requests       []ExportedRequest      `json:"requests"`
+	Participations []ExportedParticipant  `json:"participations"`
+	Notifications  []ExportedNotification `json:"notifications"`
+	Memberships    []Membership           `json:"memberships"`
+	AuditEvents    []AuditEvent           `json:"audit_events"`
+}
+
+// RideExport is a ride with its leader and everyone involved in it
+type RideExport struct {
+	ExportedAt    time.Time              `json:"exported_at"`
+	Ride          Ride                   `json:"ride"`
+	Leader        *ExportedUser          `json:"leader"`
+	Requests      []ExportedRequest      `json:"requests"`
+	Participants  []ExportedParticipant  `json:"participants"`
+	Notifications []ExportedNotification `json:"notifications"`
+	AuditEvents   []AuditEvent           `json:"audit_events"`
+}
+
+// findUserByRef looks a user up by database ID, email or Firebase UID
+func findUserByRef(db *gorm.DB, ref string) (*User, error) {
+	var user User
+	var err error
+	if id, convErr := strconv.ParseUint(ref, 10, 64); convErr == nil {
+		err = db.First(&user, "id = ?", id).Error
+	} else if strings.Contains(ref, "@") {
+		err = db.First(&user, "email = ?", ref).Error
+	} else {
+		err = db.First(&user, "firebase_uid = ?", ref).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("user %q: %v", ref, err)
+	}
+	return &user, nil
+}
+
+// buildUserExport collects the profile, rides, requests, seats, notifications
+// and audit trail of user
+func buildUserExport(db *gorm.DB, user User) (*UserExport, error) {
+	export := &UserExport{ExportedAt: time.Now().UTC(), User: exportUser(user)}
+
+	if err := db.Where("leader_id = ?", user.ID).Order("date, time").Find(&export.RidesPosted).Error; err != nil {
+		return nil, fmt.Errorf("error reading rides: %v", err)
+	}
+
+	var requests []Request
+	if err := db.Where("user_id = ?", user.FirebaseUID).Order("created_at").Find(&requests).Error; err != nil {
+		return nil, fmt.Errorf("error reading requests: %v", err)
+	}
+	export.Requests = exportRequests(requests)
+
+	var participants []Participant
+	if err := db.Where("user_id = ?", user.FirebaseUID).Order("joined_at").Find(&participants).Error; err != nil {
+		return nil, fmt.Errorf("error reading participations: %v", err)
+	}
+	export.Participations = exportParticipants(participants)
+
+	var notifications []Notification
+	if err := db.Where("user_id = ?", user.FirebaseUID).Order("created_at").Find(&notifications).Error; err != nil {
+		return nil, fmt.Errorf("error reading notifications: %v", err)
+	}
+	export.Notifications = exportNotifications(notifications, map[string]string{user.FirebaseUID: user.Locale})
+
+	if err := db.Where("user_uid = ?", user.FirebaseUID).Order("created_at").Find(&export.Memberships).Error; err != nil {
+		return nil, fmt.Errorf("error reading memberships: %v", err)
+	}
+
+	if err := db.Where("actor_uid = ? OR subject_uid = ?", user.FirebaseUID, user.FirebaseUID).
+		Order("created_at").Find(&export.AuditEvents).Error; err != nil {
+		return nil, fmt.Errorf("error reading audit events: %v", err)
+	}
+	redactAuditEvents(export.AuditEvents, user)
+	return export, nil
+}
+
+// buildRideExport collects a ride with its leader, requests, participants,
+// notifications and audit trail
+func buildRideExport(db *gorm.DB, rideID uint) (*RideExport, error) {
+	export := &RideExport{ExportedAt: time.Now().UTC()}
+	if err := db.First(&export.Ride, "id = ?", rideID).Error; err != nil {
+		return nil, fmt.Errorf("ride %d: %v", rideID, err)
+	}
+
+	var leader User
+	if err := db.First(&leader, "id = ?", export.Ride.LeaderID).Error; err == nil {
+		exported := exportUser(leader)
+		export.Leader = &exported
+	}
+
+	var requests []Request
+	if err := db.Where("ride_id = ?", rideID).Order("created_at").Find(&requests).Error; err != nil {
+		return nil, fmt.Errorf("error reading requests: %v", err)
+	}
+	export.Requests = exportRequests(requests)
+
+	var participants []Participant
+	if err := db.Where("ride_id = ?", rideID).Order("joined_at").Find(&participants).Error; err != nil {
+		return nil, fmt.Errorf("error reading participants: %v", err)
+	}
+	export.Participants = exportParticipants(participants)
+
+	var notifications []Notification
+	if err := db.Where("ride_id = ?", rideID).Order("created_at").Find(&notifications).Error; err != nil {
+		return nil, fmt.Errorf("error reading notifications: %v", err)
+	}
+	recipients := make([]string, 0, len(notifications))
+	for _, n := range notifications {
+		recipients = append(recipients, n.UserID)
+	}
+	var users []User
+	if err := db.Where("firebase_uid IN ?", recipients).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("error reading notification recipients: %v", err)
+	}
+	locales := make(map[string]string, len(users))
+	for _, u := range users {
+		locales[u.FirebaseUID] = u.Locale
+	}
+	export.Notifications = exportNotifications(notifications, locales)
+
+	if err := db.Where("ride_id = ?", rideID).Order("created_at").Find(&export.AuditEvents).Error; err != nil {
+		return nil, fmt.Errorf("error reading audit events: %v", err)
+	}
+	return export, nil
+}
+
+// redactedValue stands in for other users' identifiers and profiles in a
+// user's export
+const redactedValue = "redacted"
+
+// redactAuditEvents hides everyone but user in events going into user's
+// export: the actor and subject UIDs, and in the snapshots any "*_uid" field
+// and other users' profiles
+func redactAuditEvents(events []AuditEvent, user User) {
+	for i := range events {
+		event := &events[i]
+		if event.ActorUID != user.FirebaseUID && event.ActorUID != systemActor {
+			event.ActorUID = redactedValue
+		}
+		if event.SubjectUID != "" && event.SubjectUID != user.FirebaseUID {
+			event.SubjectUID = redactedValue
+		}
+		event.Before = redactSnapshot(event.Before, user)
+		event.After = redactSnapshot(event.After, user)
+	}
+}
+
+// redactSnapshot redacts a JSON snapshot of an audit event. A snapshot that
+// can't be parsed is dropped rather than exported as is.
+func redactSnapshot(snapshot string, user User) string {
+	if snapshot == "" {
+		return snapshot
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(snapshot), &value); err != nil {
+		return ""
+	}
+	data, err := json.Marshal(redactValue(value, user))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func redactValue(value interface{}, user User) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i], user)
+		}
+	case map[string]interface{}:
+		// User snapshots are the only ones with an email
+		if _, ok := v["email"]; ok {
+			if id, _ := v["id"].(float64); uint(id) != user.ID {
+				return redactedValue
+			}
+		}
+		for key, field := range v {
+			if uid, ok := field.(string); ok && strings.HasSuffix(key, "_uid") && uid != user.FirebaseUID {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(field, user)
+			}
+		}
+	}
+	return value
+}
+
+func exportUser(u User) ExportedUser {
+	return ExportedUser{
+		ID:              u.ID,
+		FirebaseUID:     u.FirebaseUID,
+		Name:            u.Name,
+		Email:           u.Email,
+		Phone:           u.Phone,
+		PhoneVerifiedAt: u.PhoneVerifiedAt,
+		Gender:          u.Gender,
+		Locale:          u.Locale,
+		IsAdmin:         u.IsAdmin,
+		Visibility:      gin.H{"name": u.NameVisibility, "gender": u.GenderVisibility, "phone": u.PhoneVisibility},
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+	}
+}
+
+func exportRequests(requests []Request) []ExportedRequest {
+	exported := make([]ExportedRequest, 0, len(requests))
+	for _, r := range requests {
+		e := ExportedRequest{
+			ID:        r.ID,
+			RideID:    r.RideID,
+			UserUID:   r.UserID,
+			Status:    r.Status,
+			CreatedAt: r.CreatedAt,
+			UpdatedAt: r.UpdatedAt,
+		}
+		if !r.RevokedAt.IsZero() {
+			revokedAt := r.RevokedAt
+			e.RevokedAt = &revokedAt
+		}
+		exported = append(exported, e)
+	}
+	return exported
+}
+
+func exportParticipants(participants []Participant) []ExportedParticipant {
+	exported := make([]ExportedParticipant, 0, len(participants))
+	for _, p := range participants {
+		exported = append(exported, ExportedParticipant{ID: p.ID, RideID: p.RideID, UserUID: p.UserID, JoinedAt: p.JoinedAt})
+	}
+	return exported
+}
+
+// exportNotifications renders each notification in its recipient's locale (from locales, by Firebase UID)
+func exportNotifications(notifications []Notification, locales map[string]string) []ExportedNotification {
+	exported := make([]ExportedNotification, 0, len(notifications))
+	for _, n := range notifications {
+		locale := locales[n.UserID]
+		if !isSupportedLocale(locale) {
+			locale = defaultLocale
+		}
+		title, message := n.render(locale, n.decodePayload())
+		exported = append(exported, ExportedNotification{
+			ID:          n.ID,
+			UserUID:     n.UserID,
+			RideID:      n.RideID,
+			Type:        n.Type,
+			TemplateKey: n.TemplateKey,
+			Title:       title,
+			Message:     message,
+			IsRead:      n.IsRead,
+			CreatedAt:   n.CreatedAt,
+		})
+	}
+	return exported
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// defaultLocale is used when neither the user profile nor Accept-Language picks a supported locale
+const defaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// MessageArgs holds named placeholder values for a catalog message, e.g. {"origin": "Campus"}
+type MessageArgs map[string]interface{}
+
+// catalogs maps locale -> message key -> message text. Plural messages are
+// stored as "<key>.one" and "<key>.other".
+var catalogs = loadCatalogs()
+
+// supportedLocales lists locales in the order used for Accept-Language matching (first is the fallback)
+var supportedLocales = []language.Tag{language.English, language.Hindi}
+
+var localeMatcher = language.NewMatcher(supportedLocales)
+
+// loadCatalogs reads every embedded locales/<locale>.json file
+func loadCatalogs() map[string]map[string]string {
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		log.Fatalf("Failed to read message catalogs: %v", err)
+	}
+
+	result := make(map[string]map[string]string)
+	for _, entry := range entries {
+		data, err := localeFiles.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			log.Fatalf("Failed to read message catalog %s: %v", entry.Name(), err)
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			log.Fatalf("Failed to parse message catalog %s: %v", entry.Name(), err)
+		}
+		result[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+	return result
+}
+
+// isSupportedLocale reports whether a catalog exists for locale
+func isSupportedLocale(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// matchAcceptLanguage picks the best supported locale for an Accept-Language header
+func matchAcceptLanguage(header string) string {
+	if header == "" {
+		return defaultLocale
+	}
+	tags, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(tags) == 0 {
+		return defaultLocale
+	}
+	_, index, confidence := localeMatcher.Match(tags...)
+	if confidence == language.No {
+		return defaultLocale
+	}
+	base, _ := supportedLocales[index].Base()
+	return base.String()
+}
+
+// requestLocale resolves the locale for the current request: the user's saved
+// locale first, then the Accept-Language header. The result is cached on the context.
+func requestLocale(c *gin.Context) string {
+	if cached, exists := c.Get("locale"); exists {
+		return cached.(string)
+	}
+
+	locale := ""
+	if user, exists := c.Get("user"); exists {
+		locale = user.(*User).Locale
+	} else if uid, exists := c.Get("uid"); exists && DB != nil {
+		var saved []string
+		if err := dbFor(c).Model(&User{}).Where("firebase_uid = ?", uid).Limit(1).Pluck("locale", &saved).Error; err == nil && len(saved) > 0 {
+			locale = saved[0]
+		}
+	}
+	if !isSupportedLocale(locale) {
+		locale = matchAcceptLanguage(c.GetHeader("Accept-Language"))
+	}
+
+	c.Set("locale", locale)
+	return locale
+}
+
+// localize looks up key in the locale's catalog (falling back to English) and fills in args
+func localize(locale, key string, args MessageArgs) string {
+	message, ok := catalogs[locale][key]
+	if !ok {
+		message, ok = catalogs[defaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+
+	if len(args) == 0 {
+		return message
+	}
+	// One pass, so a value containing "{name}" is never substituted again
+	pairs := make([]string, 0, 2*len(args))
+	for name, value := range args {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(message)
+}
+
+// localizePlural picks the plural form of key for count and fills in args ({count} is always set)
+func localizePlural(locale, key string, count int, args MessageArgs) string {
+	merged := MessageArgs{"count": count}
+	for name, value := range args {
+		merged[name] = value
+	}
+	return localize(locale, key+"."+pluralCategory(locale, count), merged)
+}
+
+// pluralCategory returns the CLDR plural category ("one" or "other") for count in locale
+func pluralCategory(locale string, count int) string {
+	switch locale {
+	case "hi":
+		// Hindi treats both 0 and 1 as singular
+		if count == 0 || count == 1 {
+			return "one"
+		}
+	default:
+		if count == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
+
+// tr localizes key for the locale of the current request
+func tr(c *gin.Context, key string, args ...MessageArgs) string {
+	var merged MessageArgs
+	if len(args) > 0 {
+		merged = args[0]
+	}
+	return localize(requestLocale(c), key, merged)
+}
+
+// trPlural localizes a plural message for the locale of the current request
+func trPlural(c *gin.Context, key string, count int, args ...MessageArgs) string {
+	var merged MessageArgs
+	if len(args) > 0 {
+		merged = args[0]
+	}
+	return localizePlural(requestLocale(c), key, count, merged)
+}
+
+// formatRideDateTime renders a ride's "2006-01-02" date and "15:04" time in the
+// conventions of locale, returning the raw values if they cannot be parsed
+func formatRideDateTime(locale, date, clock string) string {
+	parsed, err := time.Parse("2006-01-02 15:04", date+" "+clock)
+	if err != nil {
+		return strings.TrimSpace(date + " " + clock)
+	}
+
+	weekday := localize(locale, "date.weekday."+strings.ToLower(parsed.Weekday().String()[:3]), nil)
+	month := localize(locale, fmt.Sprintf("date.month.%d", int(parsed.Month())), nil)
+
+	hour := parsed.Hour() % 12
+	if hour == 0 {
+		hour = 12
+	}
+	meridiem := localize(locale, "date.am", nil)
+	if parsed.Hour() >= 12 {
+		meridiem = localize(locale, "date.pm", nil)
+	}
+
+	return localize(locale, "date.ride_datetime", MessageArgs{
+		"weekday":  weekday,
+		"day":      parsed.Day(),
+		"month":    month,
+		"year":     parsed.Year(),
+		"hour":     hour,
+		"minute":   fmt.Sprintf("%02d", parsed.Minute()),
+		"meridiem": meridiem,
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyRecord stores the first response to a state-changing request so
+// retries carrying the same Idempotency-Key get the same answer
+type IdempotencyRecord struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserUID     string `gorm:"type:varchar(100);not null;uniqueIndex:idx_idempotency_user_key"`
+	Key         string `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key"`
+	RequestHash string `gorm:"type:varchar(64);not null"` // SHA-256 of method, path, query string and body
+	StatusCode  int    `gorm:"not null;default:0"`        // 0 while the first request is still running
+	ContentType string `gorm:"type:varchar(100)"`
+	Body        []byte
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
+
+// idempotencyTTL is how long responses are kept for replay, set by IDEMPOTENCY_TTL (e.g. "12h")
+var idempotencyTTL time.Duration
+
+// maxIdempotencyKeyLength matches the Key column
+const maxIdempotencyKeyLength = 255
+
+// InitIdempotency reads IDEMPOTENCY_TTL and starts pruning expired records
+func InitIdempotency() error {
+	idempotencyTTL = config.IdempotencyTTL
+
+	startBackgroundJob("idempotency_prune", time.Hour, func(ctx context.Context) {
+		if err := DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&IdempotencyRecord{}).Error; err != nil {
+			slog.Error("failed to prune idempotency records", "error", err)
+		}
+	})
+	return nil
+}
+
+// responseRecorder copies everything written to the client so it can be stored for replay
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware replays the stored response for POST/PUT/PATCH/DELETE
+// requests that repeat an Idempotency-Key. Must run after FirebaseAuthMiddleware
+// and the route's own middleware, whose refusals are not stored.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			key = ""
+		}
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			respondError(c, http.StatusBadRequest, "error.idempotency_key_too_long")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "error.invalid_request_data")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "?" + c.Request.URL.RawQuery + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+		userUID := c.MustGet("uid").(string)
+
+		// A key whose record has expired can be reused as if it were new
+		if err := dbFor(c).Where("user_uid = ? AND key = ? AND expires_at < ?", userUID, key, time.Now()).
+			Delete(&IdempotencyRecord{}).Error; err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to clear expired idempotency key", "uid", userUID, "error", err)
+		}
+
+		// Claim the key; if another request already holds it, answer from its record
+		record := IdempotencyRecord{
+			UserUID:     userUID,
+			Key:         key,
+			RequestHash: requestHash,
+			ExpiresAt:   time.Now().Add(idempotencyTTL),
+			CreatedAt:   time.Now(),
+		}
+		result := dbFor(c).Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to store idempotency key", "uid", userUID, "error", result.Error)
+			c.Next()
+			return
+		}
+
+		if result.RowsAffected == 0 {
+			var existing IdempotencyRecord
+			if err := dbFor(c).Where("user_uid = ? AND key = ?", userUID, key).First(&existing).Error; err != nil {
+				respondError(c, http.StatusInternalServerError, "error.database")
+				return
+			}
+
+			if existing.RequestHash != requestHash {
+				respondError(c, http.StatusUnprocessableEntity, "error.idempotency_key_mismatch")
+				return
+			}
+
+			if existing.StatusCode == 0 {
+				respondError(c, http.StatusConflict, "error.idempotency_in_progress")
+				return
+			}
+
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+			c.Abort()
+			return
+		}
+
+		// A panicking handler would leave the key "in progress" until it expires
+		defer func() {
+			if r := recover(); r != nil {
+				dbFor(c).Delete(&record)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			// Server errors and rate limits are not cached so the client can retry with the same key
+			dbFor(c).Delete(&record)
+			return
+		}
+
+		if err := dbFor(c).Model(&record).Updates(map[string]interface{}{
+			"status_code":  status,
+			"content_type": recorder.Header().Get("Content-Type"),
+			"body":         recorder.body.Bytes(),
+		}).Error; err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to store idempotent response", "uid", userUID, "error", err)
+		}
+	}
+}
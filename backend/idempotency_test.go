@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	setupTestDatabase(t)
+	gin.SetMode(gin.TestMode)
+	savedTTL := idempotencyTTL
+	defer func() { idempotencyTTL = savedTTL }()
+	idempotencyTTL = time.Hour
+
+	tests := []struct {
+		name         string
+		key2, body2  string // The retry; the first request always sends key "k" and body "{}"
+		nested       bool   // Send the retry while the first request is still running
+		wantStatus   int
+		wantCode     string
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{name: "replay", key2: "k", body2: "{}",
+			wantStatus: http.StatusCreated, wantReplayed: true, wantCalls: 1},
+		{name: "different key", key2: "other", body2: "{}",
+			wantStatus: http.StatusCreated, wantCalls: 2},
+		{name: "body mismatch", key2: "k", body2: `{"seats":2}`,
+			wantStatus: http.StatusUnprocessableEntity, wantCode: "IDEMPOTENCY_KEY_MISMATCH", wantCalls: 1},
+		{name: "in progress", key2: "k", body2: "{}", nested: true,
+			wantStatus: http.StatusConflict, wantCode: "IDEMPOTENCY_IN_PROGRESS", wantCalls: 1},
+		{name: "key too long", key2: strings.Repeat("k", maxIdempotencyKeyLength+1), body2: "{}",
+			wantStatus: http.StatusBadRequest, wantCode: "VALIDATION_FAILED", wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uid := "idempotency " + tt.name // Keys are scoped per user
+			engine := gin.New()
+			send := func(key, body string) *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPost, "/rides", strings.NewReader(body))
+				req.Header.Set("Idempotency-Key", key)
+				engine.ServeHTTP(w, req)
+				return w
+			}
+
+			calls := 0
+			var retry *httptest.ResponseRecorder
+			engine.POST("/rides",
+				func(c *gin.Context) { c.Set("uid", uid) },
+				IdempotencyMiddleware(),
+				func(c *gin.Context) {
+					calls++
+					if tt.nested && calls == 1 {
+						retry = send(tt.key2, tt.body2)
+					}
+					c.JSON(http.StatusCreated, gin.H{"call": calls})
+				})
+
+			first := send("k", "{}")
+			if first.Code != http.StatusCreated {
+				t.Fatalf("first request: status = %d: %s", first.Code, first.Body.String())
+			}
+			if !tt.nested {
+				retry = send(tt.key2, tt.body2)
+			}
+
+			if retry.Code != tt.wantStatus {
+				t.Fatalf("retry: status = %d, want %d: %s", retry.Code, tt.wantStatus, retry.Body.String())
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if replayed := retry.Header().Get("Idempotent-Replayed") == "true"; replayed != tt.wantReplayed {
+				t.Errorf("Idempotent-Replayed = %v, want %v", replayed, tt.wantReplayed)
+			}
+			if tt.wantReplayed && retry.Body.String() != first.Body.String() {
+				t.Errorf("replayed body = %s, want %s", retry.Body.String(), first.Body.String())
+			}
+			if tt.wantCode != "" {
+				var body APIError
+				if err := json.Unmarshal(retry.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				if body.Code != tt.wantCode {
+					t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+				}
+			}
+		})
+	}
+}
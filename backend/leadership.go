@@ -0,0 +1,388 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LeadershipTransfer is a leader's offer to hand their ride over to one of its
+// participants, who becomes the leader by accepting it. A ride has at most one
+// pending offer.
+type LeadershipTransfer struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	RideID            uint       `gorm:"not null;index" json:"ride_id"`
+	FromUserID        uint       `gorm:"not null" json:"from_user_id"`                              // Leader making the offer
+	ToUserUID         string     `gorm:"type:varchar(100);not null;index" json:"-"`                 // Participant offered the ride
+	StayAsParticipant bool       `gorm:"not null;default:false" json:"stay_as_participant"`         // Leader takes the new leader's seat instead of leaving
+	Status            string     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"` // "pending", "accepted", "declined" or "withdrawn"
+	CreatedAt         time.Time  `json:"created_at"`
+	RespondedAt       *time.Time `json:"responded_at"`
+}
+
+// errLeadershipOfferGone means the offer was answered or withdrawn while being accepted
+var errLeadershipOfferGone = errors.New("leadership offer is no longer pending")
+
+// POST /ride/:rideID/transfer-leadership/:participantID[?stay_as_participant=true] - Leader offers
+// their ride to a participant. Nothing changes until the participant accepts.
+func TransferRideLeadership(c *gin.Context) {
+	rideID, err := strconv.Atoi(c.Param("rideID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
+		return
+	}
+	participantID, err := strconv.Atoi(c.Param("participantID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_participant_id")
+		return
+	}
+	stay := false
+	if value := c.Query("stay_as_participant"); value != "" {
+		if stay, err = strconv.ParseBool(value); err != nil {
+			respondError(c, http.StatusBadRequest, "error.invalid_input", MessageArgs{"details": "stay_as_participant must be true or false"})
+			return
+		}
+	}
+
+	var ride Ride
+	if err := dbFor(c).First(&ride, "id = ?", rideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
+		return
+	}
+
+	leader, err := currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+	if ride.LeaderID != leader.ID {
+		respondError(c, http.StatusForbidden, "error.not_ride_leader")
+		return
+	}
+
+	var participant Participant
+	if err := dbFor(c).Where("id = ? AND ride_id = ?", participantID, rideID).First(&participant).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.participant_not_found")
+		return
+	}
+
+	var newLeader User
+	if err := dbFor(c).First(&newLeader, "firebase_uid = ?", participant.UserID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+	// Checked before a stale offer is withdrawn below, so a refused transfer leaves it as it was
+	if respondLeadershipConflict(c, ride, newLeader, *leader, stay) {
+		return
+	}
+
+	// A pending offer blocks a new one, unless its participant has left the ride since
+	var pending LeadershipTransfer
+	if err := dbFor(c).Where("ride_id = ? AND status = ?", rideID, "pending").First(&pending).Error; err == nil {
+		var stillRiding int64
+		dbFor(c).Model(&Participant{}).Where("ride_id = ? AND user_id = ?", rideID, pending.ToUserUID).Count(&stillRiding)
+		if stillRiding > 0 {
+			respondErrorWithDetails(c, http.StatusConflict, "error.leadership_transfer_pending", gin.H{
+				"transfer_id":     pending.ID,
+				"action_required": "withdraw_transfer",
+			})
+			return
+		}
+		if !respondToLeadershipOffer(c, &pending, "withdrawn") {
+			return
+		}
+		leadershipTransfers.WithLabelValues("withdrawn").Inc()
+	}
+
+	transfer := LeadershipTransfer{RideID: ride.ID, FromUserID: leader.ID, ToUserUID: newLeader.FirebaseUID, StayAsParticipant: stay, Status: "pending"}
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "leadership_offered", TargetType: "ride", TargetID: ride.ID, RideID: ride.ID, SubjectUID: newLeader.FirebaseUID, After: transfer})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.leadership_transfer_failed")
+		return
+	}
+
+	payload := NotificationPayload{ActorUserID: leader.ID, ActorName: leader.Name, Ride: newRideSnapshot(ride)}
+	if err := createNotification(dbFor(c), newLeader.FirebaseUID, "leadership_offered", ride.ID, payload); err != nil {
+		// Log error but don't fail the request
+		slog.ErrorContext(c.Request.Context(), "failed to create notification", "error", err)
+	}
+
+	leadershipTransfers.WithLabelValues("offered").Inc()
+	c.JSON(http.StatusOK, LeadershipTransferResponse{
+		Message:       tr(c, "message.leadership_offered"),
+		Transfer:      transfer,
+		ParticipantID: participant.ID,
+	})
+}
+
+// DELETE /ride/:rideID/transfer-leadership - Leader withdraws their pending offer
+func WithdrawLeadershipTransfer(c *gin.Context) {
+	rideID, err := strconv.Atoi(c.Param("rideID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
+		return
+	}
+
+	var ride Ride
+	if err := dbFor(c).First(&ride, "id = ?", rideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
+		return
+	}
+
+	leader, err := currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+	if ride.LeaderID != leader.ID {
+		respondError(c, http.StatusForbidden, "error.not_ride_leader")
+		return
+	}
+
+	var transfer LeadershipTransfer
+	if err := dbFor(c).Where("ride_id = ? AND status = ?", rideID, "pending").First(&transfer).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.no_leadership_offer")
+		return
+	}
+	if !respondToLeadershipOffer(c, &transfer, "withdrawn") {
+		return
+	}
+
+	leadershipTransfers.WithLabelValues("withdrawn").Inc()
+	c.JSON(http.StatusOK, LeadershipTransferResponse{Message: tr(c, "message.leadership_withdrawn"), Transfer: transfer})
+}
+
+// POST /ride/:rideID/decline-leadership - Participant turns down the ride offered to them
+func DeclineRideLeadership(c *gin.Context) {
+	rideID, err := strconv.Atoi(c.Param("rideID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+
+	var transfer LeadershipTransfer
+	if err := dbFor(c).Where("ride_id = ? AND to_user_uid = ? AND status = ?", rideID, user.FirebaseUID, "pending").First(&transfer).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.no_leadership_offer")
+		return
+	}
+	if !respondToLeadershipOffer(c, &transfer, "declined") {
+		return
+	}
+
+	// The leader may have deleted the ride meanwhile, then there's nobody to tell
+	if ride, err := cachedRide(dbFor(c), transfer.RideID); err == nil {
+		if leader, err := getUser(transfer.FromUserID); err == nil {
+			payload := NotificationPayload{ActorUserID: user.ID, ActorName: user.Name, Ride: newRideSnapshot(ride)}
+			if err := createNotification(dbFor(c), leader.FirebaseUID, "leadership_declined", ride.ID, payload); err != nil {
+				slog.ErrorContext(c.Request.Context(), "failed to create notification", "error", err)
+			}
+		}
+	}
+
+	leadershipTransfers.WithLabelValues("declined").Inc()
+	c.JSON(http.StatusOK, LeadershipTransferResponse{Message: tr(c, "message.leadership_declined"), Transfer: transfer})
+}
+
+// respondToLeadershipOffer closes a pending offer with status. It responds
+// and returns false if that fails.
+func respondToLeadershipOffer(c *gin.Context, transfer *LeadershipTransfer, status string) bool {
+	now := time.Now()
+	before := *transfer
+	closed := before
+	closed.Status, closed.RespondedAt = status, &now
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&LeadershipTransfer{}).Where("id = ? AND status = ?", transfer.ID, "pending").
+			Updates(map[string]interface{}{"status": status, "responded_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLeadershipOfferGone
+		}
+		return recordAudit(tx, c, auditEntry{Action: "leadership_" + status, TargetType: "ride", TargetID: transfer.RideID, RideID: transfer.RideID,
+			SubjectUID: transfer.ToUserUID, Before: before, After: closed})
+	})
+	if errors.Is(err, errLeadershipOfferGone) {
+		respondError(c, http.StatusConflict, "error.no_leadership_offer")
+		return false
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.leadership_transfer_failed")
+		return false
+	}
+	*transfer = closed
+	return true
+}
+
+// POST /ride/:rideID/accept-leadership - Participant takes over the ride offered
+// to them. Their seat goes to the old leader, or is freed if the old leader leaves.
+func AcceptRideLeadership(c *gin.Context) {
+	rideID, err := strconv.Atoi(c.Param("rideID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
+		return
+	}
+
+	newLeader, err := currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+
+	var transfer LeadershipTransfer
+	if err := dbFor(c).Where("ride_id = ? AND to_user_uid = ? AND status = ?", rideID, newLeader.FirebaseUID, "pending").First(&transfer).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.no_leadership_offer")
+		return
+	}
+
+	var ride Ride
+	if err := dbFor(c).First(&ride, "id = ?", rideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
+		return
+	}
+
+	// The offer only stands while the user still rides along
+	var participant Participant
+	if err := dbFor(c).Where("ride_id = ? AND user_id = ?", rideID, newLeader.FirebaseUID).First(&participant).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.participant_not_found")
+		return
+	}
+
+	// The user may have left the organization since joining the ride
+	if !canUseRide(dbFor(c), ride, newLeader.FirebaseUID) {
+		respondError(c, http.StatusForbidden, "error.not_organization_member")
+		return
+	}
+
+	oldLeader, err := getUser(ride.LeaderID)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_leader_not_found")
+		return
+	}
+	if respondLeadershipConflict(c, ride, *newLeader, *oldLeader, transfer.StayAsParticipant) {
+		return
+	}
+
+	// Hand the ride over: the new leader gives up their seat, which the old leader takes if they stay
+	before := ride
+	now := time.Now()
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&LeadershipTransfer{}).Where("id = ? AND status = ?", transfer.ID, "pending").
+			Updates(map[string]interface{}{"status": "accepted", "responded_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errLeadershipOfferGone
+		}
+		if err := tx.Delete(&participant).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"leader_id": newLeader.ID}
+		if transfer.StayAsParticipant {
+			if err := tx.Create(&Participant{RideID: ride.ID, UserID: oldLeader.FirebaseUID, JoinedAt: now}).Error; err != nil {
+				return err
+			}
+		} else {
+			updates["seats_filled"] = gorm.Expr("seats_filled - 1")
+		}
+		if err := tx.Model(&ride).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&ride, "id = ?", ride.ID).Error; err != nil {
+			return err
+		}
+
+		transfer.Status, transfer.RespondedAt = "accepted", &now
+		return recordAudit(tx, c, auditEntry{Action: "leadership_transferred", TargetType: "ride", TargetID: ride.ID, RideID: ride.ID, SubjectUID: oldLeader.FirebaseUID,
+			Before: gin.H{"ride": before, "participant": participantSnapshot(participant)}, After: gin.H{"ride": ride, "transfer": transfer}})
+	})
+	if errors.Is(err, errLeadershipOfferGone) {
+		respondError(c, http.StatusConflict, "error.no_leadership_offer")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.leadership_transfer_failed")
+		return
+	}
+	invalidateRide(ride.ID)
+
+	notified := notifyLeaderChanged(c, ride, *newLeader, *oldLeader)
+
+	leadershipTransfers.WithLabelValues("accepted").Inc()
+	c.JSON(http.StatusOK, LeadershipTransferredResponse{
+		Message:              tr(c, "message.leadership_accepted"),
+		Ride:                 ride,
+		ParticipantsNotified: notified,
+	})
+}
+
+// respondLeadershipConflict applies the one-ride-per-date rule to a transfer
+// of ride: the new leader may have nothing else on that date, nor may the old
+// leader if they stay as a participant. Their ties to ride itself don't count.
+// It responds and returns true on a conflict.
+func respondLeadershipConflict(c *gin.Context, ride Ride, newLeader, oldLeader User, stay bool) bool {
+	conflict := func(role string, user User) bool {
+		hasInvolvement, involvementDetails := checkUserInvolvementBesidesRide(dbFor(c), user.FirebaseUID, user.ID, ride.Date, ride.OrganizationID, ride.ID)
+		if hasInvolvement {
+			respondErrorWithDetails(c, http.StatusConflict, "error.leadership_involvement_conflict", gin.H{
+				"user":                role, // "new_leader" or "leader"
+				"involvement_details": involvementDetails,
+				"date":                ride.Date,
+			}, MessageArgs{"name": user.Name, "date": ride.Date})
+		}
+		return hasInvolvement
+	}
+	return conflict("new_leader", newLeader) || (stay && conflict("leader", oldLeader))
+}
+
+// notifyLeaderChanged tells the old leader their ride was taken over, and its
+// other participants and open requesters who leads it now. It returns how many
+// users were notified.
+func notifyLeaderChanged(c *gin.Context, ride Ride, newLeader, oldLeader User) int {
+	payload := NotificationPayload{ActorUserID: newLeader.ID, ActorName: newLeader.Name, Ride: newRideSnapshot(ride)}
+	notified := 0
+	notify := func(uid, templateKey string) {
+		if err := createNotification(dbFor(c), uid, templateKey, ride.ID, payload); err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to create notification", "recipient_uid", uid, "error", err)
+			return
+		}
+		notified++
+	}
+
+	notify(oldLeader.FirebaseUID, "leadership_accepted")
+
+	var participants []Participant
+	if err := dbFor(c).Where("ride_id = ? AND user_id <> ?", ride.ID, oldLeader.FirebaseUID).Find(&participants).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to find participants to notify", "ride_id", ride.ID, "error", err)
+	}
+	for _, p := range participants {
+		notify(p.UserID, "leader_changed")
+	}
+
+	var requests []Request
+	if err := dbFor(c).Where("ride_id = ? AND status IN ?", ride.ID, []string{"pending", "approved"}).Find(&requests).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to find requesters to notify", "ride_id", ride.ID, "error", err)
+	}
+	for _, r := range requests {
+		notify(r.UserID, "leader_changed")
+	}
+	return notified
+}
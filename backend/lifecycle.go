@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// migrationsApplied is set once the schema is up to date
+var migrationsApplied atomic.Bool
+
+// shuttingDown makes /readyz fail while the server drains so load balancers stop routing to it
+var shuttingDown atomic.Bool
+
+// Background jobs run until stopBackgroundJobs is called on shutdown
+var (
+	backgroundCtx, stopBackgroundJobs = context.WithCancel(context.Background())
+	backgroundJobs                    sync.WaitGroup
+)
+
+// startBackgroundJob runs job every interval until shutdown
+func startBackgroundJob(name string, interval time.Duration, job func(ctx context.Context)) {
+	backgroundJobs.Add(1)
+	go func() {
+		defer backgroundJobs.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-backgroundCtx.Done():
+				slog.Debug("background job stopped", "job", name)
+				return
+			case <-ticker.C:
+				job(backgroundCtx)
+			}
+		}
+	}()
+}
+
+// GET /healthz - Liveness: the process is up and serving
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// GET /readyz - Readiness: the database answers, the token verifier is set up
+// and migrations have been applied
+func Readyz(c *gin.Context) {
+	checks := map[string]string{
+		"database":   "ok",
+		"verifier":   "ok",
+		"migrations": "ok",
+	}
+	ready := true
+	fail := func(check, reason string) {
+		checks[check] = reason
+		ready = false
+	}
+
+	if sqlDB, err := DB.DB(); err != nil {
+		fail("database", err.Error())
+	} else {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+		if err := sqlDB.PingContext(ctx); err != nil {
+			fail("database", err.Error())
+		}
+	}
+	if authClient == nil {
+		fail("verifier", "not initialized")
+	}
+	if !migrationsApplied.Load() {
+		// Migrations may have been applied by "migrate up" since startup
+		if sqlDB, err := DB.DB(); err == nil {
+			if pending, err := pendingMigrations(c.Request.Context(), sqlDB); err == nil && pending == 0 {
+				migrationsApplied.Store(true)
+			}
+		}
+		if !migrationsApplied.Load() {
+			fail("migrations", "pending")
+		}
+	}
+	if shuttingDown.Load() {
+		fail("server", "shutting down")
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: "unavailable", Checks: checks})
+		return
+	}
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok", Checks: checks})
+}
+
+// serveUntilSignal serves handler on addr until SIGINT or SIGTERM, then drains
+// in-flight requests, stops background jobs and closes the database, giving up
+// after SHUTDOWN_TIMEOUT
+func serveUntilSignal(addr string, handler http.Handler, shutdownTracing func(context.Context) error) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-signals.Done():
+		stop() // A second signal kills the process without waiting for the drain
+	}
+
+	slog.Info("shutting down", "timeout", config.ShutdownTimeout.String())
+	shuttingDown.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("error draining connections: %v", err))
+	}
+
+	stopBackgroundJobs()
+	jobsDone := make(chan struct{})
+	go func() {
+		backgroundJobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		errs = append(errs, errors.New("background jobs did not stop in time"))
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("error flushing traces: %v", err))
+	}
+	if sqlDB, err := DB.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing database: %v", err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	slog.Info("shutdown complete")
+	return nil
+}
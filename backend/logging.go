@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// logLevel is the minimum level written, set by LOG_LEVEL (debug, info, warn, error)
+var logLevel = new(slog.LevelVar)
+
+// contextKey namespaces values stored on request contexts by this package
+type contextKey string
+
+const requestIDContextKey contextKey = "request_id"
+
+// InitLogging installs a JSON slog logger writing to out as the default, including for the
+// standard log package. LOG_LEVEL sets the level and LOG_FORMAT=text switches
+// to human-readable output for local development.
+func InitLogging(out io.Writer) error {
+	if err := logLevel.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL %q", config.LogLevel)
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel}
+	var handler slog.Handler
+	switch format := config.LogFormat; format {
+	case "json":
+		handler = slog.NewJSONHandler(out, options)
+	case "text":
+		handler = slog.NewTextHandler(out, options)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %q, expected json or text", format)
+	}
+
+	slog.SetDefault(slog.New(requestContextHandler{handler}))
+	return nil
+}
+
+// requestContextHandler adds the request and trace IDs carried by the context to every record
+type requestContextHandler struct {
+	slog.Handler
+}
+
+func (h requestContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id, ok := ctx.Value(requestIDContextKey).(string); ok {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestContextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestContextHandler) WithGroup(name string) slog.Handler {
+	return requestContextHandler{h.Handler.WithGroup(name)}
+}
+
+// fatal logs msg at error level and exits, replacing log.Fatal
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// RequestIDMiddleware assigns every request an ID (reusing a valid X-Request-ID),
+// echoes it back and stores it on the request context for logs and queries
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestIDFor(c)
+		c.Header("X-Request-ID", id)
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestIDContextKey, id))
+		c.Next()
+	}
+}
+
+// AccessLogMiddleware writes one log line per request once it has been served
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if uid, exists := c.Get("uid"); exists {
+			attrs = append(attrs, slog.String("uid", uid.(string)))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// dbFor returns the database handle bound to the request's context, so queries
+// are logged with its request ID
+func dbFor(c *gin.Context) *gorm.DB {
+	return DB.WithContext(c.Request.Context())
+}
+
+// gormLogger sends GORM's query log through slog. Every query is logged at
+// debug level, slow queries at warn and failed queries at error. Parameters
+// that hold personal data are redacted before the SQL is rendered.
+type gormLogger struct {
+	slowThreshold time.Duration
+}
+
+func newGormLogger(slowThreshold time.Duration) logger.Interface {
+	return gormLogger{slowThreshold: slowThreshold}
+}
+
+func (l gormLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l // The level follows LOG_LEVEL instead
+}
+
+func (l gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+
+	var level slog.Level
+	var msg string
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level, msg = slog.LevelError, "query failed"
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold:
+		level, msg = slog.LevelWarn, "slow query"
+	default:
+		level, msg = slog.LevelDebug, "query"
+	}
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	slog.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// piiColumns are the columns whose values never appear in query logs: the
+// profile fields, and the audit snapshots and notification payloads that
+// copy them
+var piiColumns = map[string]bool{
+	"name":    true,
+	"email":   true,
+	"phone":   true,
+	"before":  true,
+	"after":   true,
+	"payload": true,
+}
+
+var (
+	comparedPlaceholder = regexp.MustCompile("(?i)[\"`]?(\\w+)[\"`]?\\s*(?:=|<>|!=|i?like)\\s*(\\$\\d+|\\?)")
+	insertStatement     = regexp.MustCompile(`(?is)^INSERT INTO \S+ \(([^)]*)\) VALUES (.*)$`)
+	valueTuple          = regexp.MustCompile(`\(([^()]*)\)`)
+	emailLike           = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	phoneLike           = regexp.MustCompile(`^\+?[0-9][0-9 ()-]+$`)
+)
+
+// looksLikePII reports whether a parameter value is an email address or a
+// phone number (at least 10 digits, so dates like 2025-05-20 are kept)
+func looksLikePII(text string) bool {
+	if emailLike.MatchString(text) {
+		return true
+	}
+	digits := 0
+	for _, r := range text {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 10 && phoneLike.MatchString(text)
+}
+
+// ParamsFilter redacts query parameters bound to PII columns, plus any string
+// that looks like an email address or phone number, before GORM renders the
+// SQL for the log. The query itself still runs with the original values.
+// Placeholders are numbered ($1) with Postgres and positional (?) with SQLite.
+func (l gormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	redact := map[int]bool{}
+
+	for _, match := range comparedPlaceholder.FindAllStringSubmatchIndex(sql, -1) {
+		if piiColumns[strings.ToLower(sql[match[2]:match[3]])] {
+			redact[placeholderIndex(sql, match[4], sql[match[4]:match[5]])] = true
+		}
+	}
+
+	if match := insertStatement.FindStringSubmatchIndex(sql); match != nil {
+		columns := strings.Split(sql[match[2]:match[3]], ",")
+		values := sql[match[4]:match[5]]
+		for _, tuple := range valueTuple.FindAllStringSubmatchIndex(values, -1) {
+			offset := match[4] + tuple[2]
+			for i, value := range strings.Split(values[tuple[2]:tuple[3]], ",") {
+				token := strings.TrimSpace(value)
+				position := offset + strings.Index(value, token)
+				offset += len(value) + 1
+				if (token != "?" && !strings.HasPrefix(token, "$")) || i >= len(columns) {
+					continue
+				}
+				if piiColumns[strings.ToLower(strings.Trim(strings.TrimSpace(columns[i]), "`\""))] {
+					redact[placeholderIndex(sql, position, token)] = true
+				}
+			}
+		}
+	}
+
+	filtered := make([]interface{}, len(params))
+	for i, param := range params {
+		if text, ok := param.(string); ok && looksLikePII(text) {
+			redact[i] = true
+		}
+		if redact[i] {
+			filtered[i] = "[REDACTED]"
+		} else {
+			filtered[i] = param
+		}
+	}
+	return sql, filtered
+}
+
+// placeholderIndex returns which parameter the placeholder token at position
+// in sql binds: $n names it, and a ? is counted from the start of the query
+func placeholderIndex(sql string, position int, token string) int {
+	if n, err := strconv.Atoi(strings.TrimPrefix(token, "$")); err == nil {
+		return n - 1
+	}
+	return strings.Count(sql[:position], "?")
+}
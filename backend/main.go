@@ -1,106 +1,166 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
 func main() {
-	// Load environment variables from .env file (optional for production)
-	err := godotenv.Load()
+	// Load settings from flags, the environment and .env (or --config); see config.go
+	args, err := LoadConfig(os.Args[0], os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		printCommands(os.Stderr)
+		return
+	}
 	if err != nil {
-		log.Println("⚠️  No .env file found, using system environment variables")
-	} else {
-		log.Println("✅ .env file loaded successfully")
+		fatal("failed to load configuration", "error", err)
+	}
+
+	// The first argument left after the flags picks the command, "serve" by default
+	name := "serve"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		printCommands(os.Stderr)
+		fatal("unknown command", "command", name)
+	}
+
+	// Initialize structured logging (LOG_LEVEL, LOG_FORMAT). Only the server logs
+	// to stdout, so commands can write their output there.
+	logOutput := os.Stderr
+	if cmd.Name == "serve" {
+		logOutput = os.Stdout
+	}
+	if err := InitLogging(logOutput); err != nil {
+		fatal("failed to initialize logging", "error", err)
+	}
+	configLevel := slog.LevelDebug
+	if cmd.Name == "serve" {
+		configLevel = slog.LevelInfo
+	}
+	slog.Log(context.Background(), configLevel, "configuration loaded", "config", config)
+	if cmd.Name == "help" {
+		cmd.Run(args)
+		return
+	}
+
+	// Initialize tracing (disabled unless OTEL_TRACES_EXPORTER is set)
+	if shutdownTracing, err = InitTracing(); err != nil {
+		fatal("failed to initialize tracing", "error", err)
 	}
 
 	// Initialize Database
 	InitDatabase()
 
+	if cmd.Schema {
+		if err := requireSchema(); err != nil {
+			fatal("database schema is not ready", "error", err)
+		}
+	}
+	if err := cmd.Run(args); err != nil {
+		fatal(cmd.Name+" failed", "error", err)
+	}
+	if cmd.Name != "serve" {
+		shutdownTracing(context.Background())
+	}
+}
+
+// runServe implements "serve": it applies pending migrations, initializes
+// every subsystem the API needs and serves until SIGINT or SIGTERM
+func runServe(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("serve takes no arguments, got %q", args)
+	}
+	// Apply pending schema migrations (MIGRATE_ON_START=false leaves them to "migrate up")
+	if err := applyMigrationsOnStart(); err != nil {
+		return fmt.Errorf("error applying migrations: %v", err)
+	}
+
+	// Expose database pool stats on /metrics
+	if err := InitMetrics(); err != nil {
+		return fmt.Errorf("error initializing metrics: %v", err)
+	}
+
 	// Initialize Firebase Admin SDK (for token verification)
-	err = InitFirebase()
-	if err != nil {
-		log.Fatalf("Failed to initialize Firebase: %v", err)
+	if err := InitFirebase(); err != nil {
+		return fmt.Errorf("error initializing Firebase: %v", err)
+	}
+
+	// Initialize rate limiting (in-memory by default, Postgres for multiple replicas)
+	if err := InitRateLimiter(); err != nil {
+		return fmt.Errorf("error initializing rate limiter: %v", err)
+	}
+
+	// Initialize Idempotency-Key support for state-changing routes
+	if err := InitIdempotency(); err != nil {
+		return fmt.Errorf("error initializing idempotency keys: %v", err)
+	}
+
+	// Start the worker that builds personal data exports (GET /user/export)
+	if err := InitDataExports(); err != nil {
+		return fmt.Errorf("error initializing data exports: %v", err)
+	}
+
+	// Set up the SMS_PROVIDER sender for phone verification codes
+	if err := InitSMS(); err != nil {
+		return fmt.Errorf("error initializing SMS: %v", err)
+	}
+
+	// Flag accounts that break the sign-up email policy (ALLOWED_EMAIL_DOMAINS)
+	InitEmailPolicy()
+
+	// Start the worker that deletes accounts once their grace period ends (DELETE /user)
+	InitAccountDeletion()
+
+	// Build the OpenAPI document from the route table
+	if err := InitOpenAPI(); err != nil {
+		return fmt.Errorf("error initializing OpenAPI: %v", err)
 	}
 
-	r := gin.Default()
+	r := gin.New()
 
-	// Configure trusted proxies for security
-	r.SetTrustedProxies([]string{"127.0.0.1", "::1"}) // Only trust localhost
+	// Trace and tag every request with an ID, log it once served and recover from panics
+	r.Use(TracingMiddleware(), RequestIDMiddleware(), AccessLogMiddleware(), MetricsMiddleware(), gin.Recovery())
+
+	// Only trust X-Forwarded-For from TRUSTED_PROXIES (localhost by default)
+	if err := r.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %v", err)
+	}
 
-	// Configure CORS to allow frontend communication
+	// Configure CORS to allow frontend communication from CORS_ALLOWED_ORIGINS
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"}, // Allow all origins for production deployment
+		AllowOrigins:     config.CORSOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Accept", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "Accept", "X-Requested-With", "Idempotency-Key", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After", "Idempotent-Replayed", "X-Request-ID"},
 		AllowCredentials: false, // Must be false when AllowOrigins is "*"
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// Public route example
-	r.GET("/public", func(c *gin.Context) {
-		c.JSON(200, gin.H{"message": "This is a public endpoint"})
-	})
-
-	// Ping endpoint for testing connectivity with cleanup
-	r.GET("/ping", func(c *gin.Context) {
-		// Clean up expired rides
-		cleanupExpiredRides()
-
-		c.JSON(http.StatusOK, gin.H{
-			"message": "pong",
-		})
-	})
-
-	// Protected routes (require authentication)
-	protected := r.Group("/")
-	protected.Use(FirebaseAuthMiddleware())
-
-	// User APIs
-	protected.GET("/user", GetCurrentUser)                                         // GET /user - Get current user profile
-	protected.PUT("/user", UpdateCurrentUser)                                      // PUT /user - Update current user profile
-	protected.POST("/user", CreateUser)                                            // POST /user
-	protected.GET("/user/:userID", GetUserBasic)                                   // GET /user/:userID
-	protected.GET("/user/rides/posted", GetRidesPostedByUser)                      // GET /user/rides/posted
-	protected.GET("/user/rides/joined", GetRidesJoinedByUser)                      // GET /user/rides/joined
-	protected.GET("/user/privileges", GetUserPrivileges)                           // GET /user/privileges
-	protected.GET("/user/requests", GetUserSentRequests)                           // GET /user/requests
-	protected.DELETE("/user/clear-involvement/:date", ClearInvolvementForDate)     // DELETE /user/clear-involvement/:date
-	protected.GET("/user/notifications", GetUserNotifications)                     // GET /user/notifications
-	protected.GET("/user/notifications/unread-count", GetUnreadNotificationCount)  // GET /user/notifications/unread-count
-	protected.PUT("/user/notifications/mark-all-read", MarkAllNotificationsAsRead) // PUT /user/notifications/mark-all-read
-	protected.DELETE("/user/cancel-ride/:rideID", CancelRideParticipation)         // DELETE /user/cancel-ride/:rideID (unified)
-
-	// Ride APIs
-	protected.POST("/ride", AddRide)                                    // POST /ride
-	protected.DELETE("/ride/:rideID", DeleteRide)                       // DELETE /ride/:rideID - Leader deletes their ride
-	protected.GET("/ride/:rideID/leader", GetRideLeader)                // GET /ride/:rideID/leader
-	r.GET("/ride/filter", FilterRides)                                  // GET /rides/filter?origin=College Campus&destination=City Airport&date=2025-06-10
-	protected.GET("/ride/:rideID/requests", GetJoinRequestsForRide)     // GET /ride/:rideID/requests
-	protected.POST("/ride/:rideID/join", SendJoinRequest)               // POST /ride/:rideID/join
-	protected.DELETE("/ride/:rideID/cancel-request", CancelJoinRequest) // DELETE /ride/:rideID/cancel-request
-	protected.POST("/ride/:rideID/join-ride", JoinRideWithPrivilege)    // POST /ride/:rideID/join-ride
-
-	// Participant Management APIs (Leaders only)
-	protected.GET("/ride/:rideID/participants", GetRideParticipants)                // GET /ride/:rideID/participants
-	protected.DELETE("/ride/:rideID/participant/:participantID", RemoveParticipant) // DELETE /ride/:rideID/participant/:participantID
-	protected.POST("/ride/:rideID/approve/:requestID", ApproveJoinRequest)          // POST /ride/:rideID/approve/:requestID
-	protected.POST("/ride/:rideID/reject/:requestID", RejectJoinRequest)            // POST /ride/:rideID/reject/:requestID
-
-	// Notification APIs
-	protected.POST("/notification/:notificationID/read", MarkNotificationAsRead) // POST /notification/:notificationID/read
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	log.Printf("🚀 Brocab server running on port %s", port)
-	r.Run(":" + port)
+	// Mount the API under /v1, keeping the unversioned paths as deprecated aliases
+	registerRoutes(r)
+
+	// Refuse to start if a route was registered outside the route table
+	if err := checkOpenAPICoverage(r.Routes()); err != nil {
+		return fmt.Errorf("OpenAPI spec is out of date: %v", err)
+	}
+
+	slog.Info("server starting", "port", config.Port)
+
+	// Serve until SIGINT/SIGTERM, then drain requests and release resources
+	if err := serveUntilSignal(":"+config.Port, r, shutdownTracing); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
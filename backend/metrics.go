@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HTTP metrics
+var httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "brocab_http_request_duration_seconds",
+	Help:    "Time taken to serve HTTP requests, by route template.",
+	Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+}, []string{"method", "route", "status"})
+
+// Business metrics
+var (
+	ridesCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "brocab_rides_created_total",
+		Help: "Rides posted by leaders.",
+	})
+	joinRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "brocab_join_requests_total",
+		Help: "Join requests by outcome: sent, approved or rejected.",
+	}, []string{"outcome"})
+	rideJoins = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "brocab_ride_joins_total",
+		Help: "Passengers who joined a ride with an approved privilege.",
+	})
+	cancellations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "brocab_cancellations_total",
+		Help: "Cancellations by type: request, privilege, participation, participant_removed, request_released (busy on a ride's new date) or ride.",
+	}, []string{"type"})
+	notificationsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "brocab_notifications_created_total",
+		Help: "Notifications created, by template.",
+	}, []string{"template"})
+)
+
+// Cleanup metrics
+var (
+	cleanupDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "brocab_cleanup_duration_seconds",
+		Help:    "Duration of expired ride cleanup runs, by outcome: success, noop or error.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"outcome"})
+	cleanupRidesDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "brocab_cleanup_rides_deleted_total",
+		Help: "Expired rides deleted by cleanup runs.",
+	})
+)
+
+// Data export metrics
+var dataExports = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "brocab_data_exports_total",
+	Help: "Personal data exports by outcome: requested, ready, failed, downloaded or expired.",
+}, []string{"outcome"})
+
+// Phone verification metrics
+var phoneVerifications = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "brocab_phone_verifications_total",
+	Help: "Phone verification steps by outcome: started, send_failed, verified, wrong_code, expired or locked.",
+}, []string{"outcome"})
+
+// Email policy metrics
+var (
+	signupsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "brocab_signups_rejected_total",
+		Help: "Sign-ups refused by the email policy, by reason: email_not_verified, domain_not_allowed or denied.",
+	}, []string{"reason"})
+	emailPolicyFlagged = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "brocab_email_policy_flagged_users",
+		Help: "Accounts breaking the email policy at the last check.",
+	})
+)
+
+// Account deletion metrics
+var accountDeletions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "brocab_account_deletions_total",
+	Help: "Account deletions by outcome: scheduled, restored, completed or failed.",
+}, []string{"outcome"})
+
+// Cache metrics
+var cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "brocab_cache_lookups_total",
+	Help: "In-memory cache lookups, by cache (ride or ride_filter) and result: hit or miss.",
+}, []string{"cache", "result"})
+
+// Leadership transfer metrics
+var leadershipTransfers = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "brocab_leadership_transfers_total",
+	Help: "Ride leadership transfers by outcome: offered, accepted, declined or withdrawn.",
+}, []string{"outcome"})
+
+// InitMetrics registers the database pool collector. Call it after InitDatabase.
+func InitMetrics() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("error getting database pool: %v", err)
+	}
+	return prometheus.Register(collectors.NewDBStatsCollector(sqlDB, "brocab"))
+}
+
+// GET /metrics - Prometheus scrape endpoint
+func ServeMetrics() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// MetricsMiddleware records the latency of every request against its route template
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched" // Keeps 404 scans from creating a series per path
+		}
+		httpRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeCleanup records one cleanup run that started at start
+func observeCleanup(start time.Time, outcome string, deleted int) {
+	cleanupDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	cleanupRidesDeleted.Add(float64(deleted))
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Each DB_DRIVER has its own copy of the migrations, with matching versions
+//
+//go:embed migrations/postgres/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// migrationFileName matches "<version>_<name>.<up|down>.sql", e.g. "0002_constraints_and_indexes.up.sql"
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migration is one schema version with the SQL to apply and to revert it
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migrationState is a migration together with when it was applied, if it was
+type migrationState struct {
+	migration
+	AppliedAt *time.Time
+}
+
+// migrationDialect holds the driver-specific parts of the migration bookkeeping
+type migrationDialect struct {
+	dir             string
+	createTable     string
+	insertMigration string
+	deleteMigration string
+}
+
+var migrationDialects = map[string]migrationDialect{
+	"postgres": {
+		dir: "migrations/postgres",
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    bigint PRIMARY KEY,
+    name       text NOT NULL,
+    applied_at timestamptz NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+		insertMigration: "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)",
+		deleteMigration: "DELETE FROM schema_migrations WHERE version = $1",
+	},
+	"sqlite": {
+		dir: "migrations/sqlite",
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    integer PRIMARY KEY,
+    name       text NOT NULL,
+    applied_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+		insertMigration: "INSERT INTO schema_migrations (version, name) VALUES (?, ?)",
+		deleteMigration: "DELETE FROM schema_migrations WHERE version = ?",
+	},
+}
+
+// dialect returns the bookkeeping for the configured DB_DRIVER
+func dialect() migrationDialect {
+	return migrationDialects[config.DBDriver]
+}
+
+// loadMigrations reads the embedded migrations in version order, checking that
+// every version has both an up and a down script
+func loadMigrations() ([]migration, error) {
+	dir := dialect().dir
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := migrationFiles.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %v", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// migrationStatus lists every known migration and when it was applied
+func migrationStatus(ctx context.Context, db *sql.DB) ([]migrationState, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, dialect().createTable); err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations: %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("error reading schema_migrations: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %v", err)
+	}
+
+	states := make([]migrationState, len(migrations))
+	for i, m := range migrations {
+		states[i] = migrationState{migration: m}
+		if appliedAt, ok := applied[m.Version]; ok {
+			states[i].AppliedAt = &appliedAt
+		}
+	}
+	return states, nil
+}
+
+// pendingMigrations returns how many migrations have not been applied yet
+func pendingMigrations(ctx context.Context, db *sql.DB) (int, error) {
+	states, err := migrationStatus(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// migrateUp applies every pending migration in order, each in its own transaction
+func migrateUp(ctx context.Context, db *sql.DB) error {
+	states, err := migrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, state := range states {
+		if state.AppliedAt != nil {
+			continue
+		}
+		err := runInTx(ctx, db, state.Up, dialect().insertMigration, state.Version, state.Name)
+		if err != nil {
+			return fmt.Errorf("error applying migration %04d_%s: %v", state.Version, state.Name, err)
+		}
+		slog.Info("migration applied", "version", state.Version, "name", state.Name)
+	}
+	return nil
+}
+
+// migrateDown reverts the most recently applied steps migrations, newest first
+func migrateDown(ctx context.Context, db *sql.DB, steps int) error {
+	states, err := migrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for i := len(states) - 1; i >= 0 && steps > 0; i-- {
+		state := states[i]
+		if state.AppliedAt == nil {
+			continue
+		}
+		err := runInTx(ctx, db, state.Down, dialect().deleteMigration, state.Version)
+		if err != nil {
+			return fmt.Errorf("error reverting migration %04d_%s: %v", state.Version, state.Name, err)
+		}
+		slog.Info("migration reverted", "version", state.Version, "name", state.Name)
+		steps--
+	}
+	return nil
+}
+
+// runInTx runs a migration script and its bookkeeping statement atomically
+func runInTx(ctx context.Context, db *sql.DB, script, bookkeeping string, args ...interface{}) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// runMigrateCommand implements "migrate up", "migrate down [steps]" and "migrate status"
+func runMigrateCommand(args []string) error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up | down [steps] | status")
+	}
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, sqlDB)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		return migrateDown(ctx, sqlDB, steps)
+	case "status":
+		states, err := migrationStatus(ctx, sqlDB)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = "applied " + state.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-40s %s\n", state.Version, state.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+}
+
+// applyMigrationsOnStart brings the schema up to date before serving, unless
+// MIGRATE_ON_START=false, in which case /readyz waits for "migrate up" to be run
+func applyMigrationsOnStart() error {
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	if !config.MigrateOnStart {
+		pending, err := pendingMigrations(context.Background(), sqlDB)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			slog.Warn("migrations pending, run \"migrate up\"", "pending", pending)
+		}
+		migrationsApplied.Store(pending == 0)
+		return nil
+	}
+
+	if err := migrateUp(context.Background(), sqlDB); err != nil {
+		return err
+	}
+	migrationsApplied.Store(true)
+	return nil
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS rate_limit_buckets;
+DROP TABLE IF EXISTS idempotency_records;
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS participants;
+DROP TABLE IF EXISTS requests;
+DROP TABLE IF EXISTS rides;
+DROP TABLE IF EXISTS users;
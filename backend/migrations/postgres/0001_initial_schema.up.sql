@@ -0,0 +1,103 @@
+-- Tables as previously created by GORM AutoMigrate. IF NOT EXISTS keeps this a
+-- no-op on databases that were set up before versioned migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id           bigserial PRIMARY KEY,
+    name         varchar(100) NOT NULL,
+    email        varchar(100) NOT NULL,
+    phone        varchar(15)  NOT NULL,
+    gender       varchar(10),
+    firebase_uid varchar(100) NOT NULL,
+    created_at   timestamptz,
+    updated_at   timestamptz
+);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale varchar(10) DEFAULT 'en';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin boolean DEFAULT false;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_firebase_uid ON users (firebase_uid);
+
+CREATE TABLE IF NOT EXISTS rides (
+    id           bigserial PRIMARY KEY,
+    leader_id    bigint,
+    origin       text,
+    destination  text,
+    date         text,
+    time         text,
+    seats        bigint,
+    seats_filled bigint,
+    price        numeric,
+    created_at   timestamptz,
+    updated_at   timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS requests (
+    id         bigserial PRIMARY KEY,
+    ride_id    bigint NOT NULL,
+    user_id    text   NOT NULL,
+    status     text   NOT NULL,
+    revoked_at timestamptz DEFAULT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS participants (
+    id         bigserial PRIMARY KEY,
+    ride_id    bigint NOT NULL,
+    user_id    text   NOT NULL,
+    joined_at  timestamptz DEFAULT CURRENT_TIMESTAMP,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id         bigserial PRIMARY KEY,
+    user_id    text         NOT NULL,
+    title      varchar(200) NOT NULL,
+    message    text         NOT NULL,
+    type       varchar(50)  NOT NULL,
+    ride_id    bigint       NOT NULL,
+    is_read    boolean DEFAULT false,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS template_key varchar(100);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS payload text;
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id          bigserial PRIMARY KEY,
+    actor_uid   varchar(100),
+    subject_uid varchar(100),
+    action      varchar(50) NOT NULL,
+    target_type varchar(30) NOT NULL,
+    target_id   bigint,
+    ride_id     bigint,
+    before      text,
+    after       text,
+    request_id  varchar(64),
+    created_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_uid ON audit_events (actor_uid);
+CREATE INDEX IF NOT EXISTS idx_audit_events_subject_uid ON audit_events (subject_uid);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_ride_id ON audit_events (ride_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+
+CREATE TABLE IF NOT EXISTS idempotency_records (
+    id           bigserial PRIMARY KEY,
+    user_uid     varchar(100) NOT NULL,
+    key          varchar(255) NOT NULL,
+    request_hash varchar(64)  NOT NULL,
+    status_code  bigint       NOT NULL DEFAULT 0,
+    content_type varchar(100),
+    body         bytea,
+    expires_at   timestamptz,
+    created_at   timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_user_key ON idempotency_records (user_uid, key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_records_expires_at ON idempotency_records (expires_at);
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key        varchar(200) PRIMARY KEY,
+    tokens     numeric NOT NULL,
+    updated_at timestamptz
+);
@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS idx_notifications_user_created;
+DROP INDEX IF EXISTS idx_notifications_user_read;
+DROP INDEX IF EXISTS idx_participants_user;
+DROP INDEX IF EXISTS idx_requests_ride_status;
+DROP INDEX IF EXISTS idx_requests_user_status;
+DROP INDEX IF EXISTS idx_rides_date;
+DROP INDEX IF EXISTS idx_rides_route_date;
+DROP INDEX IF EXISTS idx_rides_leader_date;
+
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS fk_notifications_user;
+ALTER TABLE participants DROP CONSTRAINT IF EXISTS fk_participants_user;
+ALTER TABLE participants DROP CONSTRAINT IF EXISTS fk_participants_ride;
+ALTER TABLE requests DROP CONSTRAINT IF EXISTS fk_requests_user;
+ALTER TABLE requests DROP CONSTRAINT IF EXISTS fk_requests_ride;
+ALTER TABLE rides DROP CONSTRAINT IF EXISTS fk_rides_leader;
+
+ALTER TABLE requests DROP CONSTRAINT IF EXISTS uq_requests_ride_user;
+ALTER TABLE participants DROP CONSTRAINT IF EXISTS uq_participants_ride_user;
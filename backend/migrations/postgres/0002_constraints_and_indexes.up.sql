@@ -0,0 +1,38 @@
+-- Duplicate rows would block the unique constraints below; keep the newest of each pair.
+DELETE FROM participants p
+ USING participants newer
+ WHERE p.ride_id = newer.ride_id AND p.user_id = newer.user_id AND p.id < newer.id;
+
+DELETE FROM requests r
+ USING requests newer
+ WHERE r.ride_id = newer.ride_id AND r.user_id = newer.user_id AND r.id < newer.id;
+
+-- A user has at most one request and one seat per ride
+ALTER TABLE participants ADD CONSTRAINT uq_participants_ride_user UNIQUE (ride_id, user_id);
+ALTER TABLE requests ADD CONSTRAINT uq_requests_ride_user UNIQUE (ride_id, user_id);
+
+-- Foreign keys are NOT VALID so rows orphaned before this migration don't block
+-- it; every new or updated row is still checked
+ALTER TABLE rides
+    ADD CONSTRAINT fk_rides_leader FOREIGN KEY (leader_id) REFERENCES users (id) NOT VALID;
+ALTER TABLE requests
+    ADD CONSTRAINT fk_requests_ride FOREIGN KEY (ride_id) REFERENCES rides (id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE requests
+    ADD CONSTRAINT fk_requests_user FOREIGN KEY (user_id) REFERENCES users (firebase_uid) ON UPDATE CASCADE NOT VALID;
+ALTER TABLE participants
+    ADD CONSTRAINT fk_participants_ride FOREIGN KEY (ride_id) REFERENCES rides (id) ON DELETE CASCADE NOT VALID;
+ALTER TABLE participants
+    ADD CONSTRAINT fk_participants_user FOREIGN KEY (user_id) REFERENCES users (firebase_uid) ON UPDATE CASCADE NOT VALID;
+ALTER TABLE notifications
+    ADD CONSTRAINT fk_notifications_user FOREIGN KEY (user_id) REFERENCES users (firebase_uid) ON UPDATE CASCADE NOT VALID;
+
+-- Notifications outlive their ride on purpose, so notifications.ride_id has no foreign key
+
+CREATE INDEX idx_rides_leader_date ON rides (leader_id, date);
+CREATE INDEX idx_rides_route_date ON rides (origin, destination, date);
+CREATE INDEX idx_rides_date ON rides (date);
+CREATE INDEX idx_requests_user_status ON requests (user_id, status);
+CREATE INDEX idx_requests_ride_status ON requests (ride_id, status);
+CREATE INDEX idx_participants_user ON participants (user_id);
+CREATE INDEX idx_notifications_user_read ON notifications (user_id, is_read);
+CREATE INDEX idx_notifications_user_created ON notifications (user_id, created_at DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS phone_visibility;
+ALTER TABLE users DROP COLUMN IF EXISTS gender_visibility;
+ALTER TABLE users DROP COLUMN IF EXISTS name_visibility;
@@ -0,0 +1,4 @@
+-- Per-field profile visibility: "everyone", "co_riders" or "nobody"
+ALTER TABLE users ADD COLUMN name_visibility varchar(20) NOT NULL DEFAULT 'everyone';
+ALTER TABLE users ADD COLUMN gender_visibility varchar(20) NOT NULL DEFAULT 'everyone';
+ALTER TABLE users ADD COLUMN phone_visibility varchar(20) NOT NULL DEFAULT 'co_riders';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS phone_verifications;
+ALTER TABLE rides DROP COLUMN IF EXISTS require_verified_phone;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_verified_at;
+ALTER TABLE users ALTER COLUMN phone TYPE varchar(15);
@@ -0,0 +1,17 @@
+-- Phone verification by SMS code. Numbers are stored in E.164 ("+" and up to
+-- 15 digits), which no longer fits the old varchar(15).
+ALTER TABLE users ALTER COLUMN phone TYPE varchar(20);
+ALTER TABLE users ADD COLUMN phone_verified_at timestamptz;
+ALTER TABLE rides ADD COLUMN require_verified_phone boolean NOT NULL DEFAULT false;
+
+CREATE TABLE phone_verifications (
+    id          bigserial PRIMARY KEY,
+    user_uid    varchar(100) NOT NULL,
+    phone       varchar(20)  NOT NULL,
+    code_hash   varchar(64)  NOT NULL,
+    attempts    bigint NOT NULL DEFAULT 0,
+    expires_at  timestamptz NOT NULL,
+    verified_at timestamptz,
+    created_at  timestamptz
+);
+CREATE INDEX idx_phone_verifications_user_uid ON phone_verifications (user_uid);
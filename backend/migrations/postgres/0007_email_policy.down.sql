@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS email_access_overrides;
+ALTER TABLE users DROP COLUMN IF EXISTS email_flagged_at;
+ALTER TABLE users DROP COLUMN IF EXISTS email_flag;
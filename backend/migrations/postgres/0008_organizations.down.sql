@@ -0,0 +1,4 @@
+ALTER TABLE notifications DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE rides DROP COLUMN IF EXISTS organization_id;
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1 @@
+ALTER TABLE participants DROP COLUMN IF EXISTS ride_changed_at;
@@ -0,0 +1,14 @@
+-- A leader's offer to hand their ride over to one of its participants, who
+-- becomes the leader once they accept.
+CREATE TABLE leadership_transfers (
+    id                  bigserial PRIMARY KEY,
+    ride_id             bigint       NOT NULL REFERENCES rides (id) ON DELETE CASCADE,
+    from_user_id        bigint       NOT NULL REFERENCES users (id),
+    to_user_uid         varchar(100) NOT NULL REFERENCES users (firebase_uid) ON UPDATE CASCADE,
+    stay_as_participant boolean      NOT NULL DEFAULT false,
+    status              varchar(20)  NOT NULL DEFAULT 'pending',
+    created_at          timestamptz,
+    responded_at        timestamptz
+);
+CREATE INDEX idx_leadership_transfers_ride_status ON leadership_transfers (ride_id, status);
+CREATE INDEX idx_leadership_transfers_to_user_uid ON leadership_transfers (to_user_uid);
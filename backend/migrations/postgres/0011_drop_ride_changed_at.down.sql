@@ -0,0 +1 @@
+ALTER TABLE participants ADD COLUMN ride_changed_at timestamptz;
@@ -0,0 +1,3 @@
+-- Leaving a ride never carried a penalty, so participants need no mark
+-- that the leader changed it after they joined.
+ALTER TABLE participants DROP COLUMN IF EXISTS ride_changed_at;
@@ -0,0 +1,21 @@
+-- Audit events can't be changed or removed, not even by a bug or a manual
+-- query. The one exception is account deletion, which rewrites the deleted
+-- user's details in existing events: it marks itself in
+-- audit_anonymizations for the length of its transaction, where no other
+-- transaction can see the row.
+CREATE TABLE audit_anonymizations (
+    user_id bigint PRIMARY KEY
+);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND EXISTS (SELECT 1 FROM audit_anonymizations) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit events are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
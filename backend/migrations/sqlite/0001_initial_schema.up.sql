@@ -0,0 +1,106 @@
+-- SQLite schema for local development. SQLite can't add constraints to an
+-- existing table, so the keys that Postgres gets in 0002 are declared here.
+
+CREATE TABLE users (
+    id           integer PRIMARY KEY AUTOINCREMENT,
+    name         varchar(100) NOT NULL,
+    email        varchar(100) NOT NULL,
+    phone        varchar(15)  NOT NULL,
+    gender       varchar(10),
+    firebase_uid varchar(100) NOT NULL,
+    locale       varchar(10) DEFAULT 'en',
+    is_admin     boolean DEFAULT false,
+    created_at   datetime,
+    updated_at   datetime
+);
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+CREATE UNIQUE INDEX idx_users_firebase_uid ON users (firebase_uid);
+
+CREATE TABLE rides (
+    id           integer PRIMARY KEY AUTOINCREMENT,
+    leader_id    integer REFERENCES users (id),
+    origin       text,
+    destination  text,
+    date         text,
+    time         text,
+    seats        integer,
+    seats_filled integer,
+    price        real,
+    created_at   datetime,
+    updated_at   datetime
+);
+
+CREATE TABLE requests (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    ride_id    integer NOT NULL REFERENCES rides (id) ON DELETE CASCADE,
+    user_id    text    NOT NULL REFERENCES users (firebase_uid) ON UPDATE CASCADE,
+    status     text    NOT NULL,
+    revoked_at datetime DEFAULT NULL,
+    created_at datetime,
+    updated_at datetime,
+    CONSTRAINT uq_requests_ride_user UNIQUE (ride_id, user_id)
+);
+
+CREATE TABLE participants (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    ride_id    integer NOT NULL REFERENCES rides (id) ON DELETE CASCADE,
+    user_id    text    NOT NULL REFERENCES users (firebase_uid) ON UPDATE CASCADE,
+    joined_at  datetime DEFAULT CURRENT_TIMESTAMP,
+    created_at datetime,
+    updated_at datetime,
+    CONSTRAINT uq_participants_ride_user UNIQUE (ride_id, user_id)
+);
+
+-- Notifications outlive their ride on purpose, so notifications.ride_id has no foreign key
+CREATE TABLE notifications (
+    id           integer PRIMARY KEY AUTOINCREMENT,
+    user_id      text         NOT NULL REFERENCES users (firebase_uid) ON UPDATE CASCADE,
+    title        varchar(200) NOT NULL,
+    message      text         NOT NULL,
+    type         varchar(50)  NOT NULL,
+    ride_id      integer      NOT NULL,
+    is_read      boolean DEFAULT false,
+    template_key varchar(100),
+    payload      text,
+    created_at   datetime,
+    updated_at   datetime
+);
+
+CREATE TABLE audit_events (
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    actor_uid   varchar(100),
+    subject_uid varchar(100),
+    action      varchar(50) NOT NULL,
+    target_type varchar(30) NOT NULL,
+    target_id   integer,
+    ride_id     integer,
+    before      text,
+    after       text,
+    request_id  varchar(64),
+    created_at  datetime
+);
+CREATE INDEX idx_audit_events_actor_uid ON audit_events (actor_uid);
+CREATE INDEX idx_audit_events_subject_uid ON audit_events (subject_uid);
+CREATE INDEX idx_audit_events_action ON audit_events (action);
+CREATE INDEX idx_audit_events_ride_id ON audit_events (ride_id);
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at);
+
+CREATE TABLE idempotency_records (
+    id           integer PRIMARY KEY AUTOINCREMENT,
+    user_uid     varchar(100) NOT NULL,
+    key          varchar(255) NOT NULL,
+    request_hash varchar(64)  NOT NULL,
+    status_code  integer      NOT NULL DEFAULT 0,
+    content_type varchar(100),
+    body         blob,
+    expires_at   datetime,
+    created_at   datetime
+);
+CREATE UNIQUE INDEX idx_idempotency_user_key ON idempotency_records (user_uid, key);
+CREATE INDEX idx_idempotency_records_expires_at ON idempotency_records (expires_at);
+
+CREATE TABLE rate_limit_buckets (
+    key        varchar(200) PRIMARY KEY,
+    tokens     real NOT NULL,
+    updated_at datetime
+);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_notifications_user_created;
+DROP INDEX IF EXISTS idx_notifications_user_read;
+DROP INDEX IF EXISTS idx_participants_user;
+DROP INDEX IF EXISTS idx_requests_ride_status;
+DROP INDEX IF EXISTS idx_requests_user_status;
+DROP INDEX IF EXISTS idx_rides_date;
+DROP INDEX IF EXISTS idx_rides_route_date;
+DROP INDEX IF EXISTS idx_rides_leader_date;
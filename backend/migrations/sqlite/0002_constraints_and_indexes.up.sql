@@ -0,0 +1,10 @@
+-- Indexes matching the Postgres schema. The constraints are declared in 0001.
+
+CREATE INDEX idx_rides_leader_date ON rides (leader_id, date);
+CREATE INDEX idx_rides_route_date ON rides (origin, destination, date);
+CREATE INDEX idx_rides_date ON rides (date);
+CREATE INDEX idx_requests_user_status ON requests (user_id, status);
+CREATE INDEX idx_requests_ride_status ON requests (ride_id, status);
+CREATE INDEX idx_participants_user ON participants (user_id);
+CREATE INDEX idx_notifications_user_read ON notifications (user_id, is_read);
+CREATE INDEX idx_notifications_user_created ON notifications (user_id, created_at DESC);
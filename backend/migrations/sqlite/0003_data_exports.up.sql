@@ -0,0 +1,16 @@
+-- Personal data exports requested through GET /user/export. The archive is
+-- cleared once it has been downloaded or has expired; the row stays as history.
+CREATE TABLE data_exports (
+    id            integer PRIMARY KEY AUTOINCREMENT,
+    user_uid      varchar(100) NOT NULL,
+    status        varchar(20)  NOT NULL,
+    error         text,
+    archive       blob,
+    size          integer NOT NULL DEFAULT 0,
+    expires_at    datetime,
+    downloaded_at datetime,
+    created_at    datetime,
+    updated_at    datetime
+);
+CREATE INDEX idx_data_exports_user_uid ON data_exports (user_uid);
+CREATE INDEX idx_data_exports_status ON data_exports (status);
@@ -0,0 +1,5 @@
+-- Account deletion (DELETE /user). deletion_scheduled_at is set while the
+-- grace period runs; deleted_at once the account has been anonymized.
+ALTER TABLE users ADD COLUMN deletion_scheduled_at datetime;
+ALTER TABLE users ADD COLUMN deleted_at datetime;
+CREATE INDEX idx_users_deletion_scheduled_at ON users (deletion_scheduled_at);
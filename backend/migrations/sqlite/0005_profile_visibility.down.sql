@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN phone_visibility;
+ALTER TABLE users DROP COLUMN gender_visibility;
+ALTER TABLE users DROP COLUMN name_visibility;
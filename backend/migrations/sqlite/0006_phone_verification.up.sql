@@ -0,0 +1,16 @@
+-- Phone verification by SMS code. SQLite doesn't enforce varchar lengths, so
+-- users.phone needs no change for E.164 numbers.
+ALTER TABLE users ADD COLUMN phone_verified_at datetime;
+ALTER TABLE rides ADD COLUMN require_verified_phone boolean NOT NULL DEFAULT false;
+
+CREATE TABLE phone_verifications (
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    user_uid    varchar(100) NOT NULL,
+    phone       varchar(20)  NOT NULL,
+    code_hash   varchar(64)  NOT NULL,
+    attempts    integer NOT NULL DEFAULT 0,
+    expires_at  datetime NOT NULL,
+    verified_at datetime,
+    created_at  datetime
+);
+CREATE INDEX idx_phone_verifications_user_uid ON phone_verifications (user_uid);
@@ -0,0 +1,15 @@
+-- Sign-up email policy. Accounts that break it are flagged rather than
+-- blocked; admins can allow or deny single addresses.
+ALTER TABLE users ADD COLUMN email_flag varchar(30) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN email_flagged_at datetime;
+
+CREATE TABLE email_access_overrides (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    email      varchar(100) NOT NULL,
+    access     varchar(10)  NOT NULL,
+    reason     text,
+    created_by varchar(100),
+    created_at datetime,
+    updated_at datetime
+);
+CREATE UNIQUE INDEX idx_email_access_overrides_email ON email_access_overrides (email);
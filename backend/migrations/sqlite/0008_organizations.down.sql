@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_notifications_organization_id;
+ALTER TABLE notifications DROP COLUMN organization_id;
+DROP INDEX IF EXISTS idx_rides_organization_id;
+ALTER TABLE rides DROP COLUMN organization_id;
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,30 @@
+-- Organizations (campuses, companies) sharing one deployment. Rides with an
+-- organization_id are only visible to its members; the rest form the public pool.
+CREATE TABLE organizations (
+    id                       integer PRIMARY KEY AUTOINCREMENT,
+    slug                     varchar(50)  NOT NULL,
+    name                     varchar(100) NOT NULL,
+    email_domains            text,
+    allowed_locations        text,
+    request_cooldown_minutes integer,
+    created_at               datetime,
+    updated_at               datetime
+);
+CREATE UNIQUE INDEX idx_organizations_slug ON organizations (slug);
+
+CREATE TABLE memberships (
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    organization_id integer      NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_uid        varchar(100) NOT NULL REFERENCES users (firebase_uid) ON UPDATE CASCADE,
+    role            varchar(20)  NOT NULL DEFAULT 'member',
+    created_at      datetime
+);
+CREATE UNIQUE INDEX idx_memberships_org_user ON memberships (organization_id, user_uid);
+CREATE INDEX idx_memberships_user_uid ON memberships (user_uid);
+
+ALTER TABLE rides ADD COLUMN organization_id integer REFERENCES organizations (id);
+CREATE INDEX idx_rides_organization_id ON rides (organization_id);
+
+-- Notifications outlive their ride, so they keep the organization without a foreign key
+ALTER TABLE notifications ADD COLUMN organization_id integer;
+CREATE INDEX idx_notifications_organization_id ON notifications (organization_id);
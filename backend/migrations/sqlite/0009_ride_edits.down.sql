@@ -0,0 +1 @@
+ALTER TABLE participants DROP COLUMN ride_changed_at;
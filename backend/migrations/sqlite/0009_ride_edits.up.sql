@@ -0,0 +1,3 @@
+-- Editing posted rides. Participants of a ride whose date, route or time
+-- changed substantially since they joined may leave it without penalty.
+ALTER TABLE participants ADD COLUMN ride_changed_at datetime;
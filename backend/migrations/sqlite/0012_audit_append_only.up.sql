@@ -0,0 +1,20 @@
+-- Audit events can't be changed or removed, not even by a bug or a manual
+-- query. The one exception is account deletion, which rewrites the deleted
+-- user's details in existing events: it marks itself in
+-- audit_anonymizations for the length of its transaction.
+CREATE TABLE audit_anonymizations (
+    user_id integer PRIMARY KEY
+);
+
+CREATE TRIGGER audit_events_no_update
+BEFORE UPDATE ON audit_events
+WHEN NOT EXISTS (SELECT 1 FROM audit_anonymizations)
+BEGIN
+    SELECT RAISE(ABORT, 'audit events are append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete
+BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit events are append-only');
+END;
@@ -0,0 +1,82 @@
+package main
+
+import "fmt"
+
+// notificationTemplate describes how a notification is rendered from its payload.
+// The title and message live in the locale catalogs under "notification.<key>.title"
+// and "notification.<key>.message".
+type notificationTemplate struct {
+	Type   string // Value stored in Notification.Type, used by clients to pick an icon
+	Plural bool   // Message has ".one"/".other" forms chosen by the payload's participant count
+}
+
+// notificationTemplates maps template keys to their definitions
+var notificationTemplates = map[string]notificationTemplate{
+	"join_request":               {Type: "join_request"},
+	"request_approved":           {Type: "request_approved"},
+	"participant_removed":        {Type: "participant_removed"},
+	"participant_cancelled":      {Type: "participant_cancelled"},
+	"ride_cancelled":             {Type: "ride_cancelled"},
+	"ride_completed.participant": {Type: "ride_completed", Plural: true},
+	"ride_completed.leader":      {Type: "ride_completed", Plural: true},
+	"ride_updated":               {Type: "ride_updated"},
+	"ride_updated.significant":   {Type: "ride_updated"},
+	"request_released":           {Type: "request_released"},
+	"leadership_offered":         {Type: "leadership_offered"},
+	"leadership_accepted":        {Type: "leadership_transferred"},
+	"leadership_declined":        {Type: "leadership_declined"},
+	"leader_changed":             {Type: "leadership_transferred"},
+}
+
+// renderNotification localizes the title and message of a template for locale
+func renderNotification(locale, templateKey string, p NotificationPayload) (string, string) {
+	tmpl := notificationTemplates[templateKey]
+
+	args := MessageArgs{"actor": p.ActorName}
+	if p.Ride != nil {
+		args["origin"] = p.Ride.Origin
+		args["destination"] = p.Ride.Destination
+		args["when"] = formatRideDateTime(locale, p.Ride.Date, p.Ride.Time)
+	}
+	if len(p.Changes) > 0 {
+		args["changes"] = rideChangesText(locale, p.Changes)
+	}
+	if p.Reason != "" {
+		args["reason"] = localize(locale, "notification_reason."+p.Reason, args)
+	}
+
+	prefix := "notification." + templateKey
+	title := localize(locale, prefix+".title", args)
+	if tmpl.Plural {
+		return title, localizePlural(locale, prefix+".message", p.ParticipantCount, args)
+	}
+	return title, localize(locale, prefix+".message", args)
+}
+
+// notificationActions returns the endpoints the recipient can act on from this notification
+func notificationActions(n Notification, p NotificationPayload) map[string]NotificationAction {
+	switch n.TemplateKey {
+	case "join_request":
+		if p.RequestID == 0 {
+			return nil
+		}
+		return map[string]NotificationAction{
+			"approve": {Method: "POST", Href: fmt.Sprintf("/v1/ride/%d/approve/%d", n.RideID, p.RequestID)},
+			"reject":  {Method: "POST", Href: fmt.Sprintf("/v1/ride/%d/reject/%d", n.RideID, p.RequestID)},
+		}
+	case "request_approved":
+		return map[string]NotificationAction{
+			"join": {Method: "POST", Href: fmt.Sprintf("/v1/ride/%d/join-ride", n.RideID)},
+		}
+	case "leadership_offered":
+		return map[string]NotificationAction{
+			"accept":  {Method: "POST", Href: fmt.Sprintf("/v1/ride/%d/accept-leadership", n.RideID)},
+			"decline": {Method: "POST", Href: fmt.Sprintf("/v1/ride/%d/decline-leadership", n.RideID)},
+		}
+	case "ride_updated.significant":
+		return map[string]NotificationAction{
+			"leave": {Method: "DELETE", Href: fmt.Sprintf("/v1/user/cancel-ride/%d", n.RideID)},
+		}
+	}
+	return nil
+}
@@ -1,84 +1,216 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // Notification represents a notification sent to a user
 type Notification struct {
-	ID        uint   `gorm:"primaryKey"`
-	UserID    string `gorm:"not null" json:"-"` // Firebase UID of the recipient - hidden from JSON
-	Title     string `gorm:"type:varchar(200);not null"`
-	Message   string `gorm:"type:text;not null"`
-	Type      string `gorm:"type:varchar(50);not null"` // "participant_removed", "ride_cancelled"
-	RideID    uint   `gorm:"not null"`
-	IsRead    bool   `gorm:"default:false"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             uint   `gorm:"primaryKey"`
+	UserID         string `gorm:"not null" json:"-"`          // Firebase UID of the recipient - hidden from JSON
+	Title          string `gorm:"type:varchar(200);not null"` // Only set on legacy rows, new rows are rendered from TemplateKey
+	Message        string `gorm:"type:text;not null"`         // Only set on legacy rows, new rows are rendered from TemplateKey
+	Type           string `gorm:"type:varchar(50);not null"`  // "participant_removed", "ride_cancelled"
+	TemplateKey    string `gorm:"type:varchar(100)"`          // Key into notificationTemplates, e.g. "ride_completed.leader"
+	Payload        string `gorm:"type:text"`                  // JSON-encoded NotificationPayload
+	RideID         uint   `gorm:"not null"`
+	OrganizationID *uint  `gorm:"index"` // Organization of the ride, nil for the public pool
+	IsRead         bool   `gorm:"default:false"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
-// Create a notification and save it to database
-func createNotification(userID string, title, message, notificationType string, rideID uint) error {
+// RideSnapshot captures the ride as it was when a notification was created,
+// so the notification still makes sense after the ride is edited or deleted
+type RideSnapshot struct {
+	ID             uint   `json:"id"`
+	Origin         string `json:"origin"`
+	Destination    string `json:"destination"`
+	Date           string `json:"date"`
+	Time           string `json:"time"`
+	OrganizationID *uint  `json:"organization_id,omitempty"`
+}
+
+// NotificationPayload holds the typed data a notification is about
+type NotificationPayload struct {
+	ActorUserID      uint          `json:"actor_user_id,omitempty"` // User who triggered the notification
+	ActorName        string        `json:"actor_name,omitempty"`
+	Ride             *RideSnapshot `json:"ride,omitempty"`
+	RequestID        uint          `json:"request_id,omitempty"`
+	Reason           string        `json:"reason,omitempty"` // One of the notification reasons below
+	ParticipantCount int           `json:"participant_count,omitempty"`
+	Changes          []RideChange  `json:"changes,omitempty"` // Fields changed by the ride's leader
+}
+
+// Reasons a notification gives for what happened to the recipient, rendered
+// from "notification_reason.<reason>" in the locale catalogs
+const (
+	reasonRemovedByLeader      = "removed_by_leader"
+	reasonRideEdited           = "ride_edited"
+	reasonLeaderCancelled      = "leader_cancelled"
+	reasonLeaderDeletedAccount = "leader_deleted_account"
+	reasonDateConflict         = "date_conflict"
+)
+
+// NotificationAction is an endpoint the recipient can call straight from the notification
+type NotificationAction struct {
+	Method string `json:"method"`
+	Href   string `json:"href"`
+}
+
+// newRideSnapshot copies the fields of a ride that notifications refer to
+func newRideSnapshot(ride Ride) *RideSnapshot {
+	return &RideSnapshot{
+		ID:             ride.ID,
+		Origin:         ride.Origin,
+		Destination:    ride.Destination,
+		Date:           ride.Date,
+		Time:           ride.Time,
+		OrganizationID: ride.OrganizationID,
+	}
+}
+
+// Create a notification and save it with db, which may be a transaction
+func createNotification(db *gorm.DB, userID string, templateKey string, rideID uint, payload NotificationPayload) error {
+	tmpl, ok := notificationTemplates[templateKey]
+	if !ok {
+		return fmt.Errorf("unknown notification template %q", templateKey)
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
 	notification := Notification{
-		UserID:    userID,
-		Title:     title,
-		Message:   message,
-		Type:      notificationType,
-		RideID:    rideID,
-		IsRead:    false,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		UserID:      userID,
+		Type:        tmpl.Type,
+		TemplateKey: templateKey,
+		Payload:     string(encoded),
+		RideID:      rideID,
+		IsRead:      false,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
 	}
 
-	if err := DB.Create(&notification).Error; err != nil {
+	if payload.Ride != nil {
+		notification.OrganizationID = payload.Ride.OrganizationID
+	}
+
+	if err := db.Create(&notification).Error; err != nil {
 		return err
 	}
 
+	notificationsCreated.WithLabelValues(templateKey).Inc()
 	return nil
 }
 
-// GET /user/notifications - Get all notifications for the authenticated user
+// decodePayload returns the structured payload of a notification (empty for legacy rows)
+func (n Notification) decodePayload() NotificationPayload {
+	var payload NotificationPayload
+	if n.Payload != "" {
+		if err := json.Unmarshal([]byte(n.Payload), &payload); err != nil {
+			slog.Error("failed to decode notification payload", "notification_id", n.ID, "error", err)
+		}
+	}
+	return payload
+}
+
+// render returns the title and message of a notification in locale, falling
+// back to the stored text for rows created before templates existed
+func (n Notification) render(locale string, payload NotificationPayload) (string, string) {
+	if _, ok := notificationTemplates[n.TemplateKey]; !ok {
+		return n.Title, n.Message
+	}
+	return renderNotification(locale, n.TemplateKey, payload)
+}
+
+// notificationScope limits a notifications query to one organization's rides
+// when orgID is set
+func notificationScope(orgID *uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if orgID == nil {
+			return db
+		}
+		return db.Where("organization_id = ?", *orgID)
+	}
+}
+
+// GET /user/notifications[?organization_id=] - Get all notifications for the authenticated user
 func GetUserNotifications(c *gin.Context) {
 	userID := c.MustGet("uid").(string)
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
 
 	var notifications []Notification
-	if err := DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&notifications).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+	if err := dbFor(c).Where("user_id = ?", userID).Scopes(notificationScope(orgID)).Order("created_at DESC").Find(&notifications).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_notifications_failed")
+		return
+	}
+
+	// Load the rides still around in one query; deleted ones fall back to the payload snapshot
+	rideIDs := make([]uint, 0, len(notifications))
+	for _, n := range notifications {
+		rideIDs = append(rideIDs, n.RideID)
+	}
+	rides, err := ridesByID(dbFor(c), rideIDs)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_notifications_failed")
 		return
 	}
 
 	// Build response with ride details
-	var response []map[string]interface{}
+	locale := requestLocale(c)
+	response := make([]NotificationResponse, 0, len(notifications))
 	for _, n := range notifications {
-		entry := map[string]interface{}{
-			"id":         n.ID,
-			"title":      n.Title,
-			"message":    n.Message,
-			"type":       n.Type,
-			"ride_id":    n.RideID,
-			"is_read":    n.IsRead,
-			"created_at": n.CreatedAt,
+		payload := n.decodePayload()
+		title, message := n.render(locale, payload)
+
+		entry := NotificationResponse{
+			ID:             n.ID,
+			Title:          title,
+			Message:        message,
+			Type:           n.Type,
+			TemplateKey:    n.TemplateKey,
+			Payload:        payload,
+			Actions:        notificationActions(n, payload),
+			RideID:         n.RideID,
+			OrganizationID: n.OrganizationID,
+			IsRead:         n.IsRead,
+			CreatedAt:      n.CreatedAt,
 		}
 
-		// Try to get ride details, but don't skip notification if ride doesn't exist
-		var ride Ride
-		if err := DB.First(&ride, "id = ?", n.RideID).Error; err == nil {
+		// Include ride details, but don't skip notification if ride doesn't exist
+		if ride, ok := rides[n.RideID]; ok {
 			// Ride exists - include full details
-			entry["origin"] = ride.Origin
-			entry["destination"] = ride.Destination
-			entry["date"] = ride.Date
-			entry["time"] = ride.Time
-			entry["ride_status"] = "active"
+			entry.Origin = ride.Origin
+			entry.Destination = ride.Destination
+			entry.Date = ride.Date
+			entry.Time = ride.Time
+			entry.RideStatus = "active"
+		} else if payload.Ride != nil {
+			// Ride was deleted - fall back to the snapshot taken when the notification was sent
+			entry.Origin = payload.Ride.Origin
+			entry.Destination = payload.Ride.Destination
+			entry.Date = payload.Ride.Date
+			entry.Time = payload.Ride.Time
+			entry.RideStatus = "deleted"
 		} else {
 			// Ride was deleted - show limited info for historical context
-			entry["origin"] = "Unknown"
-			entry["destination"] = "Unknown"
-			entry["date"] = "Unknown"
-			entry["time"] = "Unknown"
-			entry["ride_status"] = "deleted"
+			entry.Origin = "Unknown"
+			entry.Destination = "Unknown"
+			entry.Date = "Unknown"
+			entry.Time = "Unknown"
+			entry.RideStatus = "deleted"
 		}
 
 		response = append(response, entry)
@@ -93,52 +225,61 @@ func MarkNotificationAsRead(c *gin.Context) {
 	userID := c.MustGet("uid").(string)
 
 	// Update notification as read only if it belongs to the authenticated user
-	result := DB.Model(&Notification{}).
+	result := dbFor(c).Model(&Notification{}).
 		Where("id = ? AND user_id = ?", notificationID, userID).
 		Update("is_read", true)
 
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notification as read"})
+		respondError(c, http.StatusInternalServerError, "error.mark_notification_read_failed")
 		return
 	}
 
 	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		respondError(c, http.StatusNotFound, "error.notification_not_found")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+	c.JSON(http.StatusOK, MessageResponse{Message: tr(c, "message.notification_marked_read")})
 }
 
-// GET /user/notifications/unread-count - Get count of unread notifications
+// GET /user/notifications/unread-count[?organization_id=] - Get count of unread notifications
 func GetUnreadNotificationCount(c *gin.Context) {
 	userID := c.MustGet("uid").(string)
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
 
 	var count int64
-	if err := DB.Model(&Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+	if err := dbFor(c).Model(&Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Scopes(notificationScope(orgID)).Count(&count).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.count_notifications_failed")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+	c.JSON(http.StatusOK, UnreadCountResponse{UnreadCount: count})
 }
 
-// PUT /user/notifications/mark-all-read - Mark all notifications as read for the user
+// PUT /user/notifications/mark-all-read[?organization_id=] - Mark all notifications as read for the user
 func MarkAllNotificationsAsRead(c *gin.Context) {
 	userID := c.MustGet("uid").(string)
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
 
 	// Update all unread notifications for this user
-	result := DB.Model(&Notification{}).
+	result := dbFor(c).Model(&Notification{}).
 		Where("user_id = ? AND is_read = ?", userID, false).
+		Scopes(notificationScope(orgID)).
 		Update("is_read", true)
 
 	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read"})
+		respondError(c, http.StatusInternalServerError, "error.mark_notifications_read_failed")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":       "All notifications marked as read",
-		"updated_count": result.RowsAffected,
+	c.JSON(http.StatusOK, MarkAllReadResponse{
+		Message:      tr(c, "message.all_notifications_marked_read"),
+		UpdatedCount: result.RowsAffected,
 	})
 }
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPIValidation enables OpenAPIValidationMiddleware on every route (OPENAPI_VALIDATE=true)
+var openAPIValidation bool
+
+// openAPIDocument is generated from apiRoutes by InitOpenAPI
+var openAPIDocument gin.H
+
+// openAPIComponents holds the DTO schemas referenced from openAPIDocument
+var openAPIComponents map[string]*openAPISchema
+
+// unversionedRoutes are served outside the API and deliberately left out of the spec
+var unversionedRoutes = map[string]bool{
+	"/openapi.json": true,
+	"/metrics":      true,
+	"/healthz":      true,
+	"/readyz":       true,
+}
+
+// pathParamSchemas describes the path parameters used in apiRoutes; any other parameter is a plain string
+var pathParamSchemas = map[string]*openAPISchema{
+	"rideID":         {Type: "integer", Format: "uint"},
+	"requestID":      {Type: "integer", Format: "uint"},
+	"participantID":  {Type: "integer", Format: "uint"},
+	"notificationID": {Type: "integer", Format: "uint"},
+	"exportID":       {Type: "integer", Format: "uint"},
+	"overrideID":     {Type: "integer", Format: "uint"},
+	"orgID":          {Type: "integer", Format: "uint"},
+	"membershipID":   {Type: "integer", Format: "uint"},
+	"date":           {Type: "string", Format: "date"},
+}
+
+var ginParamPattern = regexp.MustCompile(`:(\w+)`)
+
+// openAPISchema is the subset of the OpenAPI schema object the generator emits
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// InitOpenAPI reads OPENAPI_VALIDATE and builds the OpenAPI document from the route table
+func InitOpenAPI() error {
+	openAPIValidation = config.OpenAPIValidate
+
+	openAPIDocument, openAPIComponents = buildOpenAPIDocument(apiRoutes)
+	return nil
+}
+
+// GET /openapi.json - OpenAPI 3 description of the /v1 API
+func ServeOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, openAPIDocument)
+}
+
+// openAPIPath converts a gin path such as "/ride/:rideID" to "/ride/{rideID}"
+func openAPIPath(ginPath string) string {
+	return ginParamPattern.ReplaceAllString(ginPath, "{$1}")
+}
+
+// checkOpenAPICoverage returns an error naming every registered route that is
+// missing from the OpenAPI document. Unversioned aliases are checked against
+// their /v1 path.
+func checkOpenAPICoverage(routes gin.RoutesInfo) error {
+	paths := openAPIDocument["paths"].(gin.H)
+
+	var missing []string
+	for _, route := range routes {
+		if unversionedRoutes[route.Path] {
+			continue
+		}
+		path := openAPIPath(strings.TrimPrefix(route.Path, "/v1"))
+		operations, ok := paths[path].(gin.H)
+		if !ok || operations[strings.ToLower(route.Method)] == nil {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("routes missing from the OpenAPI spec: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// buildOpenAPIDocument generates the document and its component schemas from the route table
+func buildOpenAPIDocument(routes []routeSpec) (gin.H, map[string]*openAPISchema) {
+	components := map[string]*openAPISchema{}
+	paths := gin.H{}
+
+	for _, route := range routes {
+		operation := gin.H{
+			"operationId": runtimeFuncName(route.Handler),
+			"summary":     route.Summary,
+			"tags":        []string{route.Tag},
+		}
+
+		var parameters []gin.H
+		for _, match := range ginParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			schema, ok := pathParamSchemas[match[1]]
+			if !ok {
+				schema = &openAPISchema{Type: "string"}
+			}
+			parameters = append(parameters, gin.H{"name": match[1], "in": "path", "required": true, "schema": schema})
+		}
+		for _, param := range route.Query {
+			parameter := gin.H{
+				"name":     param.Name,
+				"in":       "query",
+				"required": param.Required,
+				"schema":   &openAPISchema{Type: param.Type, Format: param.Format},
+			}
+			if param.Description != "" {
+				parameter["description"] = param.Description
+			}
+			parameters = append(parameters, parameter)
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+
+		if route.Request != nil {
+			operation["requestBody"] = gin.H{
+				"required": true,
+				"content":  gin.H{"application/json": gin.H{"schema": schemaForType(reflect.TypeOf(route.Request), components)}},
+			}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		var content gin.H
+		if route.Produces != "" {
+			content = gin.H{route.Produces: gin.H{"schema": &openAPISchema{Type: "string", Format: "binary"}}}
+		} else {
+			content = gin.H{"application/json": gin.H{"schema": schemaForType(reflect.TypeOf(route.Response), components)}}
+		}
+		operation["responses"] = gin.H{
+			strconv.Itoa(status): gin.H{
+				"description": http.StatusText(status),
+				"content":     content,
+			},
+			"default": gin.H{
+				"description": "Error",
+				"content":     gin.H{"application/json": gin.H{"schema": schemaForType(reflect.TypeOf(APIError{}), components)}},
+			},
+		}
+
+		switch route.Auth {
+		case "public":
+		case "optional":
+			operation["security"] = []gin.H{{"firebaseAuth": []string{}}, {}}
+		default:
+			operation["security"] = []gin.H{{"firebaseAuth": []string{}}}
+		}
+
+		path := openAPIPath(route.Path)
+		if _, ok := paths[path]; !ok {
+			paths[path] = gin.H{}
+		}
+		paths[path].(gin.H)[strings.ToLower(route.Method)] = operation
+	}
+
+	document := gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":       "Brocab API",
+			"version":     "1",
+			"description": "The same routes are also served without the /v1 prefix for older clients; those responses carry a Deprecation header.",
+		},
+		"servers": []gin.H{{"url": "/v1"}},
+		"paths":   paths,
+		"components": gin.H{
+			"schemas": components,
+			"securitySchemes": gin.H{
+				"firebaseAuth": gin.H{"type": "http", "scheme": "bearer", "bearerFormat": "Firebase ID token"},
+			},
+		},
+	}
+	return document, components
+}
+
+// runtimeFuncName returns the bare name of a handler function, e.g. "AddRide"
+func runtimeFuncName(handler gin.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// schemaForType maps a Go type to a schema, registering named structs as components
+func schemaForType(t reflect.Type, components map[string]*openAPISchema) *openAPISchema {
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaForType(t.Elem(), components)
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: schemaForType(t.Elem(), components)}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: schemaForType(t.Elem(), components)}
+	case reflect.Interface:
+		return &openAPISchema{}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return &openAPISchema{Type: "string", Format: "date-time"}
+		}
+		ref := &openAPISchema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := components[t.Name()]; ok {
+			return ref
+		}
+
+		schema := &openAPISchema{Type: "object", Properties: map[string]*openAPISchema{}}
+		components[t.Name()] = schema // Registered before the fields so recursive types terminate
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			fieldSchema := schemaForType(field.Type, components)
+			binding := field.Tag.Get("binding")
+			if strings.Contains(binding, "email") {
+				fieldSchema.Format = "email"
+			}
+			if strings.Contains(binding, "required") {
+				schema.Required = append(schema.Required, name)
+			}
+			schema.Properties[name] = fieldSchema
+		}
+		return ref
+	}
+	return &openAPISchema{}
+}
+
+// OpenAPIValidationMiddleware rejects requests whose path parameters, query or
+// JSON body don't match the route's OpenAPI description
+func OpenAPIValidationMiddleware(route routeSpec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var problems []string
+
+		for _, param := range c.Params {
+			if schema, ok := pathParamSchemas[param.Key]; ok {
+				if problem := validateStringValue(param.Value, schema); problem != "" {
+					problems = append(problems, "path "+param.Key+": "+problem)
+				}
+			}
+		}
+
+		for _, param := range route.Query {
+			value, present := c.GetQuery(param.Name)
+			if !present || value == "" {
+				if param.Required {
+					problems = append(problems, "query "+param.Name+": is required")
+				}
+				continue
+			}
+			if problem := validateStringValue(value, &openAPISchema{Type: param.Type, Format: param.Format}); problem != "" {
+				problems = append(problems, "query "+param.Name+": "+problem)
+			}
+		}
+
+		if route.Request != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				problems = append(problems, "body: could not be read")
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				problems = append(problems, "body: is not valid JSON")
+			} else {
+				schema := schemaForType(reflect.TypeOf(route.Request), openAPIComponents)
+				problems = append(problems, validateJSONValue("body", value, schema)...)
+			}
+		}
+
+		if len(problems) > 0 {
+			respondErrorWithDetails(c, http.StatusBadRequest, "error.request_validation_failed", gin.H{"errors": problems})
+			return
+		}
+		c.Next()
+	}
+}
+
+// validateStringValue checks a path or query value against a scalar schema
+func validateStringValue(value string, schema *openAPISchema) string {
+	switch {
+	case schema.Format == "uint":
+		if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+			return "must be a non-negative integer"
+		}
+	case schema.Type == "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "must be an integer"
+		}
+	case schema.Format == "date":
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			return "must be a date in YYYY-MM-DD format"
+		}
+	case schema.Format == "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "must be an RFC 3339 timestamp"
+		}
+	}
+	return ""
+}
+
+// validateJSONValue checks a decoded JSON value against schema, returning one problem per mismatch
+func validateJSONValue(path string, value interface{}, schema *openAPISchema) []string {
+	if schema.Ref != "" {
+		schema = openAPIComponents[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return []string{path + ": must not be null"}
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{path + ": must be an object"}
+		}
+		var problems []string
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				problems = append(problems, path+"."+name+": is required")
+			}
+		}
+		for name, field := range object {
+			fieldSchema := schema.Properties[name]
+			if fieldSchema == nil {
+				fieldSchema = schema.AdditionalProperties
+			}
+			if fieldSchema != nil {
+				problems = append(problems, validateJSONValue(path+"."+name, field, fieldSchema)...)
+			}
+		}
+		sort.Strings(problems)
+		return problems
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{path + ": must be an array"}
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, validateJSONValue(fmt.Sprintf("%s[%d]", path, i), item, schema.Items)...)
+		}
+		return problems
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return []string{path + ": must be a string"}
+		}
+		if problem := validateStringValue(text, schema); problem != "" {
+			return []string{path + ": " + problem}
+		}
+	case "integer":
+		number, ok := value.(json.Number)
+		if _, err := number.Int64(); !ok || err != nil {
+			return []string{path + ": must be an integer"}
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return []string{path + ": must be a number"}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{path + ": must be a boolean"}
+		}
+	}
+	return nil
+}
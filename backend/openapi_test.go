@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestOpenAPICoversRoutes fails when a route is registered on the engine
+// without being described by the generated OpenAPI document
+func TestOpenAPICoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := InitOpenAPI(); err != nil {
+		t.Fatal(err)
+	}
+	engine := gin.New()
+	registerRoutes(engine)
+
+	paths := openAPIDocument["paths"].(gin.H)
+	for _, route := range engine.Routes() {
+		if unversionedRoutes[route.Path] {
+			continue
+		}
+		// The deprecated root aliases share the /v1 entry
+		path := openAPIPath(strings.TrimPrefix(route.Path, "/v1"))
+		operations, ok := paths[path].(gin.H)
+		if !ok || operations[strings.ToLower(route.Method)] == nil {
+			t.Errorf("%s %s is missing from the OpenAPI document", route.Method, route.Path)
+		}
+	}
+}
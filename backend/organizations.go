@@ -0,0 +1,582 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Organization is a campus or company sharing one deployment. Its rides are
+// only visible to and joinable by its members; rides without an organization
+// form the public pool open to everyone.
+type Organization struct {
+	ID                     uint      `gorm:"primaryKey" json:"id"`
+	Slug                   string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"slug"` // e.g. "iitd"
+	Name                   string    `gorm:"type:varchar(100);not null" json:"name"`
+	EmailDomains           []string  `gorm:"type:text;serializer:json" json:"email_domains"`     // Users with these email domains join on sign-up
+	AllowedLocations       []string  `gorm:"type:text;serializer:json" json:"allowed_locations"` // Origins and destinations its rides may use, any if empty
+	RequestCooldownMinutes *int      `json:"request_cooldown_minutes"`                           // Overrides REQUEST_COOLDOWN for its rides
+	CreatedAt              time.Time `json:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at"`
+}
+
+// Membership places a user in an organization
+type Membership struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uint      `gorm:"not null;uniqueIndex:idx_memberships_org_user" json:"organization_id"`
+	UserUID        string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_memberships_org_user;index" json:"-"`
+	Role           string    `gorm:"type:varchar(20);not null;default:'member'" json:"role"` // "member" or "admin"
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// slugPattern matches an organization slug, e.g. "iitd" or "acme-corp"
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+// publicEmailDomains are webmail providers anyone can get an address at, so
+// they can't stand for an organization
+var publicEmailDomains = map[string]bool{
+	"gmail.com": true, "googlemail.com": true, "yahoo.com": true, "yahoo.co.in": true, "ymail.com": true,
+	"outlook.com": true, "hotmail.com": true, "live.com": true, "msn.com": true, "icloud.com": true,
+	"me.com": true, "aol.com": true, "proton.me": true, "protonmail.com": true, "zoho.com": true,
+	"gmx.com": true, "mail.com": true, "yandex.com": true, "rediffmail.com": true,
+}
+
+// Organization roles
+const (
+	orgRoleMember = "member"
+	orgRoleAdmin  = "admin" // Manages the organization's settings and members
+)
+
+// Request body for creating or updating an organization. On update, fields
+// left out keep their value.
+type OrganizationRequest struct {
+	Slug                   string    `json:"slug"` // Required on create, lower case letters, digits and dashes
+	Name                   string    `json:"name"` // Required on create
+	EmailDomains           *[]string `json:"email_domains"`
+	AllowedLocations       *[]string `json:"allowed_locations"`
+	RequestCooldownMinutes *int      `json:"request_cooldown_minutes"` // -1 resets to REQUEST_COOLDOWN
+}
+
+// Request body for adding a member or changing their role
+type MembershipRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role"` // "member" (default) or "admin"
+}
+
+// organizationQuery parses the optional organization_id query parameter,
+// responding with an error and returning false if it is invalid
+func organizationQuery(c *gin.Context) (*uint, bool) {
+	param := c.Query("organization_id")
+	if param == "" {
+		return nil, true
+	}
+	orgID, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_organization_id")
+		return nil, false
+	}
+	id := uint(orgID)
+	return &id, true
+}
+
+// membershipRole returns uid's role in orgID, or "" if they aren't a member
+func membershipRole(db *gorm.DB, orgID uint, uid string) string {
+	var membership Membership
+	if err := db.Where("organization_id = ? AND user_uid = ?", orgID, uid).First(&membership).Error; err != nil {
+		return ""
+	}
+	return membership.Role
+}
+
+// canUseRide reports whether uid may see and join ride: anyone for the
+// public pool, members for an organization's rides
+func canUseRide(db *gorm.DB, ride Ride, uid string) bool {
+	return ride.OrganizationID == nil || membershipRole(db, *ride.OrganizationID, uid) != ""
+}
+
+// visibleRides limits a rides query to the public pool and the organizations
+// of uid, or to the public pool alone when uid is ""
+func visibleRides(uid string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if uid == "" {
+			return db.Where("rides.organization_id IS NULL")
+		}
+		return db.Where("rides.organization_id IS NULL OR rides.organization_id IN (?)",
+			db.Session(&gorm.Session{NewDB: true}).Model(&Membership{}).Select("organization_id").Where("user_uid = ?", uid))
+	}
+}
+
+// sameTenant limits a rides query to those that compete with a ride in orgID
+// for the one-ride-per-day rule: that organization and the public pool. A
+// public pool ride (orgID nil) competes with every ride.
+func sameTenant(orgID *uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if orgID == nil {
+			return db
+		}
+		return db.Where("rides.organization_id IS NULL OR rides.organization_id = ?", *orgID)
+	}
+}
+
+// requestCooldownFor is how long a removed passenger waits before requesting ride again
+func requestCooldownFor(db *gorm.DB, ride Ride) time.Duration {
+	if ride.OrganizationID != nil {
+		var org Organization
+		if err := db.First(&org, *ride.OrganizationID).Error; err == nil {
+			return org.requestCooldown()
+		}
+	}
+	return config.RequestCooldown
+}
+
+// requestCooldown is how long a removed passenger waits before requesting one of org's rides again
+func (org Organization) requestCooldown() time.Duration {
+	if org.RequestCooldownMinutes != nil {
+		return time.Duration(*org.RequestCooldownMinutes) * time.Minute
+	}
+	return config.RequestCooldown
+}
+
+// organizationsByID loads the organizations with the given IDs in one query, keyed by ID
+func organizationsByID(db *gorm.DB, ids []uint) (map[uint]Organization, error) {
+	byID := make(map[uint]Organization, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+	var orgs []Organization
+	if err := db.Where("id IN ?", ids).Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	for _, org := range orgs {
+		byID[org.ID] = org
+	}
+	return byID, nil
+}
+
+// locationAllowed reports whether rides of org may start or end at location
+func (org Organization) locationAllowed(location string) bool {
+	if len(org.AllowedLocations) == 0 {
+		return true
+	}
+	for _, allowed := range org.AllowedLocations {
+		if strings.EqualFold(strings.TrimSpace(allowed), strings.TrimSpace(location)) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinOrganizationsByEmail makes user a member of every organization whose
+// domains match their email, keeping existing memberships as they are
+func joinOrganizationsByEmail(db *gorm.DB, user User) error {
+	defer invalidateRideFilters()
+	var orgs []Organization
+	if err := db.Find(&orgs).Error; err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		if !emailInDomains(user.Email, org.EmailDomains) {
+			continue
+		}
+		membership := Membership{OrganizationID: org.ID, UserUID: user.FirebaseUID, Role: orgRoleMember}
+		if err := db.Where(Membership{OrganizationID: org.ID, UserUID: user.FirebaseUID}).FirstOrCreate(&membership).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDomainMembers makes every existing user whose email matches org's domains a member
+func addDomainMembers(db *gorm.DB, org Organization) error {
+	defer invalidateRideFilters()
+	for _, domain := range org.EmailDomains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "@"))
+		var users []User
+		if err := db.Where("deleted_at IS NULL AND (LOWER(email) LIKE ? OR LOWER(email) LIKE ?)", "%@"+domain, "%."+domain).
+			Find(&users).Error; err != nil {
+			return err
+		}
+		for _, user := range users {
+			if !emailInDomains(user.Email, []string{domain}) {
+				continue // LIKE treats "_" in the domain as a wildcard
+			}
+			membership := Membership{OrganizationID: org.ID, UserUID: user.FirebaseUID, Role: orgRoleMember}
+			if err := db.Where(Membership{OrganizationID: org.ID, UserUID: user.FirebaseUID}).FirstOrCreate(&membership).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// OrganizationAdminMiddleware only lets admins of the :orgID organization, or
+// global admins, through, and stores the organization in the context. Must
+// run after FirebaseAuthMiddleware.
+func OrganizationAdminMiddleware() gin.HandlerFunc {
+	return organizationMiddleware(true)
+}
+
+// OrganizationMemberMiddleware is OrganizationAdminMiddleware for any member
+func OrganizationMemberMiddleware() gin.HandlerFunc {
+	return organizationMiddleware(false)
+}
+
+func organizationMiddleware(adminOnly bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, err := strconv.Atoi(c.Param("orgID"))
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "error.invalid_organization_id")
+			return
+		}
+		var org Organization
+		if err := dbFor(c).First(&org, orgID).Error; err != nil {
+			respondError(c, http.StatusNotFound, "error.organization_not_found")
+			return
+		}
+
+		uid := c.MustGet("uid").(string)
+		role := membershipRole(dbFor(c), org.ID, uid)
+		if role != orgRoleAdmin && (adminOnly || role == "") {
+			user, err := currentUser(c)
+			if err != nil || !user.IsAdmin {
+				if role == "" {
+					respondError(c, http.StatusNotFound, "error.organization_not_found")
+				} else {
+					respondError(c, http.StatusForbidden, "error.organization_admin_required")
+				}
+				return
+			}
+		}
+		c.Set("organization", org)
+		c.Next()
+	}
+}
+
+// applyOrganizationRequest validates req and copies it onto org
+func applyOrganizationRequest(c *gin.Context, org *Organization, req OrganizationRequest) bool {
+	if req.Slug != "" {
+		if !slugPattern.MatchString(req.Slug) {
+			respondError(c, http.StatusBadRequest, "error.invalid_organization_slug", MessageArgs{"slug": req.Slug})
+			return false
+		}
+		org.Slug = req.Slug
+	}
+	if req.Name != "" {
+		org.Name = req.Name
+	}
+	if req.EmailDomains != nil {
+		domains := []string{}
+		for _, domain := range *req.EmailDomains {
+			domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+			// A bare TLD would match everyone under it
+			if domain == "" || strings.ContainsAny(domain, "@ /") || !strings.Contains(strings.Trim(domain, "."), ".") {
+				respondError(c, http.StatusBadRequest, "error.invalid_email_domain", MessageArgs{"domain": domain})
+				return false
+			}
+			if publicEmailDomains[domain] {
+				respondError(c, http.StatusBadRequest, "error.public_email_domain", MessageArgs{"domain": domain})
+				return false
+			}
+			// An organization can narrow who joins it, not widen who signs up
+			if !emailDomainAllowed("user@" + domain) {
+				respondError(c, http.StatusBadRequest, "error.email_domain_not_allowed", MessageArgs{"domain": domain})
+				return false
+			}
+			domains = append(domains, domain)
+		}
+		org.EmailDomains = domains
+	}
+	if req.AllowedLocations != nil {
+		locations := []string{}
+		for _, location := range *req.AllowedLocations {
+			if location = strings.TrimSpace(location); location != "" {
+				locations = append(locations, location)
+			}
+		}
+		org.AllowedLocations = locations
+	}
+	if req.RequestCooldownMinutes != nil {
+		switch minutes := *req.RequestCooldownMinutes; {
+		case minutes == -1:
+			org.RequestCooldownMinutes = nil
+		case minutes >= 0:
+			org.RequestCooldownMinutes = &minutes
+		default:
+			respondError(c, http.StatusBadRequest, "error.invalid_cooldown")
+			return false
+		}
+	}
+	return true
+}
+
+// POST /admin/organizations - Create an organization (global admins only)
+func CreateOrganization(c *gin.Context) {
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_request_data")
+		return
+	}
+	if req.Slug == "" || req.Name == "" {
+		respondError(c, http.StatusBadRequest, "error.invalid_request_data")
+		return
+	}
+
+	org := Organization{EmailDomains: []string{}, AllowedLocations: []string{}}
+	if !applyOrganizationRequest(c, &org, req) {
+		return
+	}
+
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&Organization{}).Where("slug = ?", org.Slug).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errOrganizationSlugTaken
+		}
+		if err := tx.Create(&org).Error; err != nil {
+			return err
+		}
+		if err := addDomainMembers(tx, org); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "organization_created", TargetType: "organization", TargetID: org.ID, After: org})
+	})
+	if errors.Is(err, errOrganizationSlugTaken) {
+		respondError(c, http.StatusConflict, "error.organization_slug_taken", MessageArgs{"slug": org.Slug})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.save_organization_failed")
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// errOrganizationSlugTaken is returned when another organization has the slug
+var errOrganizationSlugTaken = errors.New("organization slug taken")
+
+// GET /organization/:orgID - Settings of an organization (members only)
+func GetOrganization(c *gin.Context) {
+	c.JSON(http.StatusOK, c.MustGet("organization").(Organization))
+}
+
+// PUT /organization/:orgID - Update an organization's settings (organization admins only)
+func UpdateOrganization(c *gin.Context) {
+	org := c.MustGet("organization").(Organization)
+	before := org
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_request_data")
+		return
+	}
+	if !applyOrganizationRequest(c, &org, req) {
+		return
+	}
+	// Domains enroll every matching user, so only global admins may change them
+	if !slices.Equal(org.EmailDomains, before.EmailDomains) {
+		if user, err := currentUser(c); err != nil || !user.IsAdmin {
+			respondError(c, http.StatusForbidden, "error.admin_required")
+			return
+		}
+	}
+
+	err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&Organization{}).Where("slug = ? AND id <> ?", org.Slug, org.ID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return errOrganizationSlugTaken
+		}
+		// Select("*") so a cleared cooldown is written as NULL
+		if err := tx.Model(&org).Select("*").Omit("created_at").Updates(&org).Error; err != nil {
+			return err
+		}
+		if err := addDomainMembers(tx, org); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "organization_updated", TargetType: "organization", TargetID: org.ID, Before: before, After: org})
+	})
+	if errors.Is(err, errOrganizationSlugTaken) {
+		respondError(c, http.StatusConflict, "error.organization_slug_taken", MessageArgs{"slug": org.Slug})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.save_organization_failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// GET /user/organizations - Organizations the current user belongs to
+func GetUserOrganizations(c *gin.Context) {
+	uid := c.MustGet("uid").(string)
+
+	var memberships []Membership
+	if err := dbFor(c).Where("user_uid = ?", uid).Find(&memberships).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_organizations_failed")
+		return
+	}
+
+	orgIDs := make([]uint, 0, len(memberships))
+	for _, membership := range memberships {
+		orgIDs = append(orgIDs, membership.OrganizationID)
+	}
+	orgs, err := organizationsByID(dbFor(c), orgIDs)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_organizations_failed")
+		return
+	}
+
+	response := make([]UserOrganizationResponse, 0, len(memberships))
+	for _, membership := range memberships {
+		org, ok := orgs[membership.OrganizationID]
+		if !ok {
+			continue
+		}
+		response = append(response, UserOrganizationResponse{
+			ID:       org.ID,
+			Slug:     org.Slug,
+			Name:     org.Name,
+			Role:     membership.Role,
+			JoinedAt: membership.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GET /organization/:orgID/members - Members of an organization (organization admins only)
+func GetOrganizationMembers(c *gin.Context) {
+	org := c.MustGet("organization").(Organization)
+
+	var memberships []Membership
+	if err := dbFor(c).Where("organization_id = ?", org.ID).Order("created_at").Find(&memberships).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_members_failed")
+		return
+	}
+
+	uids := make([]string, 0, len(memberships))
+	for _, membership := range memberships {
+		uids = append(uids, membership.UserUID)
+	}
+	users, err := usersByUID(dbFor(c), uids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_members_failed")
+		return
+	}
+
+	response := make([]OrganizationMemberResponse, 0, len(memberships))
+	for _, membership := range memberships {
+		user, ok := users[membership.UserUID]
+		if !ok {
+			continue
+		}
+		response = append(response, OrganizationMemberResponse{
+			ID:       membership.ID,
+			UserID:   user.ID,
+			Name:     user.Name,
+			Email:    user.Email,
+			Role:     membership.Role,
+			JoinedAt: membership.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PUT /organization/:orgID/members - Add a member or change their role (organization admins only)
+func PutOrganizationMember(c *gin.Context) {
+	org := c.MustGet("organization").(Organization)
+
+	var req MembershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_request_data")
+		return
+	}
+	if req.Role == "" {
+		req.Role = orgRoleMember
+	}
+	if req.Role != orgRoleMember && req.Role != orgRoleAdmin {
+		respondError(c, http.StatusBadRequest, "error.invalid_organization_role", MessageArgs{"role": req.Role})
+		return
+	}
+
+	var user User
+	if err := dbFor(c).Where("LOWER(email) = ? AND deleted_at IS NULL", strings.ToLower(req.Email)).First(&user).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+
+	membership := Membership{OrganizationID: org.ID, UserUID: user.FirebaseUID}
+	var before interface{}
+	err := dbFor(c).Where("organization_id = ? AND user_uid = ?", org.ID, user.FirebaseUID).First(&membership).Error
+	if err == nil {
+		before = membership
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusInternalServerError, "error.save_membership_failed")
+		return
+	}
+	membership.Role = req.Role
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&membership).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "membership_set", TargetType: "membership", TargetID: membership.ID, SubjectUID: user.FirebaseUID,
+			Before: before, After: membership})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.save_membership_failed")
+		return
+	}
+	invalidateRideFilters()
+
+	c.JSON(http.StatusOK, OrganizationMemberResponse{
+		ID:       membership.ID,
+		UserID:   user.ID,
+		Name:     user.Name,
+		Email:    user.Email,
+		Role:     membership.Role,
+		JoinedAt: membership.CreatedAt,
+	})
+}
+
+// DELETE /organization/:orgID/members/:membershipID - Remove a member (organization admins only)
+func DeleteOrganizationMember(c *gin.Context) {
+	org := c.MustGet("organization").(Organization)
+
+	membershipID, err := strconv.Atoi(c.Param("membershipID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_membership_id")
+		return
+	}
+
+	var membership Membership
+	if err := dbFor(c).Where("id = ? AND organization_id = ?", membershipID, org.ID).First(&membership).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.membership_not_found")
+		return
+	}
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&membership).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "membership_removed", TargetType: "membership", TargetID: membership.ID, SubjectUID: membership.UserUID,
+			Before: membership})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.save_membership_failed")
+		return
+	}
+	invalidateRideFilters()
+
+	c.JSON(http.StatusOK, MessageResponse{Message: tr(c, "message.member_removed", MessageArgs{"organization": org.Name})})
+}
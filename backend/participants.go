@@ -1,12 +1,13 @@
 package main
 
 import (
-	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // Participant represents users who have actually joined a ride (approved and confirmed)
@@ -24,56 +25,74 @@ func GetRideParticipants(c *gin.Context) {
 	rideIDParam := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
 	userID := c.MustGet("uid").(string)
 
 	// Check if the ride exists
-	var ride Ride
-	if err := DB.First(&ride, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	ride, err := cachedRide(dbFor(c), uint(rideID))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
+		return
+	}
+	// Organization rides are invisible to outsiders
+	if !canUseRide(dbFor(c), ride, userID) {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
 	// Get current user to check if they are the leader
-	currentUser, err := getUser(userID)
+	viewer, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
-	isLeader := ride.LeaderID == currentUser.ID
+	isLeader := ride.LeaderID == viewer.ID
 
 	// Fetch all participants for the ride
 	var participants []Participant
-	if err := DB.Where("ride_id = ?", rideID).Find(&participants).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch participants"})
+	if err := dbFor(c).Where("ride_id = ?", rideID).Find(&participants).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_participants_failed")
 		return
 	}
 
-	// Build response with participant details (phone number only for leaders)
-	var response []map[string]interface{}
+	// The leader and participants count as co-riders; phone numbers only go to
+	// the leader, within CONTACT_WINDOW of departure
+	coRider := isLeader
+	uids := make([]string, 0, len(participants))
 	for _, p := range participants {
-		user, err := getUser(p.UserID)
-		if err != nil {
-			continue // skip if user doesn't exist
+		if p.UserID == userID {
+			coRider = true
 		}
+		uids = append(uids, p.UserID)
+	}
+	contact := isLeader && contactAllowed(ride, time.Now())
 
-		entry := map[string]interface{}{
-			"participant_id": p.ID,
-			"name":           user.Name,
-			"gender":         user.Gender,
-			"joined_at":      p.JoinedAt,
-		}
+	users, err := usersByUID(dbFor(c), uids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_participants_failed")
+		return
+	}
 
-		// Only include phone number if the current user is the ride leader
-		if isLeader {
-			entry["phone"] = user.Phone
+	// Build response with participant details, as far as each participant shares them
+	response := make([]ParticipantResponse, 0, len(participants))
+	for _, p := range participants {
+		user, ok := users[p.UserID]
+		if !ok {
+			continue // skip if user doesn't exist
 		}
 
-		response = append(response, entry)
+		fields := visibleProfile(user, coRider, contact)
+		response = append(response, ParticipantResponse{
+			ParticipantID: p.ID,
+			Name:          fields.Name,
+			Gender:        fields.Gender,
+			JoinedAt:      p.JoinedAt,
+			Phone:         fields.Phone,
+		})
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -84,62 +103,76 @@ func RemoveParticipant(c *gin.Context) {
 	rideIDParam := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
 	participantIDParam := c.Param("participantID")
 	participantID, err := strconv.Atoi(participantIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid participant ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_participant_id")
 		return
 	}
 
-	userID := c.MustGet("uid").(string)
-
 	var ride Ride
-	if err := DB.First(&ride, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	if err := dbFor(c).First(&ride, "id = ?", rideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
-	user, err := getUser(userID)
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
 	if ride.LeaderID != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the leader of this ride"})
+		respondError(c, http.StatusForbidden, "error.not_ride_leader")
 		return
 	}
 
 	var participant Participant
-	if err := DB.Where("id = ? AND ride_id = ?", participantID, rideID).First(&participant).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Participant not found in this ride"})
-		return
-	}
-
-	if err := DB.Delete(&participant).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove participant"})
+	if err := dbFor(c).Where("id = ? AND ride_id = ?", participantID, rideID).First(&participant).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.participant_not_found")
 		return
 	}
 
-	if err := DB.Model(&ride).Update("seats_filled", ride.SeatsFilled-1).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ride seats"})
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&participant).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ride).Update("seats_filled", ride.SeatsFilled-1).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{
+			Action:     "participant_removed",
+			TargetType: "participant",
+			TargetID:   participant.ID,
+			RideID:     participant.RideID,
+			SubjectUID: participant.UserID,
+			Before:     participantSnapshot(participant),
+		})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.remove_participant_failed")
 		return
 	}
+	invalidateRide(ride.ID)
 
 	// Send notification to the removed participant
-	title := "Removed from Ride"
-	message := fmt.Sprintf("You have been removed from the ride from %s to %s on %s at %s",
-		ride.Origin, ride.Destination, ride.Date, ride.Time)
-	if err := createNotification(participant.UserID, title, message, "participant_removed", uint(rideID)); err != nil {
+	payload := NotificationPayload{
+		ActorUserID: user.ID,
+		ActorName:   user.Name,
+		Ride:        newRideSnapshot(ride),
+		Reason:      reasonRemovedByLeader,
+	}
+	if err := createNotification(dbFor(c), participant.UserID, "participant_removed", uint(rideID), payload); err != nil {
 		// Log error but don't fail the request
-		fmt.Printf("Failed to create notification: %v\n", err)
+		slog.ErrorContext(c.Request.Context(), "failed to create notification", "error", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Participant removed successfully"})
+	cancellations.WithLabelValues("participant_removed").Inc()
+	c.JSON(http.StatusOK, MessageResponse{Message: tr(c, "message.participant_removed")})
 }
 
 // POST /ride/:rideID/approve/:requestID - Approve a join request (gives user privilege to join)
@@ -147,61 +180,78 @@ func ApproveJoinRequest(c *gin.Context) {
 	rideIDParam := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
 	requestIDParam := c.Param("requestID")
 	requestID, err := strconv.Atoi(requestIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_request_id")
 		return
 	}
 
-	userID := c.MustGet("uid").(string)
-
 	// Check if the user is the leader of this ride
-	var ride Ride
-	if err := DB.First(&ride, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	ride, err := cachedRide(dbFor(c), uint(rideID))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
 	// Get user to find their ID for comparison
-	user, err := getUser(userID)
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
 	if ride.LeaderID != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the leader of this ride"})
+		respondError(c, http.StatusForbidden, "error.not_ride_leader")
 		return
 	}
 
 	// Find the join request
 	var request Request
-	if err := DB.Where("id = ? AND ride_id = ? AND status = ?", requestID, rideID, "pending").First(&request).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Join request not found or already processed"})
+	if err := dbFor(c).Where("id = ? AND ride_id = ? AND status = ?", requestID, rideID, "pending").First(&request).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.join_request_not_found")
 		return
 	}
 
 	// Update request status to approved (gives privilege to join)
-	if err := DB.Model(&request).Update("status", "approved").Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve request"})
+	before := requestSnapshot(request)
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&request).Update("status", "approved").Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{
+			Action:     "request_approved",
+			TargetType: "request",
+			TargetID:   request.ID,
+			RideID:     request.RideID,
+			SubjectUID: request.UserID,
+			Before:     before,
+			After:      requestSnapshot(request),
+		})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.approve_request_failed")
 		return
 	}
 
 	// Send notification to the approved user
-	title := "Join Request Approved"
-	message := fmt.Sprintf("Your request to join the ride from %s to %s on %s at %s has been approved. You can now join the ride!",
-		ride.Origin, ride.Destination, ride.Date, ride.Time)
-	if err := createNotification(request.UserID, title, message, "request_approved", uint(rideID)); err != nil {
+	payload := NotificationPayload{
+		ActorUserID: user.ID,
+		ActorName:   user.Name,
+		Ride:        newRideSnapshot(ride),
+		RequestID:   request.ID,
+	}
+	if err := createNotification(dbFor(c), request.UserID, "request_approved", uint(rideID), payload); err != nil {
 		// Log error but don't fail the request
-		fmt.Printf("Failed to create notification: %v\n", err)
+		slog.ErrorContext(c.Request.Context(), "failed to create notification", "error", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Join request approved - user can now join the ride"})
+	joinRequests.WithLabelValues("approved").Inc()
+	c.JSON(http.StatusOK, MessageResponse{Message: tr(c, "message.join_request_approved")})
 }
 
 // POST /ride/:rideID/reject/:requestID - Reject a join request
@@ -209,55 +259,69 @@ func RejectJoinRequest(c *gin.Context) {
 	rideIDParam := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
 	requestIDParam := c.Param("requestID")
 	requestID, err := strconv.Atoi(requestIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_request_id")
 		return
 	}
 
-	userID := c.MustGet("uid").(string)
-
 	// Check if the user is the leader of this ride
-	var ride Ride
-	if err := DB.First(&ride, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	ride, err := cachedRide(dbFor(c), uint(rideID))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
 	// Get user to find their ID for comparison
-	user, err := getUser(userID)
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
 	if ride.LeaderID != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the leader of this ride"})
+		respondError(c, http.StatusForbidden, "error.not_ride_leader")
 		return
 	}
 
 	// Find the join request
 	var request Request
-	if err := DB.Where("id = ? AND ride_id = ? AND status = ?", requestID, rideID, "pending").First(&request).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Join request not found or already processed"})
+	if err := dbFor(c).Where("id = ? AND ride_id = ? AND status = ?", requestID, rideID, "pending").First(&request).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.join_request_not_found")
 		return
 	}
 
 	// Update request status to revoked and set revoked timestamp
-	if err := DB.Model(&request).Updates(map[string]interface{}{
-		"status":     "revoked",
-		"revoked_at": time.Now(),
-	}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject request"})
+	before := requestSnapshot(request)
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&request).Updates(map[string]interface{}{
+			"status":     "revoked",
+			"revoked_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{
+			Action:     "request_rejected",
+			TargetType: "request",
+			TargetID:   request.ID,
+			RideID:     request.RideID,
+			SubjectUID: request.UserID,
+			Before:     before,
+			After:      requestSnapshot(request),
+		})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.reject_request_failed")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Join request rejected"})
+	joinRequests.WithLabelValues("rejected").Inc()
+	c.JSON(http.StatusOK, MessageResponse{Message: tr(c, "message.join_request_rejected")})
 }
 
 // GET /user/privileges - Get all approved ride privileges for the authenticated user
@@ -265,34 +329,43 @@ func GetUserPrivileges(c *gin.Context) {
 	userID := c.MustGet("uid").(string)
 
 	var requests []Request
-	if err := DB.Where("user_id = ? AND status = ?", userID, "approved").Find(&requests).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privileges"})
+	if err := dbFor(c).Where("user_id = ? AND status = ?", userID, "approved").Find(&requests).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_privileges_failed")
+		return
+	}
+
+	rideIDs := make([]uint, 0, len(requests))
+	for _, req := range requests {
+		rideIDs = append(rideIDs, req.RideID)
+	}
+	rides, err := ridesByID(dbFor(c), rideIDs)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_privileges_failed")
 		return
 	}
 
-	var response []map[string]interface{}
+	response := make([]PrivilegeResponse, 0, len(requests))
 	for _, req := range requests {
-		var ride Ride
-		if err := DB.First(&ride, "id = ?", req.RideID).Error; err != nil {
+		ride, ok := rides[req.RideID]
+		if !ok {
 			continue
 		}
 
 		seatsAvailable := ride.SeatsFilled < ride.Seats
 
-		entry := map[string]interface{}{
-			"request_id":      req.ID,
-			"ride_id":         ride.ID,
-			"origin":          ride.Origin,
-			"destination":     ride.Destination,
-			"date":            ride.Date,
-			"time":            ride.Time,
-			"price":           ride.Price,
-			"seats_available": ride.Seats - ride.SeatsFilled,
-			"total_seats":     ride.Seats,
-			"can_join":        seatsAvailable,
-			"approved_at":     req.UpdatedAt,
-		}
-		response = append(response, entry)
+		response = append(response, PrivilegeResponse{
+			RequestID:      req.ID,
+			RideID:         ride.ID,
+			Origin:         ride.Origin,
+			Destination:    ride.Destination,
+			Date:           ride.Date,
+			Time:           ride.Time,
+			Price:          ride.Price,
+			SeatsAvailable: ride.Seats - ride.SeatsFilled,
+			TotalSeats:     ride.Seats,
+			CanJoin:        seatsAvailable,
+			ApprovedAt:     req.UpdatedAt,
+		})
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -303,7 +376,7 @@ func JoinRideWithPrivilege(c *gin.Context) {
 	rideIDParam := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
@@ -311,30 +384,46 @@ func JoinRideWithPrivilege(c *gin.Context) {
 
 	// Check if user has approved privilege for this ride
 	var request Request
-	if err := DB.Where("ride_id = ? AND user_id = ? AND status = ?", rideID, userID, "approved").First(&request).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have privilege to join this ride"})
+	if err := dbFor(c).Where("ride_id = ? AND user_id = ? AND status = ?", rideID, userID, "approved").First(&request).Error; err != nil {
+		respondError(c, http.StatusForbidden, "error.no_privilege")
 		return
 	}
 
 	var ride Ride
-	if err := DB.First(&ride, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	if err := dbFor(c).First(&ride, "id = ?", rideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
 	if ride.SeatsFilled >= ride.Seats {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ride is full - no seats available"})
+		respondError(c, http.StatusBadRequest, "error.ride_full")
+		return
+	}
+
+	// The user may have left the organization since the request was approved
+	if !canUseRide(dbFor(c), ride, userID) {
+		respondError(c, http.StatusForbidden, "error.not_organization_member")
 		return
 	}
 
+	// The phone may have changed, and lost its verification, since the request was approved
+	if ride.RequireVerifiedPhone {
+		if user, err := currentUser(c); err != nil || user.PhoneVerifiedAt == nil {
+			respondError(c, http.StatusForbidden, "error.phone_not_verified")
+			return
+		}
+	}
+
 	var existingParticipant Participant
-	if err := DB.Where("ride_id = ? AND user_id = ?", rideID, userID).First(&existingParticipant).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "You are already a participant in this ride"})
+	if err := dbFor(c).Where("ride_id = ? AND user_id = ?", rideID, userID).First(&existingParticipant).Error; err == nil {
+		respondError(c, http.StatusConflict, "error.already_participant")
 		return
 	}
 
-	if err := DB.Where("user_id = ? AND status = ?", userID, "approved").Delete(&Request{}).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear other privileges"})
+	// Keep a copy of the privileges being cleared for the audit log
+	var clearedPrivileges []Request
+	if err := dbFor(c).Where("user_id = ? AND status = ?", userID, "approved").Find(&clearedPrivileges).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_privileges_failed")
 		return
 	}
 
@@ -345,20 +434,39 @@ func JoinRideWithPrivilege(c *gin.Context) {
 		JoinedAt: time.Now(),
 	}
 
-	if err := DB.Create(&participant).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join ride"})
-		return
-	}
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND status = ?", userID, "approved").Delete(&Request{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&participant).Error; err != nil {
+			return err
+		}
+		// Increase seats filled count
+		if err := tx.Model(&ride).Update("seats_filled", ride.SeatsFilled+1).Error; err != nil {
+			return err
+		}
 
-	// Increase seats filled count
-	if err := DB.Model(&ride).Update("seats_filled", ride.SeatsFilled+1).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ride seats"})
+		for _, privilege := range clearedPrivileges {
+			action := "privilege_cleared"
+			if privilege.ID == request.ID {
+				action = "privilege_used"
+			}
+			if err := recordAudit(tx, c, auditEntry{Action: action, TargetType: "request", TargetID: privilege.ID, RideID: privilege.RideID, Before: requestSnapshot(privilege)}); err != nil {
+				return err
+			}
+		}
+		return recordAudit(tx, c, auditEntry{Action: "participant_joined", TargetType: "participant", TargetID: participant.ID, RideID: participant.RideID, After: participantSnapshot(participant)})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.join_ride_failed")
 		return
 	}
+	invalidateRide(ride.ID)
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Successfully joined the ride! All other privileges have been cleared.",
-		"ride_id": rideID,
+	rideJoins.Inc()
+	c.JSON(http.StatusOK, JoinRideResponse{
+		Message: tr(c, "message.joined_ride"),
+		RideID:  rideID,
 	})
 }
 
@@ -367,7 +475,7 @@ func CancelRideParticipation(c *gin.Context) {
 	rideIDParam := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
@@ -375,70 +483,84 @@ func CancelRideParticipation(c *gin.Context) {
 
 	// First check if user has a pending request
 	var pendingRequest Request
-	if err := DB.Where("ride_id = ? AND user_id = ? AND status = ?", rideID, userID, "pending").First(&pendingRequest).Error; err == nil {
+	if err := dbFor(c).Where("ride_id = ? AND user_id = ? AND status = ?", rideID, userID, "pending").First(&pendingRequest).Error; err == nil {
 		// User has a pending request - cancel it (no notification needed)
-		if err := DB.Delete(&pendingRequest).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel request"})
+		err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&pendingRequest).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, auditEntry{Action: "request_cancelled", TargetType: "request", TargetID: pendingRequest.ID, RideID: pendingRequest.RideID, Before: requestSnapshot(pendingRequest)})
+		})
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "error.cancel_request_failed")
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Join request cancelled successfully",
-			"type":    "request_cancelled",
+		cancellations.WithLabelValues("request").Inc()
+		c.JSON(http.StatusOK, CancelParticipationResponse{
+			Message: tr(c, "message.join_request_cancelled"),
+			Type:    "request_cancelled",
 		})
 		return
 	}
 
 	// Check if user is actually a participant
 	var participant Participant
-	if err := DB.Where("ride_id = ? AND user_id = ?", rideID, userID).First(&participant).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "You have no involvement with this ride"})
+	if err := dbFor(c).Where("ride_id = ? AND user_id = ?", rideID, userID).First(&participant).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.no_involvement_with_ride")
 		return
 	}
 
 	// User is a participant - proceed with cancellation and notify leader
 	var ride Ride
-	if err := DB.First(&ride, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	if err := dbFor(c).First(&ride, "id = ?", rideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
 	// Get the cancelling user's details
-	cancellingUser, err := getUser(userID)
+	cancellingUser, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
 	// Get the ride leader's details
 	leader, err := getUser(ride.LeaderID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride leader not found"})
+		respondError(c, http.StatusNotFound, "error.ride_leader_not_found")
 		return
 	}
 
-	// Remove participant from ride
-	if err := DB.Delete(&participant).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel ride participation"})
-		return
-	}
-
-	// Update seats filled count
-	if err := DB.Model(&ride).Update("seats_filled", ride.SeatsFilled-1).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ride seats"})
+	// Remove participant from ride and free their seat
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&participant).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&ride).Update("seats_filled", ride.SeatsFilled-1).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "participant_left", TargetType: "participant", TargetID: participant.ID, RideID: participant.RideID, Before: participantSnapshot(participant)})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.cancel_participation_failed")
 		return
 	}
+	invalidateRide(ride.ID)
 
 	// Send notification to the ride leader
-	title := "Participant Cancelled"
-	message := fmt.Sprintf("%s has cancelled their participation in your ride from %s to %s on %s at %s",
-		cancellingUser.Name, ride.Origin, ride.Destination, ride.Date, ride.Time)
-	if err := createNotification(leader.FirebaseUID, title, message, "participant_cancelled", uint(rideID)); err != nil {
+	payload := NotificationPayload{
+		ActorUserID: cancellingUser.ID,
+		ActorName:   cancellingUser.Name,
+		Ride:        newRideSnapshot(ride),
+	}
+	if err := createNotification(dbFor(c), leader.FirebaseUID, "participant_cancelled", uint(rideID), payload); err != nil {
 		// Log error but don't fail the request
-		fmt.Printf("Failed to create notification: %v\n", err)
+		slog.ErrorContext(c.Request.Context(), "failed to create notification", "error", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Successfully cancelled your participation in the ride",
-		"type":    "participation_cancelled",
+	cancellations.WithLabelValues("participation").Inc()
+	c.JSON(http.StatusOK, CancelParticipationResponse{
+		Message: tr(c, "message.participation_cancelled"),
+		Type:    "participation_cancelled",
 	})
 }
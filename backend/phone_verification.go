@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PhoneVerification is a one-time code sent by SMS to prove a user owns a phone number
+type PhoneVerification struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserUID    string    `gorm:"type:varchar(100);not null;index"`
+	Phone      string    `gorm:"type:varchar(20);not null"` // E.164, e.g. "+919876543210"
+	CodeHash   string    `gorm:"type:varchar(64);not null"`
+	Attempts   int       `gorm:"not null;default:0"` // Codes tried so far, see PHONE_OTP_MAX_ATTEMPTS
+	ExpiresAt  time.Time `gorm:"not null"`
+	VerifiedAt *time.Time
+	CreatedAt  time.Time
+}
+
+var (
+	// e164Pattern matches a normalized phone number: "+", country code and subscriber number, 8-15 digits in all
+	e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+	// countryCodePattern matches a country calling code without the "+"
+	countryCodePattern = regexp.MustCompile(`^[1-9][0-9]{0,2}$`)
+)
+
+// normalizePhone converts a phone number as typed by a user to E.164. Spaces,
+// dashes, dots and parentheses are dropped, a leading "00" is read as "+" and
+// numbers without a country code get PHONE_DEFAULT_COUNTRY_CODE.
+func normalizePhone(raw string) (string, bool) {
+	var digits strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", false
+		}
+	}
+
+	phone := digits.String()
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(phone, "00"):
+		phone = "+" + phone[2:]
+	default:
+		phone = "+" + config.PhoneDefaultCountryCode + strings.TrimPrefix(phone, "0")
+	}
+	return phone, e164Pattern.MatchString(phone)
+}
+
+// hashPhoneCode hashes a verification code together with who it was sent to and where
+func hashPhoneCode(uid, phone, code string) string {
+	sum := sha256.Sum256([]byte(uid + ":" + phone + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Request body for starting a phone verification
+type StartPhoneVerificationRequest struct {
+	Phone string `json:"phone" binding:"required"` // E.164, or a national number in PHONE_DEFAULT_COUNTRY_CODE
+}
+
+// Request body for confirming a phone verification
+type ConfirmPhoneVerificationRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// POST /user/phone/verify/start - Text a verification code to a phone number
+func StartPhoneVerification(c *gin.Context) {
+	uid := c.MustGet("uid").(string)
+
+	var req StartPhoneVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_request_data")
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		respondError(c, http.StatusBadRequest, "error.invalid_phone")
+		return
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.phone_verification_failed")
+		return
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	// Starting again replaces any code sent earlier
+	if err := dbFor(c).Where("user_uid = ? AND verified_at IS NULL", uid).Delete(&PhoneVerification{}).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.phone_verification_failed")
+		return
+	}
+	verification := PhoneVerification{
+		UserUID:   uid,
+		Phone:     phone,
+		CodeHash:  hashPhoneCode(uid, phone, code),
+		ExpiresAt: time.Now().Add(config.PhoneOTPTTL),
+	}
+	if err := dbFor(c).Create(&verification).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.phone_verification_failed")
+		return
+	}
+
+	message := localize(user.Locale, "sms.phone_verification_code", MessageArgs{"code": code, "minutes": int(config.PhoneOTPTTL.Minutes())})
+	if err := smsSender.Send(c.Request.Context(), phone, message); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to send verification code", "error", err)
+		dbFor(c).Delete(&verification)
+		phoneVerifications.WithLabelValues("send_failed").Inc()
+		respondError(c, http.StatusBadGateway, "error.sms_send_failed")
+		return
+	}
+	phoneVerifications.WithLabelValues("started").Inc()
+
+	c.JSON(http.StatusAccepted, PhoneVerificationResponse{
+		Message:   tr(c, "message.phone_verification_sent", MessageArgs{"phone": phone}),
+		Phone:     phone,
+		ExpiresAt: verification.ExpiresAt,
+	})
+}
+
+// POST /user/phone/verify/confirm - Check the code and mark the phone number as verified
+func ConfirmPhoneVerification(c *gin.Context) {
+	uid := c.MustGet("uid").(string)
+
+	var req ConfirmPhoneVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_request_data")
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+
+	var verification PhoneVerification
+	err = dbFor(c).Where("user_uid = ? AND verified_at IS NULL", uid).Order("id DESC").First(&verification).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondError(c, http.StatusNotFound, "error.phone_verification_not_found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.phone_verification_failed")
+		return
+	}
+	if time.Now().After(verification.ExpiresAt) {
+		phoneVerifications.WithLabelValues("expired").Inc()
+		respondError(c, http.StatusGone, "error.phone_verification_expired")
+		return
+	}
+
+	// Count the attempt before checking the code, so concurrent guesses can't exceed the limit
+	counted := dbFor(c).Model(&PhoneVerification{}).Where("id = ? AND attempts < ?", verification.ID, config.PhoneOTPMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if counted.Error != nil {
+		respondError(c, http.StatusInternalServerError, "error.phone_verification_failed")
+		return
+	}
+	if counted.RowsAffected == 0 {
+		phoneVerifications.WithLabelValues("locked").Inc()
+		respondError(c, http.StatusTooManyRequests, "error.phone_verification_locked")
+		return
+	}
+
+	code := strings.TrimSpace(req.Code)
+	if !hmac.Equal([]byte(hashPhoneCode(uid, verification.Phone, code)), []byte(verification.CodeHash)) {
+		phoneVerifications.WithLabelValues("wrong_code").Inc()
+		respondErrorWithDetails(c, http.StatusBadRequest, "error.phone_verification_code_invalid", gin.H{
+			"remaining_attempts": config.PhoneOTPMaxAttempts - verification.Attempts - 1,
+		})
+		return
+	}
+
+	previousPhone := user.Phone
+	now := time.Now()
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&verification).Update("verified_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(user).Updates(map[string]interface{}{"phone": verification.Phone, "phone_verified_at": now, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "phone_verified", TargetType: "user", TargetID: user.ID,
+			Before: gin.H{"phone": previousPhone}, After: gin.H{"phone": verification.Phone}})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.phone_verification_failed")
+		return
+	}
+
+	phoneVerifications.WithLabelValues("verified").Inc()
+
+	updatedUser, err := getUser(uid)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_updated_user_failed")
+		return
+	}
+	c.Set("user", updatedUser)
+	c.JSON(http.StatusOK, updatedUser)
+}
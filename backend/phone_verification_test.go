@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	savedCountryCode := config.PhoneDefaultCountryCode
+	defer func() { config.PhoneDefaultCountryCode = savedCountryCode }()
+	config.PhoneDefaultCountryCode = "91"
+
+	tests := []struct {
+		raw   string
+		want  string
+		valid bool
+	}{
+		{"9876500001", "+919876500001", true},
+		{"09876500001", "+919876500001", true},      // Trunk prefix
+		{" 98765-00001 ", "+919876500001", true},    // Separators and padding
+		{"(987) 650.0001", "+919876500001", true},   // More separators
+		{"+44 20 7946 0958", "+442079460958", true}, // Own country code
+		{"0044 20 7946 0958", "+442079460958", true},
+		{"98765 abc", "", false},
+		{"98+76500001", "", false}, // "+" only at the start
+		{"12345", "+9112345", false},
+		{"+0123456789", "+0123456789", false},             // Country codes don't start with 0
+		{"+1234567890123456", "+1234567890123456", false}, // Longer than E.164 allows
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, valid := normalizePhone(tt.raw)
+			if valid != tt.valid || (tt.valid && got != tt.want) {
+				t.Errorf("normalizePhone(%q) = %q, %v; want %q, %v", tt.raw, got, valid, tt.want, tt.valid)
+			}
+		})
+	}
+}
+
+func TestConfirmPhoneVerification(t *testing.T) {
+	setupTestDatabase(t, "--phone-otp-max-attempts", "3")
+	gin.SetMode(gin.TestMode)
+	const phone, code = "+919876500001", "123456"
+
+	tests := []struct {
+		name       string
+		noCode     bool          // Verification never started
+		expiresIn  time.Duration // Relative to now
+		attempts   int           // Wrong codes tried before this request
+		code       string
+		wantStatus int
+		wantCode   string
+		remaining  float64 // remaining_attempts after a wrong code
+	}{
+		{name: "correct code", expiresIn: time.Minute, code: code, wantStatus: http.StatusOK},
+		{name: "code with spaces", expiresIn: time.Minute, code: " 123456 ", wantStatus: http.StatusOK},
+		{name: "wrong code", expiresIn: time.Minute, code: "654321",
+			wantStatus: http.StatusBadRequest, wantCode: "VERIFICATION_CODE_INVALID", remaining: 2},
+		{name: "last attempt wrong", expiresIn: time.Minute, attempts: 2, code: "654321",
+			wantStatus: http.StatusBadRequest, wantCode: "VERIFICATION_CODE_INVALID", remaining: 0},
+		{name: "last attempt right", expiresIn: time.Minute, attempts: 2, code: code, wantStatus: http.StatusOK},
+		{name: "locked", expiresIn: time.Minute, attempts: 3, code: code,
+			wantStatus: http.StatusTooManyRequests, wantCode: "VERIFICATION_LOCKED"},
+		{name: "expired", expiresIn: -time.Second, code: code,
+			wantStatus: http.StatusGone, wantCode: "VERIFICATION_EXPIRED"},
+		{name: "not started", noCode: true, code: code,
+			wantStatus: http.StatusNotFound, wantCode: "VERIFICATION_NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uid := "phone " + tt.name
+			user := User{Name: "Rider", Email: strings.ReplaceAll(uid, " ", ".") + "@example.com", FirebaseUID: uid}
+			if err := DB.Create(&user).Error; err != nil {
+				t.Fatal(err)
+			}
+			if !tt.noCode {
+				if err := DB.Create(&PhoneVerification{
+					UserUID:   uid,
+					Phone:     phone,
+					CodeHash:  hashPhoneCode(uid, phone, code),
+					Attempts:  tt.attempts,
+					ExpiresAt: time.Now().Add(tt.expiresIn),
+				}).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			engine := gin.New()
+			engine.POST("/confirm", func(c *gin.Context) { c.Set("uid", uid) }, ConfirmPhoneVerification)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/confirm", strings.NewReader(`{"code":"`+tt.code+`"}`)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				var updated User
+				if err := DB.First(&updated, user.ID).Error; err != nil {
+					t.Fatal(err)
+				}
+				if updated.Phone != phone || updated.PhoneVerifiedAt == nil {
+					t.Errorf("phone = %q, verified at %v; want %q, verified", updated.Phone, updated.PhoneVerifiedAt, phone)
+				}
+				return
+			}
+
+			var body APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Code, tt.wantCode)
+			}
+			if tt.wantCode == "VERIFICATION_CODE_INVALID" && body.Details["remaining_attempts"] != tt.remaining {
+				t.Errorf("remaining_attempts = %v, want %v", body.Details["remaining_attempts"], tt.remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Profile field visibility, chosen per field by each user (PUT /user)
+const (
+	visibleToEveryone = "everyone"  // Any signed-in user
+	visibleToCoRiders = "co_riders" // Only the leader and participants of a ride the user is confirmed on
+	visibleToNobody   = "nobody"
+)
+
+// visibilityOptions lists the settings each profile field accepts. Phone
+// numbers are never public; co-riders see them only near departure.
+var visibilityOptions = map[string][]string{
+	"name_visibility":   {visibleToEveryone, visibleToCoRiders, visibleToNobody},
+	"gender_visibility": {visibleToEveryone, visibleToCoRiders, visibleToNobody},
+	"phone_visibility":  {visibleToCoRiders, visibleToNobody},
+}
+
+// validVisibility reports whether value is a setting field accepts
+func validVisibility(field, value string) bool {
+	for _, option := range visibilityOptions[field] {
+		if value == option {
+			return true
+		}
+	}
+	return false
+}
+
+// profileFields is what a viewer may see of another user's profile
+type profileFields struct {
+	Name   string
+	Gender string
+	Phone  string
+}
+
+// visibleProfile filters owner's profile for a viewer. coRider is whether the
+// viewer is confirmed on the same ride as owner; contact is whether the ride
+// relationship entitles the viewer to owner's phone number right now (see
+// contactAllowed).
+func visibleProfile(owner User, coRider, contact bool) profileFields {
+	var fields profileFields
+	if fieldVisible(owner.NameVisibility, coRider) {
+		fields.Name = owner.Name
+	}
+	if fieldVisible(owner.GenderVisibility, coRider) {
+		fields.Gender = owner.Gender
+	}
+	if contact && owner.PhoneVisibility != visibleToNobody {
+		fields.Phone = owner.Phone
+	}
+	return fields
+}
+
+// fieldVisible applies one visibility setting; an unset one counts as "everyone"
+func fieldVisible(visibility string, coRider bool) bool {
+	switch visibility {
+	case visibleToNobody:
+		return false
+	case visibleToCoRiders:
+		return coRider
+	default:
+		return true
+	}
+}
+
+// contactAllowed reports whether the leader and a confirmed participant of ride
+// may see each other's phone numbers at now: only within CONTACT_WINDOW of departure
+func contactAllowed(ride Ride, now time.Time) bool {
+	departure, err := time.ParseInLocation("2006-01-02 15:04", ride.Date+" "+ride.Time, time.Local)
+	if err != nil {
+		return false
+	}
+	return now.After(departure.Add(-config.ContactWindow)) && now.Before(departure.Add(config.ContactWindow))
+}
+
+// isParticipant reports whether the user with Firebase UID uid has a seat in rideID
+func isParticipant(db *gorm.DB, rideID uint, uid string) bool {
+	var count int64
+	db.Model(&Participant{}).Where("ride_id = ? AND user_id = ?", rideID, uid).Count(&count)
+	return count > 0
+}
+
+// sharesRide reports whether a and b are both confirmed on some ride, as
+// leader or participant
+func sharesRide(db *gorm.DB, a, b User) bool {
+	if a.ID == b.ID {
+		return true
+	}
+	var count int64
+	db.Table("rides").
+		Where("(rides.leader_id = ? AND EXISTS (SELECT 1 FROM participants p WHERE p.ride_id = rides.id AND p.user_id = ?))", a.ID, b.FirebaseUID).
+		Or("(rides.leader_id = ? AND EXISTS (SELECT 1 FROM participants p WHERE p.ride_id = rides.id AND p.user_id = ?))", b.ID, a.FirebaseUID).
+		Or("(EXISTS (SELECT 1 FROM participants p WHERE p.ride_id = rides.id AND p.user_id = ?) AND EXISTS (SELECT 1 FROM participants p WHERE p.ride_id = rides.id AND p.user_id = ?))", a.FirebaseUID, b.FirebaseUID).
+		Count(&count)
+	return count > 0
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// querybenchRows is the size of the large data set, e.g. go test -run QueryCount -querybench-rows 5000
+var querybenchRows = flag.Int("querybench-rows", 500, "rows per list in the large data set of TestListQueryCount")
+
+// queriesRun counts every statement sent through GORM, see queryCounter
+var queriesRun atomic.Int64
+
+// queryCounter is a GORM plugin counting statements into queriesRun, so
+// TestListQueryCount can tell how many queries a request needed
+type queryCounter struct{}
+
+func (queryCounter) Name() string {
+	return "query_counter"
+}
+
+func (queryCounter) Initialize(db *gorm.DB) error {
+	count := func(*gorm.DB) { queriesRun.Add(1) }
+	callbacks := db.Callback()
+	for _, register := range []func(string, func(*gorm.DB)) error{
+		callbacks.Create().After("gorm:create").Register,
+		callbacks.Query().After("gorm:query").Register,
+		callbacks.Update().After("gorm:update").Register,
+		callbacks.Delete().After("gorm:delete").Register,
+		callbacks.Row().After("gorm:row").Register,
+		callbacks.Raw().After("gorm:raw").Register,
+	} {
+		if err := register("count:after", count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// querybenchSmall is the data set size every endpoint is compared against
+const querybenchSmall = 10
+
+// querybenchFixture holds what seedQueryBench created for one data set size
+type querybenchFixture struct {
+	Rider  string // Firebase UID with n sent requests, n privileges, n notifications and n organizations
+	Leader string // Firebase UID leading Ride and administering Org
+	Ride   uint   // Ride with n pending requests and n participants
+	Org    uint   // Organization with n members
+}
+
+// querybenchCase is one list endpoint measured by TestListQueryCount
+type querybenchCase struct {
+	Method string
+	Path   string // Route in apiRoutes
+	As     func(f querybenchFixture) string
+	Params func(f querybenchFixture) gin.Params
+}
+
+var querybenchCases = []querybenchCase{
+	{Method: http.MethodGet, Path: "/user/notifications", As: func(f querybenchFixture) string { return f.Rider }},
+	{Method: http.MethodGet, Path: "/user/requests", As: func(f querybenchFixture) string { return f.Rider }},
+	{Method: http.MethodGet, Path: "/user/privileges", As: func(f querybenchFixture) string { return f.Rider }},
+	{Method: http.MethodGet, Path: "/user/organizations", As: func(f querybenchFixture) string { return f.Rider }},
+	{Method: http.MethodGet, Path: "/ride/:rideID/requests", As: func(f querybenchFixture) string { return f.Leader },
+		Params: func(f querybenchFixture) gin.Params { return gin.Params{{Key: "rideID", Value: fmt.Sprint(f.Ride)}} }},
+	{Method: http.MethodGet, Path: "/ride/:rideID/participants", As: func(f querybenchFixture) string { return f.Leader },
+		Params: func(f querybenchFixture) gin.Params { return gin.Params{{Key: "rideID", Value: fmt.Sprint(f.Ride)}} }},
+	{Method: http.MethodGet, Path: "/organization/:orgID/members", As: func(f querybenchFixture) string { return f.Leader },
+		Params: func(f querybenchFixture) gin.Params { return gin.Params{{Key: "orgID", Value: fmt.Sprint(f.Org)}} }},
+}
+
+// TestListQueryCount seeds a small and a large data set on a throwaway SQLite
+// database and fails if any list endpoint needs more queries for the large
+// one, which means something is loaded per row
+func TestListQueryCount(t *testing.T) {
+	if *querybenchRows <= querybenchSmall {
+		t.Fatalf("-querybench-rows must be more than %d", querybenchSmall)
+	}
+	setupTestDatabase(t)
+	if err := DB.Use(queryCounter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	small, err := seedQueryBench(DB, querybenchSmall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := seedQueryBench(DB, *querybenchRows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, bc := range querybenchCases {
+		smallCount, _, err := measureQueries(bc, small)
+		if err != nil {
+			t.Fatal(err)
+		}
+		largeCount, elapsed, err := measureQueries(bc, large)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s %s: %d queries for %d rows, %d for %d rows (%s)", bc.Method, bc.Path,
+			smallCount, querybenchSmall, largeCount, *querybenchRows, elapsed.Round(time.Millisecond))
+		if largeCount != smallCount {
+			t.Errorf("%s %s: query count grows with the data, %d queries for %d rows but %d for %d",
+				bc.Method, bc.Path, smallCount, querybenchSmall, largeCount, *querybenchRows)
+		}
+	}
+}
+
+// measureQueries calls the route of bc as the fixture's user, behind the
+// route's own middleware, and returns how many statements it ran
+func measureQueries(bc querybenchCase, f querybenchFixture) (int64, time.Duration, error) {
+	var route *routeSpec
+	for i := range apiRoutes {
+		if apiRoutes[i].Method == bc.Method && apiRoutes[i].Path == bc.Path {
+			route = &apiRoutes[i]
+		}
+	}
+	if route == nil {
+		return 0, 0, fmt.Errorf("no route %s %s", bc.Method, bc.Path)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(bc.Method, "/v1"+bc.Path, nil)
+	c.Set("uid", bc.As(f))
+	if bc.Params != nil {
+		c.Params = bc.Params(f)
+	}
+
+	start := time.Now()
+	before := queriesRun.Load()
+	for _, handler := range append(append([]gin.HandlerFunc{}, route.Middleware...), route.Handler) {
+		handler(c)
+		if c.IsAborted() {
+			break
+		}
+	}
+	count := queriesRun.Load() - before
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusOK {
+		return 0, 0, fmt.Errorf("%s %s answered %d: %s", bc.Method, bc.Path, w.Code, w.Body.String())
+	}
+	return count, elapsed, nil
+}
+
+// seedQueryBench creates n rows for every list querybenchCases reads, under
+// Firebase UIDs unique to n
+func seedQueryBench(tx *gorm.DB, n int) (querybenchFixture, error) {
+	prefix := fmt.Sprintf("querybench-%d-", n)
+	date := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	newUser := func(name string) User {
+		return User{Name: name, Email: prefix + name + "@example.com", Phone: "+910000000000", FirebaseUID: prefix + name}
+	}
+
+	rider, leader := newUser("rider"), newUser("leader")
+	passengers := make([]User, 0, n)
+	leaders := make([]User, 0, n)
+	for i := 0; i < n; i++ {
+		passengers = append(passengers, newUser(fmt.Sprintf("passenger-%d", i)))
+		leaders = append(leaders, newUser(fmt.Sprintf("leader-%d", i)))
+	}
+	users := append([]User{rider, leader}, append(passengers, leaders...)...)
+	if err := tx.CreateInBatches(&users, 500).Error; err != nil {
+		return querybenchFixture{}, fmt.Errorf("error creating users: %v", err)
+	}
+	rider, leader = users[0], users[1]
+	passengers, leaders = users[2:2+n], users[2+n:]
+
+	// Organizations: one with n members for the leader to administer, n for the rider to belong to
+	cooldown := 5
+	orgs := make([]Organization, 0, n+1)
+	for i := 0; i <= n; i++ {
+		orgs = append(orgs, Organization{Slug: fmt.Sprintf("%s%d", prefix, i), Name: fmt.Sprintf("Organization %d", i), RequestCooldownMinutes: &cooldown})
+	}
+	if err := tx.CreateInBatches(&orgs, 500).Error; err != nil {
+		return querybenchFixture{}, fmt.Errorf("error creating organizations: %v", err)
+	}
+	memberships := []Membership{{OrganizationID: orgs[0].ID, UserUID: leader.FirebaseUID, Role: orgRoleAdmin}}
+	for i := 0; i < n; i++ {
+		memberships = append(memberships,
+			Membership{OrganizationID: orgs[0].ID, UserUID: passengers[i].FirebaseUID, Role: orgRoleMember},
+			Membership{OrganizationID: orgs[i+1].ID, UserUID: rider.FirebaseUID, Role: orgRoleMember})
+	}
+	if err := tx.CreateInBatches(&memberships, 500).Error; err != nil {
+		return querybenchFixture{}, fmt.Errorf("error creating memberships: %v", err)
+	}
+
+	// The leader's ride, and one ride per other leader for the rider to request,
+	// every other one in an organization so cooldowns are looked up
+	rides := []Ride{{LeaderID: leader.ID, Origin: "Campus", Destination: "Airport", Date: date, Time: "09:00", Seats: n, SeatsFilled: n, Price: 100}}
+	for i := 0; i < n; i++ {
+		ride := Ride{LeaderID: leaders[i].ID, Origin: "Campus", Destination: "Airport", Date: date, Time: "10:00", Seats: 4, Price: 100}
+		if i%2 == 0 {
+			ride.OrganizationID = &orgs[i+1].ID
+		}
+		rides = append(rides, ride)
+	}
+	if err := tx.CreateInBatches(&rides, 500).Error; err != nil {
+		return querybenchFixture{}, fmt.Errorf("error creating rides: %v", err)
+	}
+
+	// Revoked requests go in their own batch, SQLite can't mix set and default revoked_at in one insert
+	var requests, revoked []Request
+	var participants []Participant
+	var notifications []Notification
+	for i := 0; i < n; i++ {
+		requests = append(requests, Request{RideID: rides[0].ID, UserID: passengers[i].FirebaseUID, Status: "pending"})
+		participants = append(participants, Participant{RideID: rides[0].ID, UserID: passengers[i].FirebaseUID, JoinedAt: time.Now()})
+
+		switch status := []string{"pending", "approved", "revoked"}[i%3]; status {
+		case "revoked":
+			revoked = append(revoked, Request{RideID: rides[i+1].ID, UserID: rider.FirebaseUID, Status: status, RevokedAt: time.Now()})
+		default:
+			requests = append(requests, Request{RideID: rides[i+1].ID, UserID: rider.FirebaseUID, Status: status})
+		}
+		notifications = append(notifications, Notification{UserID: rider.FirebaseUID, Title: "Join Request Approved",
+			Message: "Your request was approved", Type: "request_approved", RideID: rides[i+1].ID})
+	}
+	for _, batch := range [][]Request{requests, revoked} {
+		if err := tx.CreateInBatches(&batch, 500).Error; err != nil {
+			return querybenchFixture{}, fmt.Errorf("error creating requests: %v", err)
+		}
+	}
+	if err := tx.CreateInBatches(&participants, 500).Error; err != nil {
+		return querybenchFixture{}, fmt.Errorf("error creating participants: %v", err)
+	}
+	if err := tx.CreateInBatches(&notifications, 500).Error; err != nil {
+		return querybenchFixture{}, fmt.Errorf("error creating notifications: %v", err)
+	}
+
+	return querybenchFixture{Rider: rider.FirebaseUID, Leader: leader.FirebaseUID, Ride: rides[0].ID, Org: orgs[0].ID}, nil
+}
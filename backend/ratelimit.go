@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimit is a token bucket budget: Capacity requests, refilled evenly over Window
+type RateLimit struct {
+	Capacity int
+	Window   time.Duration
+}
+
+// refillRate returns how many tokens are added per second
+func (l RateLimit) refillRate() float64 {
+	return float64(l.Capacity) / l.Window.Seconds()
+}
+
+// rateLimitBudgets are the per-route budgets, overridable with RATE_LIMIT_<NAME>=<capacity>/<window>,
+// e.g. RATE_LIMIT_RIDE_CREATE=5/1h
+var rateLimitBudgets = map[string]RateLimit{
+	"public":       {Capacity: 60, Window: time.Minute},  // Unauthenticated routes, per client IP
+	"ping":         {Capacity: 6, Window: time.Minute},   // /ping runs the expired ride cleanup
+	"protected":    {Capacity: 120, Window: time.Minute}, // Any authenticated route, per Firebase UID
+	"ride_create":  {Capacity: 10, Window: time.Hour},    // POST /ride
+	"join_request": {Capacity: 20, Window: time.Hour},    // POST /ride/:rideID/join
+	"phone_verify": {Capacity: 5, Window: time.Hour},     // POST /user/phone/verify/start, each sends an SMS
+}
+
+// rateLimitResult is the outcome of taking a token from a bucket
+type rateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	ResetAfter time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until the next token is available (only when denied)
+}
+
+// RateLimitStore keeps token buckets. Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (rateLimitResult, error)
+}
+
+// rateLimiter is the store used by RateLimitMiddleware, nil when rate limiting is disabled
+var rateLimiter RateLimitStore
+
+// takeToken refills a bucket holding tokens as of last and tries to take one token at now
+func takeToken(tokens float64, last time.Time, now time.Time, limit RateLimit) (float64, rateLimitResult) {
+	rate := limit.refillRate()
+	capacity := float64(limit.Capacity)
+
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(capacity, tokens+elapsed*rate)
+	}
+
+	result := rateLimitResult{}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.ResetAfter = time.Duration((capacity - tokens) / rate * float64(time.Second))
+	return tokens, result
+}
+
+// memoryRateLimitStore keeps buckets in process memory. Each replica enforces its own budget.
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *memoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (rateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(limit.Capacity), updated: now}
+		s.buckets[key] = bucket
+	}
+
+	tokens, result := takeToken(bucket.tokens, bucket.updated, now, limit)
+	bucket.tokens = tokens
+	bucket.updated = now
+	return result, nil
+}
+
+// prune drops buckets untouched since before cutoff; they would be full again anyway
+func (s *memoryRateLimitStore) prune(cutoff time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, bucket := range s.buckets {
+		if bucket.updated.Before(cutoff) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RateLimitBucket is a token bucket row shared by all replicas through Postgres
+type RateLimitBucket struct {
+	Key       string  `gorm:"primaryKey;type:varchar(200)"`
+	Tokens    float64 `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+// postgresRateLimitStore keeps buckets in the rate_limit_buckets table so every replica shares them
+type postgresRateLimitStore struct {
+	db *gorm.DB
+}
+
+func (s *postgresRateLimitStore) Take(ctx context.Context, key string, limit RateLimit, now time.Time) (rateLimitResult, error) {
+	var result rateLimitResult
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Create the bucket full if this is the first request for the key
+		initial := RateLimitBucket{Key: key, Tokens: float64(limit.Capacity), UpdatedAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&initial).Error; err != nil {
+			return err
+		}
+
+		var bucket RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bucket, "key = ?", key).Error; err != nil {
+			return err
+		}
+
+		tokens, taken := takeToken(bucket.Tokens, bucket.UpdatedAt, now, limit)
+		result = taken
+		return tx.Model(&RateLimitBucket{}).Where("key = ?", key).
+			Updates(map[string]interface{}{"tokens": tokens, "updated_at": now}).Error
+	})
+	return result, err
+}
+
+// prune deletes buckets untouched since before cutoff
+func (s *postgresRateLimitStore) prune(cutoff time.Time) {
+	if err := s.db.Where("updated_at < ?", cutoff).Delete(&RateLimitBucket{}).Error; err != nil {
+		slog.Error("failed to prune rate limit buckets", "error", err)
+	}
+}
+
+// InitRateLimiter configures budgets from the environment and picks the store
+// named by RATE_LIMIT_BACKEND ("memory" by default, "postgres", or "off")
+func InitRateLimiter() error {
+	for name, limit := range config.RateLimits {
+		rateLimitBudgets[name] = limit
+		slog.Info("rate limit configured", "budget", name, "capacity", limit.Capacity, "window", limit.Window.String())
+	}
+
+	var pruner interface{ prune(time.Time) }
+	switch backend := config.RateLimitBackend; backend {
+	case "memory":
+		store := newMemoryRateLimitStore()
+		rateLimiter, pruner = store, store
+	case "postgres":
+		store := &postgresRateLimitStore{db: DB}
+		rateLimiter, pruner = store, store
+	case "off":
+		slog.Warn("rate limiting disabled")
+		return nil
+	default:
+		return fmt.Errorf("unknown RATE_LIMIT_BACKEND %q", backend)
+	}
+
+	// Buckets idle for longer than the longest window are full again and can be dropped
+	var longest time.Duration
+	for _, limit := range rateLimitBudgets {
+		if limit.Window > longest {
+			longest = limit.Window
+		}
+	}
+	startBackgroundJob("rate_limit_prune", 10*time.Minute, func(context.Context) {
+		pruner.prune(time.Now().Add(-longest))
+	})
+	return nil
+}
+
+// parseRateLimit parses "<capacity>/<window>", e.g. "10/1h"
+func parseRateLimit(value string) (RateLimit, error) {
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("expected <capacity>/<window>, got %q", value)
+	}
+	capacity, err := strconv.Atoi(parts[0])
+	if err != nil || capacity < 1 {
+		return RateLimit{}, fmt.Errorf("invalid capacity %q", parts[0])
+	}
+	window, err := time.ParseDuration(parts[1])
+	if err != nil || window <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid window %q", parts[1])
+	}
+	return RateLimit{Capacity: capacity, Window: window}, nil
+}
+
+// RateLimitMiddleware enforces the named budget, keyed by Firebase UID when the
+// request is authenticated and by client IP otherwise
+func RateLimitMiddleware(budget string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, ok := rateLimitBudgets[budget]
+		if rateLimiter == nil || !ok {
+			c.Next()
+			return
+		}
+
+		key := budget + ":ip:" + c.ClientIP()
+		if uid, exists := c.Get("uid"); exists {
+			key = budget + ":uid:" + uid.(string)
+		}
+
+		result, err := rateLimiter.Take(c.Request.Context(), key, limit, time.Now())
+		if err != nil {
+			// Fail open so a rate limiter outage doesn't take the API down with it
+			slog.ErrorContext(c.Request.Context(), "rate limiter error", "key", key, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Capacity))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Capacity, ceilSeconds(limit.Window)))
+
+		if !result.Allowed {
+			retryAfter := ceilSeconds(result.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			writeError(c, http.StatusTooManyRequests, errorCodeFor("error.rate_limited", http.StatusTooManyRequests),
+				trPlural(c, "error.rate_limited", retryAfter), gin.H{"retry_after_seconds": retryAfter})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTakeToken(t *testing.T) {
+	limit := RateLimit{Capacity: 2, Window: 2 * time.Second} // One token per second
+	last := time.Date(2025, 5, 20, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		tokens  float64
+		elapsed time.Duration
+		left    float64
+		want    rateLimitResult
+	}{
+		{"full bucket", 2, 0, 1,
+			rateLimitResult{Allowed: true, Remaining: 1, ResetAfter: time.Second}},
+		{"empty bucket", 0, 0, 0,
+			rateLimitResult{Remaining: 0, ResetAfter: 2 * time.Second, RetryAfter: time.Second}},
+		{"partly refilled", 0, 500 * time.Millisecond, 0.5,
+			rateLimitResult{Remaining: 0, ResetAfter: 1500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}},
+		{"refilled one token", 0, time.Second, 0,
+			rateLimitResult{Allowed: true, Remaining: 0, ResetAfter: 2 * time.Second}},
+		{"refill stops at capacity", 1, time.Minute, 1,
+			rateLimitResult{Allowed: true, Remaining: 1, ResetAfter: time.Second}},
+		{"clock moved back", 1, -time.Minute, 0,
+			rateLimitResult{Allowed: true, Remaining: 0, ResetAfter: 2 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, got := takeToken(tt.tokens, last, last.Add(tt.elapsed), limit)
+			if left != tt.left {
+				t.Errorf("tokens left = %v, want %v", left, tt.left)
+			}
+			if got != tt.want {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRateLimitStores runs the same requests against every store. The
+// Postgres store only uses SQL that SQLite understands too, so it runs on the
+// test database.
+func TestRateLimitStores(t *testing.T) {
+	setupTestDatabase(t)
+	limit := RateLimit{Capacity: 2, Window: time.Minute}
+	start := time.Date(2025, 5, 20, 10, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		key     string
+		after   time.Duration // Since start
+		allowed bool
+	}{
+		{"a", 0, true},
+		{"a", 0, true},
+		{"a", time.Second, false}, // A token takes 30s to come back
+		{"b", time.Second, true},  // Other keys have their own bucket
+		{"a", 31 * time.Second, true},
+		{"a", 32 * time.Second, false},
+	}
+
+	stores := map[string]RateLimitStore{
+		"memory":   newMemoryRateLimitStore(),
+		"postgres": &postgresRateLimitStore{db: DB},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for i, step := range steps {
+				result, err := store.Take(context.Background(), name+":"+step.key, limit, start.Add(step.after))
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				if result.Allowed != step.allowed {
+					t.Errorf("step %d: allowed = %v, want %v", i, result.Allowed, step.allowed)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	savedLimiter := rateLimiter
+	savedBudget, hadBudget := rateLimitBudgets["test"]
+	defer func() {
+		rateLimiter = savedLimiter
+		if hadBudget {
+			rateLimitBudgets["test"] = savedBudget
+		} else {
+			delete(rateLimitBudgets, "test")
+		}
+	}()
+	rateLimitBudgets["test"] = RateLimit{Capacity: 1, Window: time.Minute}
+
+	tests := []struct {
+		name       string
+		store      RateLimitStore
+		requests   int
+		wantStatus int // Of the last request
+		retryAfter string
+	}{
+		{"within budget", newMemoryRateLimitStore(), 1, http.StatusOK, ""},
+		{"over budget", newMemoryRateLimitStore(), 2, http.StatusTooManyRequests, "60"},
+		{"rate limiting off", nil, 2, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rateLimiter = tt.store
+			engine := gin.New()
+			engine.GET("/limited", RateLimitMiddleware("test"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			var w *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				w = httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				engine.ServeHTTP(w, req)
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if tt.wantStatus != http.StatusTooManyRequests {
+				return
+			}
+			var body APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != "RATE_LIMITED" || body.Details["retry_after_seconds"] != float64(60) {
+				t.Errorf("body = %+v, want code RATE_LIMITED and retry_after_seconds 60", body)
+			}
+		})
+	}
+}
@@ -1,13 +1,14 @@
 package main
 
 import (
-	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type Request struct {
@@ -25,39 +26,49 @@ func SendJoinRequest(c *gin.Context) {
 	rideIDStr := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
 	userID := c.MustGet("uid").(string)
 
 	// Get the ride details to check the date
-	var targetRide Ride
-	if err := DB.First(&targetRide, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	targetRide, err := cachedRide(dbFor(c), uint(rideID))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
 	// Get user to find their ID for comparison
-	user, err := getUser(userID)
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+
+	// Organization rides are invisible to outsiders
+	if !canUseRide(dbFor(c), targetRide, userID) {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
+		return
+	}
+
+	if targetRide.RequireVerifiedPhone && user.PhoneVerifiedAt == nil {
+		respondError(c, http.StatusForbidden, "error.phone_not_verified")
 		return
 	}
 
 	// Get ride leader for notifications
 	rideLeader, err := getUser(targetRide.LeaderID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride leader not found"})
+		respondError(c, http.StatusNotFound, "error.ride_leader_not_found")
 		return
 	}
 
-	// Check for any existing involvement on this date
-	hasInvolvement, involvementDetails := checkUserInvolvementForDate(userID, user.ID, targetRide.Date)
+	// Check for any existing involvement on this date, in the ride's organization and the public pool
+	hasInvolvement, involvementDetails := checkUserInvolvementForDate(dbFor(c), userID, user.ID, targetRide.Date, targetRide.OrganizationID)
 
 	if hasInvolvement {
-		c.JSON(http.StatusConflict, gin.H{
-			"error":               "You are already involved in rides for this date. Please clear your involvement first.",
+		respondErrorWithDetails(c, http.StatusConflict, "error.involvement_conflict", gin.H{
 			"involvement_details": involvementDetails,
 			"action_required":     "clear_involvement",
 			"date":                targetRide.Date,
@@ -67,34 +78,31 @@ func SendJoinRequest(c *gin.Context) {
 
 	// Check if a request already exists
 	var existing Request
-	if err := DB.Where("ride_id = ? AND user_id = ?", rideID, userID).First(&existing).Error; err == nil {
+	var replaced interface{} // Revoked request replaced by this one, for the audit log
+	if err := dbFor(c).Where("ride_id = ? AND user_id = ?", rideID, userID).First(&existing).Error; err == nil {
 		if strings.Contains(strings.ToLower(existing.Status), "pending") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Request already pending"})
+			respondError(c, http.StatusConflict, "error.request_already_pending")
 			return
 		}
 		if strings.Contains(strings.ToLower(existing.Status), "approved") {
-			c.JSON(http.StatusConflict, gin.H{"error": "Already approved for this ride"})
+			respondError(c, http.StatusConflict, "error.already_approved")
 			return
 		}
 		if strings.Contains(strings.ToLower(existing.Status), "revoked") {
-			// Check if 30 minutes have passed since revocation
+			// Check if the cooldown (REQUEST_COOLDOWN, or the organization's) has passed since revocation
 			timeSinceRevoked := time.Since(existing.RevokedAt)
-			cooldownPeriod := 30 * time.Minute
+			cooldownPeriod := requestCooldownFor(dbFor(c), targetRide)
 
 			if timeSinceRevoked < cooldownPeriod {
 				remainingTime := cooldownPeriod - timeSinceRevoked
 				remainingMinutes := int(remainingTime.Minutes())
-				c.JSON(http.StatusConflict, gin.H{
-					"error":                      fmt.Sprintf("Request was revoked. Please wait %d more minutes before resending.", remainingMinutes+1),
-					"remaining_cooldown_minutes": remainingMinutes + 1,
-				})
-				return
-			}
-			// Cooldown period has passed, allow new request by deleting the old revoked record
-			if err := DB.Delete(&existing).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear old request"})
+				writeError(c, http.StatusConflict, errorCodeFor("error.request_revoked_cooldown", http.StatusConflict),
+					trPlural(c, "error.request_revoked_cooldown", remainingMinutes+1),
+					gin.H{"remaining_cooldown_minutes": remainingMinutes + 1})
 				return
 			}
+			// Cooldown period has passed, so the old revoked record makes way for the new request
+			replaced = requestSnapshot(existing)
 		}
 	}
 
@@ -105,22 +113,43 @@ func SendJoinRequest(c *gin.Context) {
 		Status: "pending",
 	}
 
-	if err := DB.Create(&request).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create join request"})
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if replaced != nil {
+			if err := tx.Delete(&existing).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{
+			Action:     "request_created",
+			TargetType: "request",
+			TargetID:   request.ID,
+			RideID:     request.RideID,
+			Before:     replaced,
+			After:      requestSnapshot(request),
+		})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.create_join_request_failed")
 		return
 	}
 
 	// Send notification to ride leader
-	notificationTitle := "New Join Request"
-	notificationMessage := fmt.Sprintf("%s has requested to join your ride from %s to %s on %s at %s",
-		user.Name, targetRide.Origin, targetRide.Destination, targetRide.Date, targetRide.Time)
-
-	if err := createNotification(rideLeader.FirebaseUID, notificationTitle, notificationMessage, "join_request", uint(rideID)); err != nil {
+	payload := NotificationPayload{
+		ActorUserID: user.ID,
+		ActorName:   user.Name,
+		Ride:        newRideSnapshot(targetRide),
+		RequestID:   request.ID,
+	}
+	if err := createNotification(dbFor(c), rideLeader.FirebaseUID, "join_request", uint(rideID), payload); err != nil {
 		// Log error but don't fail the request since the join request was created successfully
-		fmt.Printf("Failed to create notification for ride leader %s: %v\n", rideLeader.FirebaseUID, err)
+		slog.ErrorContext(c.Request.Context(), "failed to create notification", "recipient_uid", rideLeader.FirebaseUID, "error", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Join request sent"})
+	joinRequests.WithLabelValues("sent").Inc()
+	c.JSON(http.StatusOK, MessageResponse{Message: tr(c, "message.join_request_sent")})
 }
 
 // DELETE /ride/:rideID/cancel-request - User cancels their pending join request
@@ -128,26 +157,33 @@ func CancelJoinRequest(c *gin.Context) {
 	rideIDStr := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
 	userID := c.MustGet("uid").(string)
 
-	// Find the pending request - using ILIKE for case insensitive matching
+	// Find the pending request (case-insensitive, portable across Postgres and SQLite)
 	var request Request
-	if err := DB.Where("ride_id = ? AND user_id = ? AND status ILIKE ?", rideID, userID, "%pending%").First(&request).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "No pending request found for this ride"})
+	if err := dbFor(c).Where("ride_id = ? AND user_id = ? AND LOWER(status) LIKE ?", rideID, userID, "%pending%").First(&request).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.no_pending_request")
 		return
 	}
 
 	// Delete the pending request
-	if err := DB.Delete(&request).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel request"})
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&request).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "request_cancelled", TargetType: "request", TargetID: request.ID, RideID: request.RideID, Before: requestSnapshot(request)})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.cancel_request_failed")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Join request cancelled successfully"})
+	cancellations.WithLabelValues("request").Inc()
+	c.JSON(http.StatusOK, MessageResponse{Message: tr(c, "message.join_request_cancelled")})
 }
 
 // GET /user/requests - Get all join requests sent by the authenticated user
@@ -156,22 +192,49 @@ func GetUserSentRequests(c *gin.Context) {
 
 	// Find all requests sent by the user
 	var requests []Request
-	if err := DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&requests).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch your requests"})
+	if err := dbFor(c).Where("user_id = ?", userID).Order("created_at DESC").Find(&requests).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_requests_failed")
+		return
+	}
+
+	// Load the rides, their leaders and organizations up front rather than per request
+	rideIDs := make([]uint, 0, len(requests))
+	for _, req := range requests {
+		rideIDs = append(rideIDs, req.RideID)
+	}
+	rides, err := ridesByID(dbFor(c), rideIDs)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_requests_failed")
+		return
+	}
+	leaders, err := rideLeaders(dbFor(c), rides)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_requests_failed")
+		return
+	}
+	orgIDs := make([]uint, 0)
+	for _, ride := range rides {
+		if ride.OrganizationID != nil {
+			orgIDs = append(orgIDs, *ride.OrganizationID)
+		}
+	}
+	orgs, err := organizationsByID(dbFor(c), orgIDs)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_requests_failed")
 		return
 	}
 
 	// Build response with request and ride details
-	var response []map[string]interface{}
+	response := make([]SentRequestResponse, 0, len(requests))
 	for _, req := range requests {
-		var ride Ride
-		if err := DB.First(&ride, "id = ?", req.RideID).Error; err != nil {
+		ride, ok := rides[req.RideID]
+		if !ok {
 			continue // Skip if ride doesn't exist
 		}
 
 		// Get ride leader info
-		leader, err := getUser(ride.LeaderID)
-		if err != nil {
+		leader, ok := leaders[ride.LeaderID]
+		if !ok {
 			continue // Skip if leader doesn't exist
 		}
 
@@ -180,84 +243,85 @@ func GetUserSentRequests(c *gin.Context) {
 		canJoin := strings.Contains(strings.ToLower(req.Status), "approved") && ride.SeatsFilled < ride.Seats
 
 		// Calculate cooldown for revoked requests
-		var cooldownInfo map[string]interface{}
+		var cooldownInfo *CooldownInfo
 		if strings.Contains(strings.ToLower(req.Status), "revoked") {
 			timeSinceRevoked := time.Since(req.RevokedAt)
-			cooldownPeriod := 30 * time.Minute
+			cooldownPeriod := config.RequestCooldown
+			if ride.OrganizationID != nil {
+				if org, ok := orgs[*ride.OrganizationID]; ok {
+					cooldownPeriod = org.requestCooldown()
+				}
+			}
 			if timeSinceRevoked < cooldownPeriod {
 				remainingTime := cooldownPeriod - timeSinceRevoked
 				remainingMinutes := int(remainingTime.Minutes())
-				cooldownInfo = map[string]interface{}{
-					"can_resend":        false,
-					"remaining_minutes": remainingMinutes + 1,
-				}
+				cooldownInfo = &CooldownInfo{CanResend: false, RemainingMinutes: remainingMinutes + 1}
 			} else {
-				cooldownInfo = map[string]interface{}{
-					"can_resend":        true,
-					"remaining_minutes": 0,
-				}
+				cooldownInfo = &CooldownInfo{CanResend: true, RemainingMinutes: 0}
 			}
 		}
 
-		entry := map[string]interface{}{
-			"request_id":      req.ID,
-			"ride_id":         ride.ID,
-			"origin":          ride.Origin,
-			"destination":     ride.Destination,
-			"date":            ride.Date,
-			"time":            ride.Time,
-			"price":           ride.Price,
-			"seats_available": ride.Seats - ride.SeatsFilled,
-			"total_seats":     ride.Seats,
-			"status":          req.Status,
-			"leader_name":     leader.Name,
-			"requested_at":    req.CreatedAt,
-			"updated_at":      req.UpdatedAt,
-			"can_cancel":      canCancel,
-			"can_join":        canJoin,
-		}
-
-		// Add cooldown info for revoked requests
-		if cooldownInfo != nil {
-			entry["cooldown"] = cooldownInfo
-		}
-
-		response = append(response, entry)
+		response = append(response, SentRequestResponse{
+			RequestID:      req.ID,
+			RideID:         ride.ID,
+			Origin:         ride.Origin,
+			Destination:    ride.Destination,
+			Date:           ride.Date,
+			Time:           ride.Time,
+			Price:          ride.Price,
+			SeatsAvailable: ride.Seats - ride.SeatsFilled,
+			TotalSeats:     ride.Seats,
+			Status:         req.Status,
+			LeaderName:     visibleProfile(leader, false, false).Name,
+			RequestedAt:    req.CreatedAt,
+			UpdatedAt:      req.UpdatedAt,
+			CanCancel:      canCancel,
+			CanJoin:        canJoin,
+			Cooldown:       cooldownInfo,
+		})
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// DELETE /user/clear-involvement/:date - Cancel all pending requests and privileges for a specific date
+// DELETE /user/clear-involvement/:date[?organization_id=] - Cancel all pending requests and privileges for a specific date,
+// optionally only those that count against a ride in that organization
 func ClearInvolvementForDate(c *gin.Context) {
 	dateParam := c.Param("date")
 	userID := c.MustGet("uid").(string)
 
 	// Validate date format
 	if _, err := time.Parse("2006-01-02", dateParam); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format, expected YYYY-MM-DD"})
+		respondError(c, http.StatusBadRequest, "error.invalid_date_format")
+		return
+	}
+
+	orgID, ok := organizationQuery(c)
+	if !ok {
 		return
 	}
 
-	// Find all pending requests for rides on this date - using ILIKE for case insensitive matching
+	// Find all pending requests for rides on this date (case-insensitive, portable across Postgres and SQLite)
 	var pendingRequestsForDate []Request
-	if err := DB.Table("requests").
+	if err := dbFor(c).Table("requests").
 		Joins("JOIN rides ON requests.ride_id = rides.id").
-		Where("requests.user_id = ? AND requests.status ILIKE ? AND rides.date = ?",
+		Where("requests.user_id = ? AND LOWER(requests.status) LIKE ? AND rides.date = ?",
 			userID, "%pending%", dateParam).
+		Scopes(sameTenant(orgID)).
 		Find(&pendingRequestsForDate).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pending requests for date"})
+		respondError(c, http.StatusInternalServerError, "error.fetch_pending_requests_for_date_failed")
 		return
 	}
 
-	// Find all approved privileges for rides on this date - using ILIKE for case insensitive matching
+	// Find all approved privileges for rides on this date (case-insensitive, portable across Postgres and SQLite)
 	var approvedRequestsForDate []Request
-	if err := DB.Table("requests").
+	if err := dbFor(c).Table("requests").
 		Joins("JOIN rides ON requests.ride_id = rides.id").
-		Where("requests.user_id = ? AND requests.status ILIKE ? AND rides.date = ?",
+		Where("requests.user_id = ? AND LOWER(requests.status) LIKE ? AND rides.date = ?",
 			userID, "%approved%", dateParam).
+		Scopes(sameTenant(orgID)).
 		Find(&approvedRequestsForDate).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch privileges for date"})
+		respondError(c, http.StatusInternalServerError, "error.fetch_privileges_for_date_failed")
 		return
 	}
 
@@ -266,12 +330,9 @@ func ClearInvolvementForDate(c *gin.Context) {
 	totalCount := pendingCount + approvedCount
 
 	if totalCount == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"message":              fmt.Sprintf("No requests or privileges to cancel for %s", dateParam),
-			"cancelled_requests":   0,
-			"cancelled_privileges": 0,
-			"total_cancelled":      0,
-			"date":                 dateParam,
+		c.JSON(http.StatusOK, ClearInvolvementResponse{
+			Message: tr(c, "message.nothing_to_clear", MessageArgs{"date": dateParam}),
+			Date:    dateParam,
 		})
 		return
 	}
@@ -282,8 +343,19 @@ func ClearInvolvementForDate(c *gin.Context) {
 		for i, req := range pendingRequestsForDate {
 			requestIDs[i] = req.ID
 		}
-		if err := DB.Where("id IN ?", requestIDs).Delete(&Request{}).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel pending requests"})
+		err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("id IN ?", requestIDs).Delete(&Request{}).Error; err != nil {
+				return err
+			}
+			for _, req := range pendingRequestsForDate {
+				if err := recordAudit(tx, c, auditEntry{Action: "request_cancelled", TargetType: "request", TargetID: req.ID, RideID: req.RideID, Before: requestSnapshot(req)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "error.cancel_pending_requests_failed")
 			return
 		}
 	}
@@ -294,55 +366,79 @@ func ClearInvolvementForDate(c *gin.Context) {
 		for i, req := range approvedRequestsForDate {
 			requestIDs[i] = req.ID
 		}
-		if err := DB.Where("id IN ?", requestIDs).Delete(&Request{}).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel privileges"})
+		err := dbFor(c).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("id IN ?", requestIDs).Delete(&Request{}).Error; err != nil {
+				return err
+			}
+			for _, req := range approvedRequestsForDate {
+				if err := recordAudit(tx, c, auditEntry{Action: "privilege_cancelled", TargetType: "request", TargetID: req.ID, RideID: req.RideID, Before: requestSnapshot(req)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "error.cancel_privileges_failed")
 			return
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":              fmt.Sprintf("Successfully cleared all ride involvement for %s", dateParam),
-		"cancelled_requests":   pendingCount,
-		"cancelled_privileges": approvedCount,
-		"total_cancelled":      totalCount,
-		"date":                 dateParam,
+	cancellations.WithLabelValues("request").Add(float64(pendingCount))
+	cancellations.WithLabelValues("privilege").Add(float64(approvedCount))
+	c.JSON(http.StatusOK, ClearInvolvementResponse{
+		Message:             tr(c, "message.involvement_cleared", MessageArgs{"date": dateParam}),
+		CancelledRequests:   pendingCount,
+		CancelledPrivileges: approvedCount,
+		TotalCancelled:      totalCount,
+		Date:                dateParam,
 	})
 }
 
-// Helper function to check user involvement for a specific date
+// Helper function to check user involvement for a specific date, counting
+// only rides in orgID and the public pool (see sameTenant)
 // Returns (hasInvolvement bool, involvementDetails map)
-func checkUserInvolvementForDate(userID string, userDBID uint, date string) (bool, map[string]interface{}) {
+func checkUserInvolvementForDate(db *gorm.DB, userID string, userDBID uint, date string, orgID *uint) (bool, map[string]interface{}) {
+	return checkUserInvolvementBesidesRide(db, userID, userDBID, date, orgID, 0)
+}
+
+// checkUserInvolvementBesidesRide is checkUserInvolvementForDate ignoring
+// whatever ties the user to the ride with exceptRideID, e.g. for a leadership
+// transfer where the user already leads or rides it
+func checkUserInvolvementBesidesRide(db *gorm.DB, userID string, userDBID uint, date string, orgID *uint, exceptRideID uint) (bool, map[string]interface{}) {
 	// Check if user has created any rides on this date
 	var createdRideCount int64
-	if err := DB.Model(&Ride{}).Where("leader_id = ? AND date = ?", userDBID, date).Count(&createdRideCount).Error; err != nil {
+	if err := db.Model(&Ride{}).Where("leader_id = ? AND date = ? AND id <> ?", userDBID, date, exceptRideID).Scopes(sameTenant(orgID)).Count(&createdRideCount).Error; err != nil {
 		return false, map[string]interface{}{"error": "Failed to check created rides"}
 	}
 
 	// Check for pending requests on this date
 	var pendingRequestCount int64
-	if err := DB.Table("requests").
+	if err := db.Table("requests").
 		Joins("JOIN rides ON requests.ride_id = rides.id").
-		Where("requests.user_id = ? AND requests.status ILIKE ? AND rides.date = ?",
-			userID, "%pending%", date).
+		Where("requests.user_id = ? AND LOWER(requests.status) LIKE ? AND rides.date = ? AND rides.id <> ?",
+			userID, "%pending%", date, exceptRideID).
+		Scopes(sameTenant(orgID)).
 		Count(&pendingRequestCount).Error; err != nil {
 		return false, map[string]interface{}{"error": "Failed to check pending requests"}
 	}
 
 	// Check for approved privileges on this date
 	var approvedRequestCount int64
-	if err := DB.Table("requests").
+	if err := db.Table("requests").
 		Joins("JOIN rides ON requests.ride_id = rides.id").
-		Where("requests.user_id = ? AND requests.status ILIKE ? AND rides.date = ?",
-			userID, "%approved%", date).
+		Where("requests.user_id = ? AND LOWER(requests.status) LIKE ? AND rides.date = ? AND rides.id <> ?",
+			userID, "%approved%", date, exceptRideID).
+		Scopes(sameTenant(orgID)).
 		Count(&approvedRequestCount).Error; err != nil {
 		return false, map[string]interface{}{"error": "Failed to check approved privileges"}
 	}
 
 	// Check for active participations on this date
 	var participationCount int64
-	if err := DB.Table("participants").
+	if err := db.Table("participants").
 		Joins("JOIN rides ON participants.ride_id = rides.id").
-		Where("participants.user_id = ? AND rides.date = ?", userID, date).
+		Where("participants.user_id = ? AND rides.date = ? AND rides.id <> ?", userID, date, exceptRideID).
+		Scopes(sameTenant(orgID)).
 		Count(&participationCount).Error; err != nil {
 		return false, map[string]interface{}{"error": "Failed to check participations"}
 	}
@@ -361,20 +457,26 @@ func checkUserInvolvementForDate(userID string, userDBID uint, date string) (boo
 	return hasInvolvement, involvementDetails
 }
 
-// GET /user/check-involvement/:date - Check if user has any involvement for a specific date
+// GET /user/check-involvement/:date[?organization_id=] - Check if user has any involvement for a specific date,
+// optionally only what counts against a ride in that organization
 func CheckInvolvementForDate(c *gin.Context) {
 	dateParam := c.Param("date")
 	userID := c.MustGet("uid").(string)
 
 	// Validate date format
 	if _, err := time.Parse("2006-01-02", dateParam); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format, expected YYYY-MM-DD"})
+		respondError(c, http.StatusBadRequest, "error.invalid_date_format")
 		return
 	}
 
-	user, err := getUser(userID)
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
+
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
@@ -398,112 +500,134 @@ func CheckInvolvementForDate(c *gin.Context) {
 
 	// 1. Check for posted rides (user is the leader)
 	var postedRides []Ride
-	if err := DB.Where("leader_id = ? AND date = ?", user.ID, dateParam).Find(&postedRides).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check posted rides"})
+	if err := dbFor(c).Where("leader_id = ? AND date = ?", user.ID, dateParam).Scopes(sameTenant(orgID)).Find(&postedRides).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.check_posted_rides_failed")
 		return
 	}
 
 	postedRideDetails := []map[string]interface{}{}
 	for _, ride := range postedRides {
 		postedRideDetails = append(postedRideDetails, map[string]interface{}{
-			"ride_id":      ride.ID,
-			"origin":       ride.Origin,
-			"destination":  ride.Destination,
-			"time":         ride.Time,
-			"seats":        ride.Seats,
-			"seats_filled": ride.SeatsFilled,
-			"price":        ride.Price,
+			"ride_id":         ride.ID,
+			"origin":          ride.Origin,
+			"destination":     ride.Destination,
+			"time":            ride.Time,
+			"seats":           ride.Seats,
+			"seats_filled":    ride.SeatsFilled,
+			"price":           ride.Price,
+			"organization_id": ride.OrganizationID,
 		})
 	}
 
 	// 2. Check for joined rides (user is a participant)
 	var participants []Participant
-	if err := DB.Table("participants").
+	if err := dbFor(c).Table("participants").
 		Joins("JOIN rides ON participants.ride_id = rides.id").
 		Where("participants.user_id = ? AND rides.date = ?", userID, dateParam).
+		Scopes(sameTenant(orgID)).
 		Find(&participants).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check joined rides"})
+		respondError(c, http.StatusInternalServerError, "error.check_joined_rides_failed")
+		return
+	}
+
+	// 3. Check for pending requests
+	var pendingRequests []Request
+	if err := dbFor(c).Table("requests").
+		Joins("JOIN rides ON requests.ride_id = rides.id").
+		Where("requests.user_id = ? AND LOWER(requests.status) LIKE ? AND rides.date = ?",
+			userID, "%pending%", dateParam).
+		Scopes(sameTenant(orgID)).
+		Find(&pendingRequests).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.check_pending_requests_failed")
+		return
+	}
+
+	// 4. Check for approved privileges
+	var approvedRequests []Request
+	if err := dbFor(c).Table("requests").
+		Joins("JOIN rides ON requests.ride_id = rides.id").
+		Where("requests.user_id = ? AND LOWER(requests.status) LIKE ? AND rides.date = ?",
+			userID, "%approved%", dateParam).
+		Scopes(sameTenant(orgID)).
+		Find(&approvedRequests).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.check_privileges_failed")
+		return
+	}
+
+	// Load the rides involved and their leaders in two queries
+	rideIDs := make([]uint, 0, len(participants)+len(pendingRequests)+len(approvedRequests))
+	for _, participant := range participants {
+		rideIDs = append(rideIDs, participant.RideID)
+	}
+	for _, request := range pendingRequests {
+		rideIDs = append(rideIDs, request.RideID)
+	}
+	for _, request := range approvedRequests {
+		rideIDs = append(rideIDs, request.RideID)
+	}
+	rides, err := ridesByID(dbFor(c), rideIDs)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.check_joined_rides_failed")
+		return
+	}
+	leaders, err := rideLeaders(dbFor(c), rides)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.check_joined_rides_failed")
 		return
 	}
 
 	joinedRideDetails := []map[string]interface{}{}
 	for _, participant := range participants {
-		var ride Ride
-		if err := DB.First(&ride, "id = ?", participant.RideID).Error; err == nil {
+		if ride, ok := rides[participant.RideID]; ok {
 			// Get leader info
-			leader, err := getUserByID(ride.LeaderID)
 			leaderName := "Unknown"
-			if err == nil {
-				leaderName = leader.Name
+			if leader, ok := leaders[ride.LeaderID]; ok {
+				leaderName = visibleProfile(leader, true, false).Name
 			}
 
 			joinedRideDetails = append(joinedRideDetails, map[string]interface{}{
-				"ride_id":     ride.ID,
-				"origin":      ride.Origin,
-				"destination": ride.Destination,
-				"time":        ride.Time,
-				"price":       ride.Price,
-				"leader_name": leaderName,
-				"joined_at":   participant.JoinedAt,
+				"ride_id":         ride.ID,
+				"origin":          ride.Origin,
+				"destination":     ride.Destination,
+				"time":            ride.Time,
+				"price":           ride.Price,
+				"leader_name":     leaderName,
+				"joined_at":       participant.JoinedAt,
+				"organization_id": ride.OrganizationID,
 			})
 		}
 	}
 
-	// 3. Check for pending requests
-	var pendingRequests []Request
-	if err := DB.Table("requests").
-		Joins("JOIN rides ON requests.ride_id = rides.id").
-		Where("requests.user_id = ? AND requests.status ILIKE ? AND rides.date = ?",
-			userID, "%pending%", dateParam).
-		Find(&pendingRequests).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check pending requests"})
-		return
-	}
-
 	pendingRequestDetails := []map[string]interface{}{}
 	for _, request := range pendingRequests {
-		var ride Ride
-		if err := DB.First(&ride, "id = ?", request.RideID).Error; err == nil {
+		if ride, ok := rides[request.RideID]; ok {
 			// Get leader info
-			leader, err := getUserByID(ride.LeaderID)
 			leaderName := "Unknown"
-			if err == nil {
-				leaderName = leader.Name
+			if leader, ok := leaders[ride.LeaderID]; ok {
+				leaderName = visibleProfile(leader, false, false).Name
 			}
 
 			pendingRequestDetails = append(pendingRequestDetails, map[string]interface{}{
-				"request_id":   request.ID,
-				"ride_id":      ride.ID,
-				"origin":       ride.Origin,
-				"destination":  ride.Destination,
-				"time":         ride.Time,
-				"price":        ride.Price,
-				"leader_name":  leaderName,
-				"requested_at": request.CreatedAt,
+				"request_id":      request.ID,
+				"ride_id":         ride.ID,
+				"origin":          ride.Origin,
+				"destination":     ride.Destination,
+				"time":            ride.Time,
+				"price":           ride.Price,
+				"leader_name":     leaderName,
+				"requested_at":    request.CreatedAt,
+				"organization_id": ride.OrganizationID,
 			})
 		}
 	}
 
-	// 4. Check for approved privileges
-	var approvedRequests []Request
-	if err := DB.Table("requests").
-		Joins("JOIN rides ON requests.ride_id = rides.id").
-		Where("requests.user_id = ? AND requests.status ILIKE ? AND rides.date = ?",
-			userID, "%approved%", dateParam).
-		Find(&approvedRequests).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check approved privileges"})
-		return
-	}
-
 	approvedPrivilegeDetails := []map[string]interface{}{}
 	for _, request := range approvedRequests {
-		var ride Ride
-		if err := DB.First(&ride, "id = ?", request.RideID).Error; err == nil {
+		if ride, ok := rides[request.RideID]; ok {
 			// Get leader info
-			leader, err := getUserByID(ride.LeaderID)
 			leaderName := "Unknown"
-			if err == nil {
-				leaderName = leader.Name
+			if leader, ok := leaders[ride.LeaderID]; ok {
+				leaderName = visibleProfile(leader, false, false).Name
 			}
 
 			approvedPrivilegeDetails = append(approvedPrivilegeDetails, map[string]interface{}{
@@ -517,6 +641,7 @@ func CheckInvolvementForDate(c *gin.Context) {
 				"approved_at":     request.UpdatedAt,
 				"seats_available": ride.Seats - ride.SeatsFilled,
 				"can_join":        ride.SeatsFilled < ride.Seats,
+				"organization_id": ride.OrganizationID,
 			})
 		}
 	}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rideTimeShiftThreshold is how far the departure time can move before the
+// change counts as significant, see RideChange.Significant
+const rideTimeShiftThreshold = 30 * time.Minute
+
+// errSeatsBelowFilled aborts an edit when more participants than seats are
+// left on the ride once the transaction has counted them
+var errSeatsBelowFilled = errors.New("seats below participants")
+
+// Request body for PATCH /ride/:rideID. Fields left out keep their value.
+type UpdateRideRequest struct {
+	Origin      *string  `json:"origin"`
+	Destination *string  `json:"destination"`
+	Date        *string  `json:"date"` // e.g. "2025-05-20"
+	Time        *string  `json:"time"` // e.g. "15:30"
+	Seats       *int     `json:"seats"`
+	Price       *float64 `json:"price"`
+	// Participants to remove when seats go below seats_filled, at least
+	// seats_filled - seats of them, or who are busy on a new date. Without
+	// it such an update is refused.
+	DropParticipantIDs []uint `json:"drop_participant_ids"`
+}
+
+// RideChange is one field changed by PATCH /ride/:rideID, as sent in
+// notifications and the response
+type RideChange struct {
+	Field string `json:"field"` // "origin", "destination", "date", "time", "seats" or "price"
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Significant reports whether the change can break a participant's plans:
+// a new route or date, a departure moved by more than
+// rideTimeShiftThreshold, or a higher price. Participants are told about
+// significant changes with a link to leave the ride.
+func (change RideChange) Significant() bool {
+	switch change.Field {
+	case "origin", "destination", "date":
+		return true
+	case "time":
+		oldTime, err1 := time.Parse("15:04", change.Old)
+		newTime, err2 := time.Parse("15:04", change.New)
+		shift := newTime.Sub(oldTime)
+		return err1 != nil || err2 != nil || shift > rideTimeShiftThreshold || shift < -rideTimeShiftThreshold
+	case "price":
+		oldPrice, err1 := strconv.ParseFloat(change.Old, 64)
+		newPrice, err2 := strconv.ParseFloat(change.New, 64)
+		return err1 != nil || err2 != nil || newPrice > oldPrice
+	}
+	return false
+}
+
+// rideChanges applies req to ride and lists what changed
+func rideChanges(ride *Ride, req UpdateRideRequest) []RideChange {
+	var changes []RideChange
+	setString := func(field string, target *string, value *string) {
+		if value != nil && strings.TrimSpace(*value) != *target {
+			changes = append(changes, RideChange{Field: field, Old: *target, New: strings.TrimSpace(*value)})
+			*target = strings.TrimSpace(*value)
+		}
+	}
+	setString("origin", &ride.Origin, req.Origin)
+	setString("destination", &ride.Destination, req.Destination)
+	setString("date", &ride.Date, req.Date)
+	setString("time", &ride.Time, req.Time)
+	if req.Seats != nil && *req.Seats != ride.Seats {
+		changes = append(changes, RideChange{Field: "seats", Old: strconv.Itoa(ride.Seats), New: strconv.Itoa(*req.Seats)})
+		ride.Seats = *req.Seats
+	}
+	if req.Price != nil && *req.Price != ride.Price {
+		changes = append(changes, RideChange{Field: "price", Old: formatPrice(ride.Price), New: formatPrice(*req.Price)})
+		ride.Price = *req.Price
+	}
+	return changes
+}
+
+// formatPrice renders a price without trailing zeros, e.g. "120" or "99.5"
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
+
+// PATCH /ride/:rideID - Leader edits their ride. Participants and requesters
+// are notified of the changes. Seats can only go below seats_filled, and the
+// date can only move to a day other participants are busy, if the leader
+// drops enough of them. Requests of users busy on the new date are withdrawn.
+// Participants unhappy with a change just leave with DELETE
+// /user/cancel-ride/:rideID: leaving never counts against anyone (nothing
+// records or limits it, unlike REQUEST_COOLDOWN for removed passengers), so
+// significant changes need no separate penalty-free way out.
+func UpdateRide(c *gin.Context) {
+	rideID, err := strconv.Atoi(c.Param("rideID"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
+		return
+	}
+
+	var req UpdateRideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_input", MessageArgs{"details": err.Error()})
+		return
+	}
+
+	// Seats are checked against the database, not rideCache
+	var ride Ride
+	if err := dbFor(c).First(&ride, "id = ?", rideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
+		return
+	}
+
+	user, err := currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.user_not_found")
+		return
+	}
+	if ride.LeaderID != user.ID {
+		respondError(c, http.StatusForbidden, "error.not_ride_leader")
+		return
+	}
+
+	before := ride
+	changes := rideChanges(&ride, req)
+	if len(changes) == 0 && len(req.DropParticipantIDs) == 0 {
+		c.JSON(http.StatusOK, RideUpdatedResponse{Message: tr(c, "message.ride_unchanged"), Ride: ride, Changes: []RideChange{}})
+		return
+	}
+
+	// Validate the ride as it would be after the update
+	if _, err := time.Parse("15:04", ride.Time); err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_time_format")
+		return
+	}
+	if _, err := time.Parse("2006-01-02", ride.Date); err != nil {
+		respondError(c, http.StatusBadRequest, "error.invalid_date_format")
+		return
+	}
+	if ride.Date < time.Now().Format("2006-01-02") {
+		respondError(c, http.StatusBadRequest, "error.ride_date_past")
+		return
+	}
+	if ride.Origin == "" || ride.Destination == "" {
+		respondError(c, http.StatusBadRequest, "error.invalid_input", MessageArgs{"details": "origin and destination must not be empty"})
+		return
+	}
+	if ride.Seats < 1 {
+		respondError(c, http.StatusBadRequest, "error.invalid_seats")
+		return
+	}
+	if ride.Price < 0 {
+		respondError(c, http.StatusBadRequest, "error.invalid_price")
+		return
+	}
+	if ride.OrganizationID != nil {
+		var org Organization
+		if err := dbFor(c).First(&org, *ride.OrganizationID).Error; err == nil {
+			for _, location := range []string{ride.Origin, ride.Destination} {
+				if !org.locationAllowed(location) {
+					respondErrorWithDetails(c, http.StatusBadRequest, "error.location_not_allowed",
+						gin.H{"allowed_locations": org.AllowedLocations}, MessageArgs{"location": location})
+					return
+				}
+			}
+		}
+	}
+
+	// A new date has to be free for the leader, as when posting the ride
+	if ride.Date != before.Date {
+		if hasInvolvement, involvementDetails := checkUserInvolvementForDate(dbFor(c), user.FirebaseUID, user.ID, ride.Date, ride.OrganizationID); hasInvolvement {
+			respondErrorWithDetails(c, http.StatusConflict, "error.involvement_conflict", gin.H{
+				"involvement_details": involvementDetails,
+				"action_required":     "clear_involvement",
+				"date":                ride.Date,
+			})
+			return
+		}
+	}
+
+	var participants []Participant
+	if err := dbFor(c).Where("ride_id = ?", ride.ID).Order("joined_at").Find(&participants).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_participants_failed")
+		return
+	}
+	var requests []Request
+	if err := dbFor(c).Where("ride_id = ? AND status IN ?", ride.ID, []string{"pending", "approved"}).Find(&requests).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_requests_failed")
+		return
+	}
+
+	// Dropped participants have to belong to the ride
+	drop := make(map[uint]bool, len(req.DropParticipantIDs))
+	for _, id := range req.DropParticipantIDs {
+		drop[id] = true
+	}
+	var dropped, staying []Participant
+	for _, p := range participants {
+		if drop[p.ID] {
+			dropped = append(dropped, p)
+			delete(drop, p.ID)
+		} else {
+			staying = append(staying, p)
+		}
+	}
+	for id := range drop {
+		respondErrorWithDetails(c, http.StatusBadRequest, "error.participant_not_found", gin.H{"participant_id": id})
+		return
+	}
+	if len(staying) > ride.Seats {
+		respondSeatsBelowFilled(c, ride, staying)
+		return
+	}
+
+	// Everyone staying on the ride has to be free on a new date too. Busy
+	// participants have to be dropped by the leader, busy requesters lose
+	// their request.
+	var released []Request
+	if ride.Date != before.Date {
+		busy, err := busyOnDate(c, ride, staying, requests)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "error.fetch_participants_failed")
+			return
+		}
+		var busyParticipants []Participant
+		for _, p := range staying {
+			if busy[p.UserID] {
+				busyParticipants = append(busyParticipants, p)
+			}
+		}
+		if len(busyParticipants) > 0 {
+			respondParticipantsBusy(c, ride, busyParticipants)
+			return
+		}
+		remaining := requests[:0:0]
+		for _, r := range requests {
+			if busy[r.UserID] {
+				released = append(released, r)
+			} else {
+				remaining = append(remaining, r)
+			}
+		}
+		requests = remaining
+	}
+
+	significant := false
+	for _, change := range changes {
+		significant = significant || change.Significant()
+	}
+
+	// Save the ride, drop participants and withdraw requests in one
+	// transaction. Seats filled is counted under the ride's lock, as
+	// participants may have joined or left since they were listed above.
+	now := time.Now()
+	var filled int64
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&Ride{}, ride.ID).Error; err != nil {
+			return err
+		}
+		for _, p := range dropped {
+			if err := tx.Delete(&p).Error; err != nil {
+				return err
+			}
+			if err := recordAudit(tx, c, auditEntry{Action: "participant_removed", TargetType: "participant", TargetID: p.ID, RideID: ride.ID, SubjectUID: p.UserID,
+				Before: participantSnapshot(p), After: gin.H{"reason": reasonRideEdited}}); err != nil {
+				return err
+			}
+		}
+		for i, r := range released {
+			before := requestSnapshot(r)
+			if err := tx.Model(&released[i]).Updates(map[string]interface{}{"status": "revoked", "revoked_at": now}).Error; err != nil {
+				return err
+			}
+			if err := recordAudit(tx, c, auditEntry{Action: "request_released", TargetType: "request", TargetID: r.ID, RideID: ride.ID, SubjectUID: r.UserID,
+				Before: before, After: requestSnapshot(released[i])}); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&Participant{}).Where("ride_id = ?", ride.ID).Count(&filled).Error; err != nil {
+			return err
+		}
+		if int(filled) > ride.Seats {
+			return errSeatsBelowFilled
+		}
+		ride.SeatsFilled = int(filled)
+		if err := tx.Model(&ride).Select("origin", "destination", "date", "time", "seats", "price", "seats_filled").Updates(&ride).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "ride_updated", TargetType: "ride", TargetID: ride.ID, RideID: ride.ID, Before: before, After: ride})
+	})
+	if errors.Is(err, errSeatsBelowFilled) {
+		respondError(c, http.StatusConflict, "error.seats_below_filled", MessageArgs{"seats": ride.Seats, "filled": filled})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.update_ride_failed")
+		return
+	}
+	invalidateRide(ride.ID)
+
+	notified := notifyRideUpdated(c, *user, ride, changes, significant, staying, dropped, requests, released)
+
+	c.JSON(http.StatusOK, RideUpdatedResponse{
+		Message:              tr(c, "message.ride_updated"),
+		Ride:                 ride,
+		Changes:              append([]RideChange{}, changes...),
+		Significant:          significant,
+		ParticipantsDropped:  len(dropped),
+		ParticipantsNotified: notified,
+		RequestsWithdrawn:    len(released),
+	})
+}
+
+// busyOnDate returns the UIDs of the participants and requesters of ride
+// who are involved in other rides on its date
+func busyOnDate(c *gin.Context, ride Ride, participants []Participant, requests []Request) (map[string]bool, error) {
+	uids := make([]string, 0, len(participants)+len(requests))
+	for _, p := range participants {
+		uids = append(uids, p.UserID)
+	}
+	for _, r := range requests {
+		uids = append(uids, r.UserID)
+	}
+	users, err := usersByUID(dbFor(c), uids)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := make(map[string]bool)
+	for _, uid := range uids {
+		user, ok := users[uid]
+		if !ok {
+			continue
+		}
+		if hasInvolvement, _ := checkUserInvolvementBesidesRide(dbFor(c), uid, user.ID, ride.Date, ride.OrganizationID, ride.ID); hasInvolvement {
+			busy[uid] = true
+		}
+	}
+	return busy, nil
+}
+
+// respondParticipantsBusy refuses a new date while some participants ride
+// elsewhere that day, listing them so the leader can drop them
+func respondParticipantsBusy(c *gin.Context, ride Ride, busy []Participant) {
+	uids := make([]string, 0, len(busy))
+	for _, p := range busy {
+		uids = append(uids, p.UserID)
+	}
+	users, err := usersByUID(dbFor(c), uids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_participants_failed")
+		return
+	}
+
+	choices := make([]gin.H, 0, len(busy))
+	for _, p := range busy {
+		choices = append(choices, gin.H{"participant_id": p.ID, "name": visibleProfile(users[p.UserID], true, false).Name, "joined_at": p.JoinedAt})
+	}
+	respondErrorWithDetails(c, http.StatusConflict, "error.participants_date_conflict", gin.H{
+		"date":            ride.Date,
+		"participants":    choices,
+		"action_required": "drop_participants",
+	}, MessageArgs{"count": len(busy), "date": ride.Date})
+}
+
+// respondSeatsBelowFilled refuses seats below the participants staying on
+// ride, listing them so the leader can choose whom to drop
+func respondSeatsBelowFilled(c *gin.Context, ride Ride, staying []Participant) {
+	uids := make([]string, 0, len(staying))
+	for _, p := range staying {
+		uids = append(uids, p.UserID)
+	}
+	users, err := usersByUID(dbFor(c), uids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_participants_failed")
+		return
+	}
+
+	// The leader counts as a co-rider of their participants
+	choices := make([]gin.H, 0, len(staying))
+	for _, p := range staying {
+		choices = append(choices, gin.H{"participant_id": p.ID, "name": visibleProfile(users[p.UserID], true, false).Name, "joined_at": p.JoinedAt})
+	}
+	respondErrorWithDetails(c, http.StatusConflict, "error.seats_below_filled", gin.H{
+		"seats":           ride.Seats,
+		"seats_filled":    len(staying),
+		"drop_at_least":   len(staying) - ride.Seats,
+		"participants":    choices,
+		"action_required": "drop_participants",
+	}, MessageArgs{"seats": ride.Seats, "filled": len(staying)})
+}
+
+// notifyRideUpdated tells staying participants and open requesters what
+// changed, dropped participants that they were removed and released
+// requesters that their request was withdrawn. It returns how many users
+// were notified.
+func notifyRideUpdated(c *gin.Context, leader User, ride Ride, changes []RideChange, significant bool, staying, dropped []Participant, requests, released []Request) int {
+	payload := NotificationPayload{ActorUserID: leader.ID, ActorName: leader.Name, Ride: newRideSnapshot(ride), Changes: changes}
+	notified := 0
+	notify := func(uid, templateKey string) {
+		if err := createNotification(dbFor(c), uid, templateKey, ride.ID, payload); err != nil {
+			slog.ErrorContext(c.Request.Context(), "failed to create notification", "recipient_uid", uid, "error", err)
+			return
+		}
+		notified++
+	}
+
+	if len(changes) > 0 {
+		participantTemplate := "ride_updated"
+		if significant {
+			participantTemplate = "ride_updated.significant"
+		}
+		for _, p := range staying {
+			notify(p.UserID, participantTemplate)
+		}
+		for _, r := range requests {
+			notify(r.UserID, "ride_updated")
+		}
+	}
+
+	payload.Changes = nil
+	payload.Reason = reasonRideEdited
+	for _, p := range dropped {
+		notify(p.UserID, "participant_removed")
+		cancellations.WithLabelValues("participant_removed").Inc()
+	}
+	payload.Reason = reasonDateConflict
+	for _, r := range released {
+		notify(r.UserID, "request_released")
+		cancellations.WithLabelValues("request_released").Inc()
+	}
+	return notified
+}
+
+// rideChangesText renders changes for a notification message, e.g.
+// "time: 09:00 → 10:30, price: 100 → 120"
+func rideChangesText(locale string, changes []RideChange) string {
+	parts := make([]string, 0, len(changes))
+	for _, change := range changes {
+		label := localize(locale, "ride_field."+change.Field, nil)
+		parts = append(parts, fmt.Sprintf("%s: %s → %s", label, change.Old, change.New))
+	}
+	return strings.Join(parts, ", ")
+}
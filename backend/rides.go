@@ -1,27 +1,31 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type Ride struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	LeaderID    uint      `json:"leader_id"`
-	Origin      string    `json:"origin"`
-	Destination string    `json:"destination"`
-	Date        string    `json:"date"` // e.g. "2025-05-20"
-	Time        string    `json:"time"` // e.g. "15:30"
-	Seats       int       `json:"seats"`
-	SeatsFilled int       `json:"seats_filled"`
-	Price       float64   `json:"price"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                   uint      `gorm:"primaryKey" json:"id"`
+	LeaderID             uint      `json:"leader_id"`
+	Origin               string    `json:"origin"`
+	Destination          string    `json:"destination"`
+	Date                 string    `json:"date"` // e.g. "2025-05-20"
+	Time                 string    `json:"time"` // e.g. "15:30"
+	Seats                int       `json:"seats"`
+	SeatsFilled          int       `json:"seats_filled"`
+	Price                float64   `json:"price"`
+	RequireVerifiedPhone bool      `gorm:"default:false" json:"require_verified_phone"` // Only users with a verified phone number can join
+	OrganizationID       *uint     `gorm:"index" json:"organization_id"`                // Only members of the organization see the ride; nil for the public pool
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
 }
 
 // POST /ride
@@ -29,41 +33,56 @@ func AddRide(c *gin.Context) {
 	var ride Ride
 
 	if err := c.ShouldBindJSON(&ride); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		respondError(c, http.StatusBadRequest, "error.invalid_input", MessageArgs{"details": err.Error()})
 		return
 	}
 
 	userID, exists := c.Get("uid")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		respondError(c, http.StatusUnauthorized, "error.not_authenticated")
 		return
 	}
 
 	// Convert Firebase UID (string) to find the user's ID
-	user, err := getUser(userID.(string))
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
 	ride.LeaderID = user.ID
 
 	if _, err := time.Parse("15:04", ride.Time); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time format, expected HH:mm"})
+		respondError(c, http.StatusBadRequest, "error.invalid_time_format")
 		return
 	}
 
 	if _, err := time.Parse("2006-01-02", ride.Date); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format, expected YYYY-MM-DD"})
+		respondError(c, http.StatusBadRequest, "error.invalid_date_format")
 		return
 	}
 
-	// Check for any existing involvement on this date
-	hasInvolvement, involvementDetails := checkUserInvolvementForDate(userID.(string), user.ID, ride.Date)
+	// Organization rides can only be posted by members, between the organization's allowed locations
+	if ride.OrganizationID != nil {
+		var org Organization
+		if err := dbFor(c).First(&org, *ride.OrganizationID).Error; err != nil || membershipRole(dbFor(c), org.ID, user.FirebaseUID) == "" {
+			respondError(c, http.StatusForbidden, "error.not_organization_member")
+			return
+		}
+		for _, location := range []string{ride.Origin, ride.Destination} {
+			if !org.locationAllowed(location) {
+				respondErrorWithDetails(c, http.StatusBadRequest, "error.location_not_allowed",
+					gin.H{"allowed_locations": org.AllowedLocations}, MessageArgs{"location": location})
+				return
+			}
+		}
+	}
+
+	// Check for any existing involvement on this date, in the ride's organization and the public pool
+	hasInvolvement, involvementDetails := checkUserInvolvementForDate(dbFor(c), userID.(string), user.ID, ride.Date, ride.OrganizationID)
 
 	if hasInvolvement {
-		c.JSON(http.StatusConflict, gin.H{
-			"error":               "You are already involved in rides for this date. Please clear your involvement first.",
+		respondErrorWithDetails(c, http.StatusConflict, "error.involvement_conflict", gin.H{
 			"involvement_details": involvementDetails,
 			"action_required":     "clear_involvement",
 			"date":                ride.Date,
@@ -73,27 +92,33 @@ func AddRide(c *gin.Context) {
 
 	ride.SeatsFilled = 0
 
-	if err := DB.Create(&ride).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not save ride: " + err.Error()})
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ride).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "ride_created", TargetType: "ride", TargetID: ride.ID, RideID: ride.ID, After: ride})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.save_ride_failed", MessageArgs{"details": err.Error()})
 		return
 	}
+	invalidateRide(ride.ID)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Ride added successfully", "ride": ride})
+	ridesCreated.Inc()
+	c.JSON(http.StatusOK, RideCreatedResponse{Message: tr(c, "message.ride_added"), Ride: ride})
 }
 
 // GET /user/rides/posted
 func GetRidesPostedByUser(c *gin.Context) {
-	userID := c.MustGet("uid").(string)
-
-	user, err := getUser(userID)
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
-	var rides []Ride
-	if err := DB.Where("leader_id = ?", user.ID).Find(&rides).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rides"})
+	rides := []Ride{}
+	if err := dbFor(c).Where("leader_id = ?", user.ID).Find(&rides).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_rides_failed")
 		return
 	}
 
@@ -106,8 +131,8 @@ func GetRidesJoinedByUser(c *gin.Context) {
 
 	// Find all rides where user is actually a participant (not just approved)
 	var participants []Participant
-	if err := DB.Where("user_id = ?", userID).Find(&participants).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch participant data"})
+	if err := dbFor(c).Where("user_id = ?", userID).Find(&participants).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_participant_data_failed")
 		return
 	}
 
@@ -116,10 +141,10 @@ func GetRidesJoinedByUser(c *gin.Context) {
 		rideIDs = append(rideIDs, p.RideID)
 	}
 
-	var rides []Ride
+	rides := []Ride{}
 	if len(rideIDs) > 0 {
-		if err := DB.Where("id IN ?", rideIDs).Find(&rides).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rides"})
+		if err := dbFor(c).Where("id IN ?", rideIDs).Find(&rides).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "error.fetch_rides_failed")
 			return
 		}
 	}
@@ -127,21 +152,36 @@ func GetRidesJoinedByUser(c *gin.Context) {
 	c.JSON(http.StatusOK, rides)
 }
 
-// GET /rides/filter?origin=College Campus&destination=City Airport&date=2025-06-10
+// GET /rides/filter?origin=College Campus&destination=City Airport&date=2025-06-10[&organization_id=1]
+// Anonymous callers only see the public pool, signed-in ones also their organizations' rides
 func FilterRides(c *gin.Context) {
 	origin := c.Query("origin")
 	destination := c.Query("destination")
 	date := c.Query("date")
+	uid := c.GetString("uid")
 
-	var rides []Ride
+	orgID, ok := organizationQuery(c)
+	if !ok {
+		return
+	}
+
+	rides, err := rideFilterCache.load(rideFilterKey(uid, origin, destination, date, orgID), func() ([]Ride, error) {
+		query := dbFor(c).Where("origin = ? AND destination = ? AND date = ?", origin, destination, date).Scopes(visibleRides(uid))
+		if orgID != nil {
+			query = query.Where("rides.organization_id = ?", *orgID)
+		}
 
-	// Use SafeQuery to handle potential prepared statement conflicts9AM
-	err := SafeQuery(func() error {
-		return DB.Where("origin = ? AND destination = ? AND date = ?", origin, destination, date).Find(&rides).Error
+		rides := []Ride{}
+
+		// Use SafeQuery to handle potential prepared statement conflicts9AM
+		err := SafeQuery(func() error {
+			return query.Find(&rides).Error
+		})
+		return rides, err
 	})
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rides"})
+		respondError(c, http.StatusInternalServerError, "error.fetch_rides_failed")
 		return
 	}
 
@@ -153,50 +193,59 @@ func GetJoinRequestsForRide(c *gin.Context) {
 	rideIDParam := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
-	userID := c.MustGet("uid").(string)
-
-	var ride Ride
-	if err := DB.First(&ride, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	ride, err := cachedRide(dbFor(c), uint(rideID))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
-	user, err := getUser(userID)
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
 	if ride.LeaderID != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the leader of this ride"})
+		respondError(c, http.StatusForbidden, "error.not_ride_leader")
 		return
 	}
 
 	var requests []Request
-	if err := DB.Where("ride_id = ? AND status = ?", rideID, "pending").Find(&requests).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch join requests"})
+	if err := dbFor(c).Where("ride_id = ? AND status = ?", rideID, "pending").Find(&requests).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_join_requests_failed")
+		return
+	}
+
+	uids := make([]string, 0, len(requests))
+	for _, r := range requests {
+		uids = append(uids, r.UserID)
+	}
+	requesters, err := usersByUID(dbFor(c), uids)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_join_requests_failed")
 		return
 	}
 
 	// Build response with request details
-	var response []map[string]interface{}
+	response := make([]JoinRequestResponse, 0, len(requests))
 	for _, r := range requests {
-		user, err := getUser(r.UserID)
-		if err != nil {
+		requester, ok := requesters[r.UserID]
+		if !ok {
 			continue // skip if user doesn't exist
 		}
 
-		entry := map[string]interface{}{
-			"request_id": r.ID,
-			"name":       user.Name,
-			"gender":     user.Gender,
-			"status":     r.Status,
-		}
-		response = append(response, entry)
+		// Requesters aren't co-riders yet, so only what they share with everyone is shown
+		fields := visibleProfile(requester, false, false)
+		response = append(response, JoinRequestResponse{
+			RequestID: r.ID,
+			Name:      fields.Name,
+			Gender:    fields.Gender,
+			Status:    r.Status,
+		})
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -207,43 +256,41 @@ func DeleteRide(c *gin.Context) {
 	rideIDParam := c.Param("rideID")
 	rideID, err := strconv.Atoi(rideIDParam)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ride ID"})
+		respondError(c, http.StatusBadRequest, "error.invalid_ride_id")
 		return
 	}
 
-	userID := c.MustGet("uid").(string)
-
 	// Get the ride to be deleted
 	var ride Ride
-	if err := DB.First(&ride, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	if err := dbFor(c).First(&ride, "id = ?", rideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
 	// Get current user to verify they are the leader
-	user, err := getUser(userID)
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
 	// Check if the current user is the leader of this ride
 	if ride.LeaderID != user.ID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not the leader of this ride"})
+		respondError(c, http.StatusForbidden, "error.not_ride_leader")
 		return
 	}
 
 	// Get all participants to notify them
 	var participants []Participant
-	if err := DB.Where("ride_id = ?", rideID).Find(&participants).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch participants"})
+	if err := dbFor(c).Where("ride_id = ?", rideID).Find(&participants).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "error.fetch_participants_failed")
 		return
 	}
 
 	// Start a transaction to ensure data consistency
-	tx := DB.Begin()
+	tx := dbFor(c).Begin()
 	if tx.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		respondError(c, http.StatusInternalServerError, "error.transaction_start_failed")
 		return
 	}
 
@@ -252,80 +299,150 @@ func DeleteRide(c *gin.Context) {
 	// 1. Delete all notifications related to this ride
 	if err := tx.Where("ride_id = ?", rideID).Delete(&Notification{}).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ride notifications"})
+		respondError(c, http.StatusInternalServerError, "error.delete_ride_notifications_failed")
 		return
 	}
 
 	// 2. Delete all participants
 	if err := tx.Where("ride_id = ?", rideID).Delete(&Participant{}).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete participants"})
+		respondError(c, http.StatusInternalServerError, "error.delete_participants_failed")
 		return
 	}
 
-	// 3. Delete all join requests
+	// 3. Delete all join requests (keeping a copy for the audit log) and leadership offers
+	var requests []Request
+	if err := tx.Where("ride_id = ?", rideID).Find(&requests).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, "error.fetch_join_requests_failed")
+		return
+	}
 	if err := tx.Where("ride_id = ?", rideID).Delete(&Request{}).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete join requests"})
+		respondError(c, http.StatusInternalServerError, "error.delete_join_requests_failed")
+		return
+	}
+	if err := tx.Where("ride_id = ?", rideID).Delete(&LeadershipTransfer{}).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, "error.delete_ride_failed")
 		return
 	}
 
 	// 4. Finally delete the ride itself
 	if err := tx.Delete(&ride).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ride"})
+		respondError(c, http.StatusInternalServerError, "error.delete_ride_failed")
+		return
+	}
+
+	if err := recordAudit(tx, c, auditEntry{
+		Action:     "ride_deleted",
+		TargetType: "ride",
+		TargetID:   ride.ID,
+		RideID:     ride.ID,
+		Before: gin.H{
+			"ride":         ride,
+			"participants": participantSnapshots(participants),
+			"requests":     requestSnapshots(requests),
+		},
+	}); err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, "error.delete_ride_failed")
 		return
 	}
 
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		respondError(c, http.StatusInternalServerError, "error.transaction_commit_failed")
 		return
 	}
+	invalidateRide(ride.ID)
 
 	// Send notifications to all participants about the ride cancellation
-	title := "Ride Cancelled by Leader"
-	message := fmt.Sprintf("The ride from %s to %s on %s at %s has been cancelled by the leader %s",
-		ride.Origin, ride.Destination, ride.Date, ride.Time, user.Name)
+	payload := NotificationPayload{
+		ActorUserID: user.ID,
+		ActorName:   user.Name,
+		Ride:        newRideSnapshot(ride),
+		Reason:      reasonLeaderCancelled,
+	}
 
 	notificationCount := 0
 	for _, participant := range participants {
-		if err := createNotification(participant.UserID, title, message, "ride_cancelled", uint(rideID)); err != nil {
+		if err := createNotification(dbFor(c), participant.UserID, "ride_cancelled", uint(rideID), payload); err != nil {
 			// Log error but don't fail the request
-			fmt.Printf("Failed to create notification for participant %s: %v\n", participant.UserID, err)
+			slog.ErrorContext(c.Request.Context(), "failed to create notification", "recipient_uid", participant.UserID, "error", err)
 		} else {
 			notificationCount++
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":               fmt.Sprintf("Ride deleted successfully. %d participants have been notified.", notificationCount),
-		"participants_notified": notificationCount,
-		"ride_id":               rideID,
+	cancellations.WithLabelValues("ride").Inc()
+	c.JSON(http.StatusOK, DeleteRideResponse{
+		Message:              trPlural(c, "message.ride_deleted", notificationCount),
+		ParticipantsNotified: notificationCount,
+		RideID:               rideID,
 	})
 }
 
 // cleanupExpiredRides removes all rides with dates that have already passed
 func cleanupExpiredRides() {
-	currentDate := time.Now().Format("2006-01-02")
+	if _, err := cleanupRidesBefore(context.Background(), time.Now().Format("2006-01-02"), false); err != nil {
+		slog.Error("expired ride cleanup failed", "error", err)
+	}
+}
 
-	// Find all rides with dates before today
+// cleanupReport lists the rides a cleanup run found and how many it deleted
+type cleanupReport struct {
+	Rides   []cleanupRide `json:"rides"`
+	Deleted int           `json:"deleted"`
+	DryRun  bool          `json:"dry_run"`
+}
+
+// cleanupRide is a ride found by a cleanup run with the people it affects
+type cleanupRide struct {
+	Ride         Ride `json:"ride"`
+	Participants int  `json:"participants"`
+	Requests     int  `json:"requests"`
+}
+
+// cleanupRidesBefore deletes every ride dated before date ("2006-01-02"),
+// notifying its participants and leader first. With dryRun nothing is
+// changed and the report only lists what would be deleted.
+func cleanupRidesBefore(ctx context.Context, date string, dryRun bool) (cleanupReport, error) {
+	start := time.Now()
+	db := DB.WithContext(ctx)
+	report := cleanupReport{DryRun: dryRun}
+
+	// Find all rides with dates before the cutoff
 	var expiredRides []Ride
-	if err := DB.Where("date < ?", currentDate).Find(&expiredRides).Error; err != nil {
-		log.Printf("Failed to find expired rides: %v", err)
-		return
+	if err := db.Where("date < ?", date).Order("date, id").Find(&expiredRides).Error; err != nil {
+		if !dryRun {
+			observeCleanup(start, "error", 0)
+		}
+		return report, fmt.Errorf("error finding expired rides: %v", err)
+	}
+
+	if dryRun {
+		for _, ride := range expiredRides {
+			var participants, requests int64
+			db.Model(&Participant{}).Where("ride_id = ?", ride.ID).Count(&participants)
+			db.Model(&Request{}).Where("ride_id = ?", ride.ID).Count(&requests)
+			report.Rides = append(report.Rides, cleanupRide{Ride: ride, Participants: int(participants), Requests: int(requests)})
+		}
+		return report, nil
 	}
 
 	if len(expiredRides) == 0 {
-		log.Println("No expired rides found")
-		return
+		slog.Debug("no expired rides found")
+		observeCleanup(start, "noop", 0)
+		return report, nil
 	}
 
 	// Start a transaction to ensure data consistency
-	tx := DB.Begin()
+	tx := db.Begin()
 	if tx.Error != nil {
-		log.Printf("Failed to start transaction for cleanup: %v", tx.Error)
-		return
+		observeCleanup(start, "error", 0)
+		return report, fmt.Errorf("error starting cleanup transaction: %v", tx.Error)
 	}
 
 	deletedCount := 0
@@ -333,65 +450,117 @@ func cleanupExpiredRides() {
 		// Get all participants for this ride to send completion notifications
 		var participants []Participant
 		if err := tx.Where("ride_id = ?", ride.ID).Find(&participants).Error; err != nil {
-			log.Printf("Failed to fetch participants for ride %d: %v", ride.ID, err)
+			slog.Error("failed to fetch participants of expired ride", "ride_id", ride.ID, "error", err)
 			continue
 		}
+		var requests int64
+		tx.Model(&Request{}).Where("ride_id = ?", ride.ID).Count(&requests)
+		report.Rides = append(report.Rides, cleanupRide{Ride: ride, Participants: len(participants), Requests: int(requests)})
 
 		// Get ride leader details for the completion notification
 		var leader User
 		if err := tx.First(&leader, "id = ?", ride.LeaderID).Error; err != nil {
-			log.Printf("Failed to fetch leader for ride %d: %v", ride.ID, err)
+			slog.Error("failed to fetch leader of expired ride", "ride_id", ride.ID, "error", err)
 			continue
 		}
 
 		// Send "Ride Completed" notifications to all participants BEFORE deleting
-		participantCount := len(participants)
-		title := "Ride Completed"
-		message := fmt.Sprintf("Your ride from %s to %s on %s at %s with leader %s has been completed. Total participants: %d",
-			ride.Origin, ride.Destination, ride.Date, ride.Time, leader.Name, participantCount)
+		payload := NotificationPayload{
+			ActorUserID:      leader.ID,
+			ActorName:        leader.Name,
+			Ride:             newRideSnapshot(ride),
+			ParticipantCount: len(participants),
+		}
 
 		// Send completion notification to all participants
 		for _, participant := range participants {
-			if err := createNotification(participant.UserID, title, message, "ride_completed", ride.ID); err != nil {
-				log.Printf("Failed to create completion notification for participant %s: %v", participant.UserID, err)
+			if err := createNotification(tx, participant.UserID, "ride_completed.participant", ride.ID, payload); err != nil {
+				slog.Error("failed to create completion notification", "recipient_uid", participant.UserID, "error", err)
 			}
 		}
 
 		// Also send completion notification to the leader
-		leaderMessage := fmt.Sprintf("Your ride from %s to %s on %s at %s has been completed. Total participants: %d",
-			ride.Origin, ride.Destination, ride.Date, ride.Time, participantCount)
-		if err := createNotification(leader.FirebaseUID, title, leaderMessage, "ride_completed", ride.ID); err != nil {
-			log.Printf("Failed to create completion notification for leader %s: %v", leader.FirebaseUID, err)
+		if err := createNotification(tx, leader.FirebaseUID, "ride_completed.leader", ride.ID, payload); err != nil {
+			slog.Error("failed to create completion notification", "recipient_uid", leader.FirebaseUID, "error", err)
 		}
 
 		// Delete ride-related data (but keep notifications for history)
 		// 1. Delete all participants
 		if err := tx.Where("ride_id = ?", ride.ID).Delete(&Participant{}).Error; err != nil {
-			log.Printf("Failed to delete participants for ride %d: %v", ride.ID, err)
+			slog.Error("failed to delete participants of expired ride", "ride_id", ride.ID, "error", err)
 			continue
 		}
 
-		// 2. Delete all join requests
+		// 2. Delete all join requests and leadership offers
 		if err := tx.Where("ride_id = ?", ride.ID).Delete(&Request{}).Error; err != nil {
-			log.Printf("Failed to delete requests for ride %d: %v", ride.ID, err)
+			slog.Error("failed to delete requests of expired ride", "ride_id", ride.ID, "error", err)
+			continue
+		}
+		if err := tx.Where("ride_id = ?", ride.ID).Delete(&LeadershipTransfer{}).Error; err != nil {
+			slog.Error("failed to delete leadership transfers of expired ride", "ride_id", ride.ID, "error", err)
 			continue
 		}
 
 		// 3. Finally delete the ride itself
 		if err := tx.Delete(&ride).Error; err != nil {
-			log.Printf("Failed to delete expired ride %d: %v", ride.ID, err)
+			slog.Error("failed to delete expired ride", "ride_id", ride.ID, "error", err)
 			continue
 		}
 
+		if err := recordAudit(tx, nil, auditEntry{
+			Action:     "ride_expired",
+			TargetType: "ride",
+			TargetID:   ride.ID,
+			RideID:     ride.ID,
+			Before:     gin.H{"ride": ride, "participants": participantSnapshots(participants)},
+		}); err != nil {
+			// The ride is already gone in this transaction, so don't keep that without its record
+			tx.Rollback()
+			observeCleanup(start, "error", 0)
+			return report, fmt.Errorf("error auditing expired ride %d: %v", ride.ID, err)
+		}
+
 		// NOTE: We intentionally DO NOT delete notifications - they serve as ride history
 		deletedCount++
 	}
 
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
-		log.Printf("Failed to commit cleanup transaction: %v", err)
-		return
+		observeCleanup(start, "error", 0)
+		return report, fmt.Errorf("error committing cleanup transaction: %v", err)
+	}
+	for _, ride := range expiredRides {
+		invalidateRide(ride.ID)
 	}
 
-	log.Printf("✅ Cleanup completed: %d expired rides deleted (out of %d found). Completion notifications sent to all participants.", deletedCount, len(expiredRides))
+	slog.Info("expired rides cleaned up", "deleted", deletedCount, "found", len(expiredRides), "before", date)
+	observeCleanup(start, "success", deletedCount)
+	report.Deleted = deletedCount
+	return report, nil
+}
+
+// ridesByID loads the rides with the given IDs in one query, keyed by ID.
+// Deleted rides are missing from the map.
+func ridesByID(db *gorm.DB, ids []uint) (map[uint]Ride, error) {
+	byID := make(map[uint]Ride, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+	var rides []Ride
+	if err := db.Where("id IN ?", ids).Find(&rides).Error; err != nil {
+		return nil, err
+	}
+	for _, ride := range rides {
+		byID[ride.ID] = ride
+	}
+	return byID, nil
+}
+
+// rideLeaders loads the leaders of rides in one query, keyed by user ID
+func rideLeaders(db *gorm.DB, rides map[uint]Ride) (map[uint]User, error) {
+	ids := make([]uint, 0, len(rides))
+	for _, ride := range rides {
+		ids = append(ids, ride.LeaderID)
+	}
+	return usersByID(db, ids)
 }
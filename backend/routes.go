@@ -0,0 +1,241 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryParam documents a query string parameter of a route
+type queryParam struct {
+	Name        string
+	Type        string // OpenAPI type: "string", "integer"
+	Format      string // Optional OpenAPI format, e.g. "date"
+	Required    bool
+	Description string
+}
+
+// routeSpec is one entry of the route table. The same table mounts the gin
+// routes and generates the OpenAPI document served at /openapi.json.
+type routeSpec struct {
+	Method     string
+	Path       string // gin syntax, relative to /v1, e.g. "/ride/:rideID"
+	Handler    gin.HandlerFunc
+	Middleware []gin.HandlerFunc // Extra per-route middleware, e.g. a rate limit budget
+	Auth       string            // "public", "optional" (Firebase token if sent), "user" (Firebase token) or "admin"
+	Tag        string
+	Summary    string
+	Query      []queryParam
+	Request    interface{} // JSON body DTO, nil if the route takes no body
+	Response   interface{} // Success response DTO
+	Status     int         // Success status, defaults to 200
+	Produces   string      // Content type of a non-JSON success response, e.g. "application/zip"
+}
+
+// apiRoutes is the route table for the whole API
+var apiRoutes = []routeSpec{
+	// Public routes
+	{Method: http.MethodGet, Path: "/public", Handler: PublicEndpoint, Middleware: []gin.HandlerFunc{RateLimitMiddleware("public")},
+		Auth: "public", Tag: "System", Summary: "Public route example", Response: MessageResponse{}},
+	{Method: http.MethodGet, Path: "/ping", Handler: Ping, Middleware: []gin.HandlerFunc{RateLimitMiddleware("ping")},
+		Auth: "public", Tag: "System", Summary: "Connectivity check that also cleans up expired rides", Response: MessageResponse{}},
+	{Method: http.MethodGet, Path: "/ride/filter", Handler: FilterRides, Middleware: []gin.HandlerFunc{RateLimitMiddleware("public")},
+		Auth: "optional", Tag: "Rides", Summary: "Find rides by origin, destination and date; signed-in users also see their organizations' rides",
+		Query: []queryParam{
+			{Name: "origin", Type: "string", Required: true, Description: "e.g. College Campus"},
+			{Name: "destination", Type: "string", Required: true, Description: "e.g. City Airport"},
+			{Name: "date", Type: "string", Format: "date", Required: true, Description: "YYYY-MM-DD"},
+			{Name: "organization_id", Type: "integer", Description: "Only rides of this organization"},
+		},
+		Response: []Ride{}},
+
+	// User APIs
+	{Method: http.MethodGet, Path: "/user", Handler: GetCurrentUser, Auth: "user", Tag: "Users",
+		Summary: "Get current user profile", Response: User{}},
+	{Method: http.MethodPut, Path: "/user", Handler: UpdateCurrentUser, Auth: "user", Tag: "Users",
+		Summary: "Update current user profile", Request: UpdateUserRequest{}, Response: User{}},
+	{Method: http.MethodPost, Path: "/user", Handler: CreateUser, Auth: "user", Tag: "Users",
+		Summary: "Create the profile of the signed-in user", Request: CreateUserRequest{}, Response: User{}, Status: http.StatusCreated},
+	{Method: http.MethodPost, Path: "/user/phone/verify/start", Handler: StartPhoneVerification, Middleware: []gin.HandlerFunc{RateLimitMiddleware("phone_verify")},
+		Auth: "user", Tag: "Users", Summary: "Text a verification code to a phone number",
+		Request: StartPhoneVerificationRequest{}, Response: PhoneVerificationResponse{}, Status: http.StatusAccepted},
+	{Method: http.MethodPost, Path: "/user/phone/verify/confirm", Handler: ConfirmPhoneVerification, Auth: "user", Tag: "Users",
+		Summary: "Confirm the code and mark the phone number as verified", Request: ConfirmPhoneVerificationRequest{}, Response: User{}},
+	{Method: http.MethodDelete, Path: "/user", Handler: DeleteCurrentUser, Auth: "user", Tag: "Users",
+		Summary: "Delete the current user's account after a grace period", Response: AccountDeletionResponse{}, Status: http.StatusAccepted},
+	{Method: http.MethodPost, Path: "/user/restore", Handler: RestoreCurrentUser, Auth: "user", Tag: "Users",
+		Summary: "Cancel a scheduled account deletion", Response: User{}},
+	{Method: http.MethodGet, Path: "/user/:userID", Handler: GetUserBasic, Auth: "user", Tag: "Users",
+		Summary: "Get another user's public profile", Response: UserBasicResponse{}},
+	{Method: http.MethodGet, Path: "/user/rides/posted", Handler: GetRidesPostedByUser, Auth: "user", Tag: "Users",
+		Summary: "Rides the current user leads", Response: []Ride{}},
+	{Method: http.MethodGet, Path: "/user/rides/joined", Handler: GetRidesJoinedByUser, Auth: "user", Tag: "Users",
+		Summary: "Rides the current user has joined", Response: []Ride{}},
+	{Method: http.MethodGet, Path: "/user/privileges", Handler: GetUserPrivileges, Auth: "user", Tag: "Users",
+		Summary: "Approved requests the current user can use to join", Response: []PrivilegeResponse{}},
+	{Method: http.MethodGet, Path: "/user/requests", Handler: GetUserSentRequests, Auth: "user", Tag: "Users",
+		Summary: "Join requests sent by the current user", Response: []SentRequestResponse{}},
+	{Method: http.MethodDelete, Path: "/user/clear-involvement/:date", Handler: ClearInvolvementForDate, Auth: "user", Tag: "Users",
+		Summary:  "Cancel all pending requests and privileges for a date",
+		Query:    []queryParam{{Name: "organization_id", Type: "integer", Description: "Only those that count against rides of this organization"}},
+		Response: ClearInvolvementResponse{}},
+	{Method: http.MethodGet, Path: "/user/notifications", Handler: GetUserNotifications, Auth: "user", Tag: "Notifications",
+		Summary:  "Notifications of the current user",
+		Query:    []queryParam{{Name: "organization_id", Type: "integer", Description: "Only notifications about rides of this organization"}},
+		Response: []NotificationResponse{}},
+	{Method: http.MethodGet, Path: "/user/notifications/unread-count", Handler: GetUnreadNotificationCount, Auth: "user", Tag: "Notifications",
+		Summary:  "Number of unread notifications",
+		Query:    []queryParam{{Name: "organization_id", Type: "integer", Description: "Only notifications about rides of this organization"}},
+		Response: UnreadCountResponse{}},
+	{Method: http.MethodPut, Path: "/user/notifications/mark-all-read", Handler: MarkAllNotificationsAsRead, Auth: "user", Tag: "Notifications",
+		Summary:  "Mark every notification as read",
+		Query:    []queryParam{{Name: "organization_id", Type: "integer", Description: "Only notifications about rides of this organization"}},
+		Response: MarkAllReadResponse{}},
+	{Method: http.MethodGet, Path: "/user/export", Handler: RequestDataExport, Auth: "user", Tag: "Users",
+		Summary: "Start an export of all the current user's data, or get the one in progress", Response: DataExportResponse{}, Status: http.StatusAccepted},
+	{Method: http.MethodGet, Path: "/user/export/:exportID", Handler: GetDataExport, Auth: "user", Tag: "Users",
+		Summary: "Status of a data export, with a download link once ready", Response: DataExportResponse{}},
+	{Method: http.MethodGet, Path: "/user/export/:exportID/download", Handler: DownloadDataExport, Middleware: []gin.HandlerFunc{RateLimitMiddleware("public")},
+		Auth: "public", Tag: "Users", Summary: "Download a data export archive once, using the signed link from its status",
+		Query: []queryParam{
+			{Name: "expires", Type: "integer", Required: true, Description: "From download_url"},
+			{Name: "signature", Type: "string", Required: true, Description: "From download_url"},
+		},
+		Produces: "application/zip"},
+	{Method: http.MethodGet, Path: "/user/organizations", Handler: GetUserOrganizations, Auth: "user", Tag: "Organizations",
+		Summary: "Organizations the current user belongs to", Response: []UserOrganizationResponse{}},
+	{Method: http.MethodDelete, Path: "/user/cancel-ride/:rideID", Handler: CancelRideParticipation, Auth: "user", Tag: "Users",
+		Summary: "Cancel a pending request or leave a joined ride", Response: CancelParticipationResponse{}},
+
+	// Ride APIs
+	{Method: http.MethodPost, Path: "/ride", Handler: AddRide, Middleware: []gin.HandlerFunc{RateLimitMiddleware("ride_create")},
+		Auth: "user", Tag: "Rides", Summary: "Post a ride", Request: Ride{}, Response: RideCreatedResponse{}},
+	{Method: http.MethodDelete, Path: "/ride/:rideID", Handler: DeleteRide, Auth: "user", Tag: "Rides",
+		Summary: "Leader deletes their ride", Response: DeleteRideResponse{}},
+	{Method: http.MethodPatch, Path: "/ride/:rideID", Handler: UpdateRide, Auth: "user", Tag: "Rides",
+		Summary: "Leader edits their ride", Request: UpdateRideRequest{}, Response: RideUpdatedResponse{}},
+	{Method: http.MethodGet, Path: "/ride/:rideID/leader", Handler: GetRideLeader, Auth: "user", Tag: "Rides",
+		Summary: "Leader of a ride", Response: RideLeaderResponse{}},
+	{Method: http.MethodGet, Path: "/ride/:rideID/requests", Handler: GetJoinRequestsForRide, Auth: "user", Tag: "Rides",
+		Summary: "Pending join requests for a ride (leader only)", Response: []JoinRequestResponse{}},
+	{Method: http.MethodPost, Path: "/ride/:rideID/join", Handler: SendJoinRequest, Middleware: []gin.HandlerFunc{RateLimitMiddleware("join_request")},
+		Auth: "user", Tag: "Rides", Summary: "Send a join request", Response: MessageResponse{}},
+	{Method: http.MethodDelete, Path: "/ride/:rideID/cancel-request", Handler: CancelJoinRequest, Auth: "user", Tag: "Rides",
+		Summary: "Cancel a pending join request", Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/ride/:rideID/join-ride", Handler: JoinRideWithPrivilege, Auth: "user", Tag: "Rides",
+		Summary: "Join a ride using an approved privilege", Response: JoinRideResponse{}},
+
+	// Participant Management APIs (Leaders only)
+	{Method: http.MethodGet, Path: "/ride/:rideID/participants", Handler: GetRideParticipants, Auth: "user", Tag: "Participants",
+		Summary: "Participants of a ride", Response: []ParticipantResponse{}},
+	{Method: http.MethodDelete, Path: "/ride/:rideID/participant/:participantID", Handler: RemoveParticipant, Auth: "user", Tag: "Participants",
+		Summary: "Remove a participant from a ride", Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/ride/:rideID/approve/:requestID", Handler: ApproveJoinRequest, Auth: "user", Tag: "Participants",
+		Summary: "Approve a join request", Response: MessageResponse{}},
+	{Method: http.MethodPost, Path: "/ride/:rideID/reject/:requestID", Handler: RejectJoinRequest, Auth: "user", Tag: "Participants",
+		Summary: "Reject a join request", Response: MessageResponse{}},
+
+	// Leadership transfer APIs
+	{Method: http.MethodPost, Path: "/ride/:rideID/transfer-leadership/:participantID", Handler: TransferRideLeadership, Auth: "user", Tag: "Participants",
+		Summary:  "Offer the ride's leadership to a participant",
+		Query:    []queryParam{{Name: "stay_as_participant", Type: "boolean", Description: "Take the new leader's seat instead of leaving the ride"}},
+		Response: LeadershipTransferResponse{}},
+	{Method: http.MethodDelete, Path: "/ride/:rideID/transfer-leadership", Handler: WithdrawLeadershipTransfer, Auth: "user", Tag: "Participants",
+		Summary: "Withdraw a pending leadership offer", Response: LeadershipTransferResponse{}},
+	{Method: http.MethodPost, Path: "/ride/:rideID/accept-leadership", Handler: AcceptRideLeadership, Auth: "user", Tag: "Participants",
+		Summary: "Accept the ride's leadership offered to you", Response: LeadershipTransferredResponse{}},
+	{Method: http.MethodPost, Path: "/ride/:rideID/decline-leadership", Handler: DeclineRideLeadership, Auth: "user", Tag: "Participants",
+		Summary: "Decline the ride's leadership offered to you", Response: LeadershipTransferResponse{}},
+
+	// Organization APIs (members, or organization admins for changes)
+	{Method: http.MethodGet, Path: "/organization/:orgID", Handler: GetOrganization, Middleware: []gin.HandlerFunc{OrganizationMemberMiddleware()},
+		Auth: "user", Tag: "Organizations", Summary: "Settings of an organization", Response: Organization{}},
+	{Method: http.MethodPut, Path: "/organization/:orgID", Handler: UpdateOrganization, Middleware: []gin.HandlerFunc{OrganizationAdminMiddleware()},
+		Auth: "user", Tag: "Organizations", Summary: "Update an organization's settings", Request: OrganizationRequest{}, Response: Organization{}},
+	{Method: http.MethodGet, Path: "/organization/:orgID/members", Handler: GetOrganizationMembers, Middleware: []gin.HandlerFunc{OrganizationAdminMiddleware()},
+		Auth: "user", Tag: "Organizations", Summary: "Members of an organization", Response: []OrganizationMemberResponse{}},
+	{Method: http.MethodPut, Path: "/organization/:orgID/members", Handler: PutOrganizationMember, Middleware: []gin.HandlerFunc{OrganizationAdminMiddleware()},
+		Auth: "user", Tag: "Organizations", Summary: "Add a member or change their role", Request: MembershipRequest{}, Response: OrganizationMemberResponse{}},
+	{Method: http.MethodDelete, Path: "/organization/:orgID/members/:membershipID", Handler: DeleteOrganizationMember, Middleware: []gin.HandlerFunc{OrganizationAdminMiddleware()},
+		Auth: "user", Tag: "Organizations", Summary: "Remove a member", Response: MessageResponse{}},
+
+	// Notification APIs
+	{Method: http.MethodPost, Path: "/notification/:notificationID/read", Handler: MarkNotificationAsRead, Auth: "user", Tag: "Notifications",
+		Summary: "Mark a notification as read", Response: MessageResponse{}},
+
+	// Admin APIs
+	{Method: http.MethodGet, Path: "/admin/audit", Handler: GetAuditEvents, Auth: "admin", Tag: "Admin",
+		Summary: "Query the audit log",
+		Query: []queryParam{
+			{Name: "user_id", Type: "string", Description: "Database user ID or Firebase UID of the actor or subject"},
+			{Name: "ride_id", Type: "integer"},
+			{Name: "from", Type: "string", Format: "date-time"},
+			{Name: "to", Type: "string", Format: "date-time"},
+			{Name: "limit", Type: "integer", Description: "1-1000, defaults to 100"},
+		},
+		Response: []AuditEvent{}},
+	{Method: http.MethodPost, Path: "/admin/organizations", Handler: CreateOrganization, Auth: "admin", Tag: "Admin",
+		Summary: "Create an organization", Request: OrganizationRequest{}, Response: Organization{}, Status: http.StatusCreated},
+	{Method: http.MethodGet, Path: "/admin/email-access", Handler: GetEmailAccessOverrides, Auth: "admin", Tag: "Admin",
+		Summary: "List email addresses allowed or denied regardless of ALLOWED_EMAIL_DOMAINS", Response: []EmailAccessOverride{}},
+	{Method: http.MethodPut, Path: "/admin/email-access", Handler: PutEmailAccessOverride, Auth: "admin", Tag: "Admin",
+		Summary: "Allow or deny an email address", Request: EmailAccessRequest{}, Response: EmailAccessOverride{}},
+	{Method: http.MethodDelete, Path: "/admin/email-access/:overrideID", Handler: DeleteEmailAccessOverride, Auth: "admin", Tag: "Admin",
+		Summary: "Remove an email override", Response: MessageResponse{}},
+	{Method: http.MethodGet, Path: "/admin/users/flagged", Handler: GetFlaggedUsers, Auth: "admin", Tag: "Admin",
+		Summary: "Accounts that break the email policy", Response: []FlaggedUserResponse{}},
+}
+
+// registerRoutes mounts the API under /v1 and again at the root for older
+// clients. The root copies answer identically but carry Deprecation headers.
+func registerRoutes(r *gin.Engine) {
+	r.GET("/openapi.json", ServeOpenAPI)
+	r.GET("/metrics", ServeMetrics())
+	r.GET("/healthz", Healthz)
+	r.GET("/readyz", Readyz)
+
+	mountAPI(r.Group("/v1"))
+	mountAPI(r.Group("/", DeprecatedAliasMiddleware()))
+}
+
+// mountAPI registers every route of apiRoutes on the given group
+func mountAPI(api *gin.RouterGroup) {
+	userMiddleware := []gin.HandlerFunc{FirebaseAuthMiddleware(), RateLimitMiddleware("protected")}
+
+	for _, route := range apiRoutes {
+		var handlers []gin.HandlerFunc
+		switch route.Auth {
+		case "optional":
+			handlers = append(handlers, OptionalFirebaseAuthMiddleware())
+		case "user":
+			handlers = append(handlers, userMiddleware...)
+		case "admin":
+			handlers = append(handlers, userMiddleware...)
+			handlers = append(handlers, AdminMiddleware())
+		}
+		handlers = append(handlers, route.Middleware...)
+		// After the route's own limits and access checks, so their refusals aren't replayed
+		if route.Auth == "user" || route.Auth == "admin" {
+			handlers = append(handlers, IdempotencyMiddleware())
+		}
+		if openAPIValidation {
+			handlers = append(handlers, OpenAPIValidationMiddleware(route))
+		}
+		handlers = append(handlers, route.Handler)
+
+		api.Handle(route.Method, route.Path, handlers...)
+	}
+}
+
+// GET /public
+func PublicEndpoint(c *gin.Context) {
+	c.JSON(http.StatusOK, MessageResponse{Message: "This is a public endpoint"})
+}
+
+// GET /ping - Connectivity check that also cleans up expired rides
+func Ping(c *gin.Context) {
+	// Clean up expired rides
+	cleanupExpiredRides()
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "pong"})
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// seedUIDPrefix marks users created by "seed" so they are easy to find and never collide with Firebase UIDs
+const seedUIDPrefix = "seed-"
+
+var (
+	seedFirstNames = map[string][]string{
+		"male":   {"Aarav", "Vivaan", "Aditya", "Arjun", "Rohan", "Kabir", "Ishaan", "Rahul", "Vikram", "Karan"},
+		"female": {"Saanvi", "Ananya", "Diya", "Priya", "Meera", "Kavya", "Riya", "Neha", "Aisha", "Sneha"},
+	}
+	seedLastNames = []string{"Sharma", "Verma", "Gupta", "Singh", "Patel", "Reddy", "Iyer", "Nair", "Mehta", "Joshi", "Kapoor", "Chopra", "Das", "Rao", "Malhotra"}
+	seedPlaces    = []string{"IGI Airport T3", "New Delhi Railway Station", "Connaught Place", "Hauz Khas", "Noida Sector 62", "Cyber City Gurugram", "Anand Vihar ISBT", "Saket", "Dwarka Sector 21", "Lajpat Nagar", "Kashmere Gate ISBT", "Greater Noida"}
+)
+
+// seedOptions controls how much data "seed" creates
+type seedOptions struct {
+	Users int
+	Rides int
+	Seed  int64
+}
+
+// seedSummary counts the rows "seed" created
+type seedSummary struct {
+	Users        int
+	Rides        int
+	Requests     int
+	Participants int
+}
+
+// seedDatabase fills the database with fake but plausible users, upcoming
+// rides, join requests in every state and participants, in one transaction
+func seedDatabase(ctx context.Context, opts seedOptions) (seedSummary, error) {
+	var summary seedSummary
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	tx := DB.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return summary, tx.Error
+	}
+	defer tx.Rollback()
+
+	// Number new users after any created by an earlier run so emails and UIDs stay unique
+	var existing int64
+	if err := tx.Model(&User{}).Where("firebase_uid LIKE ?", seedUIDPrefix+"%").Count(&existing).Error; err != nil {
+		return summary, err
+	}
+
+	users := make([]User, 0, opts.Users)
+	for i := 0; i < opts.Users; i++ {
+		n := int(existing) + i + 1
+		gender := "male"
+		if rng.Intn(2) == 0 {
+			gender = "female"
+		}
+		first := seedFirstNames[gender][rng.Intn(len(seedFirstNames[gender]))]
+		last := seedLastNames[rng.Intn(len(seedLastNames))]
+		locale := "en"
+		if rng.Intn(5) == 0 {
+			locale = "hi"
+		}
+		users = append(users, User{
+			Name:        first + " " + last,
+			Email:       fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), n),
+			Phone:       fmt.Sprintf("+919%09d", rng.Intn(1_000_000_000)),
+			Gender:      gender,
+			Locale:      locale,
+			FirebaseUID: fmt.Sprintf("%s%04d", seedUIDPrefix, n),
+		})
+	}
+	if len(users) > 0 {
+		if err := tx.Create(&users).Error; err != nil {
+			return summary, fmt.Errorf("error creating users: %v", err)
+		}
+	}
+	summary.Users = len(users)
+	if len(users) < 2 {
+		return summary, tx.Commit().Error
+	}
+
+	// A user takes at most one seat per day, as the app enforces
+	busy := make(map[string]bool)
+	today := time.Now()
+
+	for i := 0; i < opts.Rides; i++ {
+		leader := users[rng.Intn(len(users))]
+		origin := seedPlaces[rng.Intn(len(seedPlaces))]
+		destination := seedPlaces[rng.Intn(len(seedPlaces))]
+		for destination == origin {
+			destination = seedPlaces[rng.Intn(len(seedPlaces))]
+		}
+		ride := Ride{
+			LeaderID:    leader.ID,
+			Origin:      origin,
+			Destination: destination,
+			Date:        today.AddDate(0, 0, rng.Intn(14)+1).Format("2006-01-02"),
+			Time:        fmt.Sprintf("%02d:%02d", 6+rng.Intn(16), 15*rng.Intn(4)),
+			Seats:       2 + rng.Intn(5),
+			Price:       float64(50 + 10*rng.Intn(75)),
+		}
+		if err := tx.Create(&ride).Error; err != nil {
+			return summary, fmt.Errorf("error creating ride: %v", err)
+		}
+		summary.Rides++
+
+		for _, j := range rng.Perm(len(users))[:rng.Intn(len(users)/2+1)] {
+			passenger := users[j]
+			if passenger.ID == leader.ID {
+				continue
+			}
+			dayKey := passenger.FirebaseUID + "/" + ride.Date
+			switch roll := rng.Intn(10); {
+			case roll < 4 && ride.SeatsFilled < ride.Seats && !busy[dayKey]:
+				// Joined with an approved request, which joining consumes
+				busy[dayKey] = true
+				ride.SeatsFilled++
+				if err := tx.Create(&Participant{RideID: ride.ID, UserID: passenger.FirebaseUID, JoinedAt: time.Now()}).Error; err != nil {
+					return summary, fmt.Errorf("error creating participant: %v", err)
+				}
+				summary.Participants++
+			case roll < 7:
+				if err := tx.Create(&Request{RideID: ride.ID, UserID: passenger.FirebaseUID, Status: "pending"}).Error; err != nil {
+					return summary, fmt.Errorf("error creating request: %v", err)
+				}
+				summary.Requests++
+			case roll < 9:
+				if err := tx.Create(&Request{RideID: ride.ID, UserID: passenger.FirebaseUID, Status: "approved"}).Error; err != nil {
+					return summary, fmt.Errorf("error creating request: %v", err)
+				}
+				summary.Requests++
+			default:
+				// Removed by the leader a few minutes ago, still cooling down
+				revokedAt := time.Now().Add(-time.Duration(rng.Intn(20)) * time.Minute)
+				if err := tx.Create(&Request{RideID: ride.ID, UserID: passenger.FirebaseUID, Status: "revoked", RevokedAt: revokedAt}).Error; err != nil {
+					return summary, fmt.Errorf("error creating request: %v", err)
+				}
+				summary.Requests++
+			}
+		}
+		if err := tx.Model(&ride).Update("seats_filled", ride.SeatsFilled).Error; err != nil {
+			return summary, fmt.Errorf("error updating ride: %v", err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return summary, err
+	}
+	slog.Info("database seeded", "users", summary.Users, "rides", summary.Rides, "requests", summary.Requests, "participants", summary.Participants)
+	return summary, nil
+}
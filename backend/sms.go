@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SMSSender delivers text messages to E.164 phone numbers
+type SMSSender interface {
+	Send(ctx context.Context, to, message string) error
+}
+
+// smsSender is the sender picked by SMS_PROVIDER
+var smsSender SMSSender
+
+// InitSMS sets up the SMS_PROVIDER sender
+func InitSMS() error {
+	switch config.SMSProvider {
+	case "twilio":
+		smsSender = &twilioSMSSender{
+			accountSID: config.TwilioAccountSID,
+			authToken:  config.TwilioAuthToken,
+			from:       config.TwilioFrom,
+			client:     &http.Client{Timeout: 10 * time.Second},
+		}
+	case "log":
+		smsSender = logSMSSender{}
+		slog.Warn("SMS_PROVIDER=log, verification codes are written to the log instead of being sent")
+	default:
+		return fmt.Errorf("unknown SMS provider %q", config.SMSProvider)
+	}
+	return nil
+}
+
+// logSMSSender writes messages to the log, for local development and tests
+type logSMSSender struct{}
+
+func (logSMSSender) Send(ctx context.Context, to, message string) error {
+	slog.InfoContext(ctx, "sms not sent (SMS_PROVIDER=log)", "to", to, "message", message)
+	return nil
+}
+
+// twilioSMSSender sends messages through the Twilio Messages API
+type twilioSMSSender struct {
+	accountSID string
+	authToken  string
+	from       string // Phone number, or a messaging service SID ("MG...")
+	client     *http.Client
+}
+
+func (s *twilioSMSSender) Send(ctx context.Context, to, message string) error {
+	form := url.Values{"To": {to}, "Body": {message}}
+	if strings.HasPrefix(s.from, "MG") {
+		form.Set("MessagingServiceSid", s.from)
+	} else {
+		form.Set("From", s.from)
+	}
+
+	endpoint := "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(s.accountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.accountSID, s.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling Twilio: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("twilio returned %s: %s", resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// setupTestDatabase loads a test configuration and points DB at a fresh
+// in-memory SQLite database with every migration applied. args are extra
+// command line flags, e.g. "--phone-otp-max-attempts", "3".
+func setupTestDatabase(t testing.TB, args ...string) {
+	t.Helper()
+	flags := append([]string{"--config", os.DevNull, "--db-driver", "sqlite", "--sqlite-path", ":memory:", "--log-level", "warn"}, args...)
+	if _, err := LoadConfig("test", flags); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitLogging(os.Stderr); err != nil {
+		t.Fatal(err)
+	}
+	InitDatabase()
+	if err := applyMigrationsOnStart(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const serviceName = "brocab"
+
+// shutdownTracing flushes pending spans, set by InitTracing
+var shutdownTracing = func(context.Context) error { return nil }
+
+// tracer creates the spans this package starts by hand (GORM, token verification)
+var tracer = otel.Tracer("github.com/Ansingh0305/BroCab")
+
+// InitTracing installs the exporter named by OTEL_TRACES_EXPORTER: "none" (the
+// default, spans are not recorded), "otlp" (OTLP over HTTP, configured with the
+// OTEL_EXPORTER_OTLP_ENDPOINT and the other standard OTEL_EXPORTER_OTLP_*
+// variables) or "stdout" for local debugging.
+// The returned function flushes pending spans and must be called on shutdown.
+func InitTracing() (func(context.Context) error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch name := config.TracesExporter; name {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		var options []otlptracehttp.Option
+		if config.OTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(config.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, expected none, otlp or stdout", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s trace exporter: %v", config.TracesExporter, err)
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override these defaults
+	resource, err := sdkresource.Merge(
+		sdkresource.Default(),
+		sdkresource.NewSchemaless(attribute.String("service.name", serviceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error building trace resource: %v", err)
+	}
+	resource, err = sdkresource.Merge(resource, sdkresource.Environment())
+	if err != nil {
+		return nil, fmt.Errorf("error building trace resource: %v", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// TracingMiddleware starts a server span per request, continuing any trace
+// passed in by the caller through the traceparent header
+func TracingMiddleware() gin.HandlerFunc {
+	return otelgin.Middleware(serviceName)
+}
+
+// gormTracing is a GORM plugin that wraps every query in a span. Only the
+// parameterized SQL is recorded, so bound values never reach the trace.
+type gormTracing struct{}
+
+type gormSpan struct {
+	span   trace.Span
+	parent context.Context
+}
+
+const gormSpanKey = "otel:span"
+
+func (gormTracing) Name() string {
+	return "otel_tracing"
+}
+
+func (p gormTracing) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	register := []struct {
+		name   string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register},
+		{"query", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register},
+		{"update", callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register},
+		{"delete", callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register},
+		{"row", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	}
+
+	for _, r := range register {
+		if err := r.before("otel:before_"+r.name, p.before(r.name)); err != nil {
+			return err
+		}
+		if err := r.after("otel:after_"+r.name, p.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gormTracing) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		parent := db.Statement.Context
+		ctx, span := tracer.Start(parent, "gorm."+operation, trace.WithSpanKind(trace.SpanKindClient))
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, gormSpan{span: span, parent: parent})
+	}
+}
+
+func (gormTracing) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	s := value.(gormSpan)
+	defer s.span.End()
+	db.Statement.Context = s.parent
+
+	s.span.SetAttributes(
+		attribute.String("db.system", db.Dialector.Name()),
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		s.span.RecordError(db.Error)
+		s.span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -10,14 +11,30 @@ import (
 )
 
 type User struct {
-	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
-	Email       string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"email"`
-	Phone       string    `gorm:"type:varchar(15);not null" json:"phone"`
-	Gender      string    `gorm:"type:varchar(10)" json:"gender,omitempty"`
-	FirebaseUID string    `gorm:"type:varchar(100);uniqueIndex;not null" json:"-"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name"`
+	Email       string `gorm:"type:varchar(100);uniqueIndex;not null" json:"email"`
+	Phone       string `gorm:"type:varchar(20);not null" json:"phone"` // E.164 when set through the API, e.g. "+919876543210"
+	Gender      string `gorm:"type:varchar(10)" json:"gender,omitempty"`
+	Locale      string `gorm:"type:varchar(10);default:'en'" json:"locale"` // Language for messages and notifications, e.g. "en", "hi"
+	FirebaseUID string `gorm:"type:varchar(100);uniqueIndex;not null" json:"-"`
+	IsAdmin     bool   `gorm:"default:false" json:"-"` // Grants access to /admin routes, set directly in the database
+
+	// Why the account breaks the email policy ("domain_not_allowed", "email_not_verified" or "denied"), see email_policy.go
+	EmailFlag      string     `gorm:"type:varchar(30);not null;default:''" json:"-"`
+	EmailFlaggedAt *time.Time `json:"-"`
+
+	// Who else can see each field, see privacy.go
+	NameVisibility   string `gorm:"type:varchar(20);default:'everyone'" json:"name_visibility"`
+	GenderVisibility string `gorm:"type:varchar(20);default:'everyone'" json:"gender_visibility"`
+	PhoneVisibility  string `gorm:"type:varchar(20);default:'co_riders'" json:"phone_visibility"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PhoneVerifiedAt     *time.Time `json:"phone_verified_at,omitempty"`     // Set by POST /user/phone/verify/confirm, cleared when the phone changes
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"` // Set by DELETE /user until the grace period ends or the user restores the account
+	DeletedAt           *time.Time `json:"-"`                               // When the account was anonymized; not a GORM soft delete
 }
 
 func getUser(uid interface{}) (*User, error) { //
@@ -37,32 +54,51 @@ func getUser(uid interface{}) (*User, error) { //
 	return &user, nil
 }
 
+// currentUser returns the signed-in user, loading it once per request and
+// keeping it in the context under "user". FirebaseAuthMiddleware loads it
+// up front, so handlers don't query for it again.
+func currentUser(c *gin.Context) (*User, error) {
+	if cached, exists := c.Get("user"); exists {
+		return cached.(*User), nil
+	}
+	var user User
+	if err := dbFor(c).Where("firebase_uid = ?", c.GetString("uid")).First(&user).Error; err != nil {
+		return nil, err
+	}
+	c.Set("user", &user)
+	return &user, nil
+}
+
 // Request body struct for creating user
 type CreateUserRequest struct {
 	Name   string `json:"name" binding:"required"`
-	Email  string `json:"email" binding:"required,email"`
+	Email  string `json:"email"` // Ignored, the email comes from the verified ID token
 	Phone  string `json:"phone"` // No longer required
 	Gender string `json:"gender"`
+	Locale string `json:"locale"` // Defaults to the best match for Accept-Language
 }
 
 // Request body struct for updating user
 type UpdateUserRequest struct {
-	Name   string `json:"name"`
-	Phone  string `json:"phone"`
-	Gender string `json:"gender"`
+	Name             string `json:"name"`
+	Phone            string `json:"phone"`
+	Gender           string `json:"gender"`
+	Locale           string `json:"locale"`
+	NameVisibility   string `json:"name_visibility"`   // "everyone", "co_riders" or "nobody"
+	GenderVisibility string `json:"gender_visibility"` // "everyone", "co_riders" or "nobody"
+	PhoneVisibility  string `json:"phone_visibility"`  // "co_riders" or "nobody"
 }
 
 // GET /user - Get current user's profile
 func GetCurrentUser(c *gin.Context) {
-	firebaseUID, exists := c.Get("uid")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	if _, exists := c.Get("uid"); !exists {
+		respondError(c, http.StatusUnauthorized, "error.unauthorized")
 		return
 	}
 
-	user, err := getUser(firebaseUID.(string))
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
@@ -73,13 +109,13 @@ func GetCurrentUser(c *gin.Context) {
 func CreateUser(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		respondError(c, http.StatusBadRequest, "error.invalid_request_data")
 		return
 	}
 
 	firebaseUID, exists := c.Get("uid")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, http.StatusUnauthorized, "error.unauthorized")
 		return
 	}
 
@@ -92,25 +128,76 @@ func CreateUser(c *gin.Context) {
 		return
 	}
 	if result.Error != nil && result.Error != gorm.ErrRecordNotFound {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		respondError(c, http.StatusInternalServerError, "error.database")
+		return
+	}
+
+	locale := req.Locale
+	if locale == "" {
+		locale = matchAcceptLanguage(c.GetHeader("Accept-Language"))
+	} else if !isSupportedLocale(locale) {
+		respondError(c, http.StatusBadRequest, "error.unsupported_locale", MessageArgs{"locale": locale})
+		return
+	}
+
+	// Only trust the email Firebase vouches for, and only if it passes the sign-up policy
+	email := c.GetString("email")
+	if email == "" {
+		respondError(c, http.StatusForbidden, "error.email_missing")
+		return
+	}
+	violation, err := emailPolicyViolation(db, email, c.GetBool("email_verified"))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.database")
+		return
+	}
+	switch violation {
+	case emailFlagUnverified:
+		signupsRejected.WithLabelValues(violation).Inc()
+		respondError(c, http.StatusForbidden, "error.email_not_verified")
+		return
+	case emailFlagDomain, emailFlagDenied:
+		signupsRejected.WithLabelValues(violation).Inc()
+		respondError(c, http.StatusForbidden, "error.email_not_allowed")
 		return
 	}
 
+	phone := req.Phone
+	if phone != "" {
+		var ok bool
+		if phone, ok = normalizePhone(phone); !ok {
+			respondError(c, http.StatusBadRequest, "error.invalid_phone")
+			return
+		}
+	}
+
 	newUser := User{
 		Name:        req.Name,
-		Email:       req.Email,
-		Phone:       req.Phone,
+		Email:       email,
+		Phone:       phone,
 		Gender:      req.Gender,
+		Locale:      locale,
 		FirebaseUID: firebaseUID.(string),
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
-	if err := db.Create(&newUser).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newUser).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "user_created", TargetType: "user", TargetID: newUser.ID, After: newUser})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.create_user_failed")
 		return
 	}
 
+	// Organizations claiming the email's domain take the user in right away
+	if err := joinOrganizationsByEmail(db, newUser); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to add user to organizations", "user_id", newUser.ID, "error", err)
+	}
+
 	c.JSON(http.StatusCreated, newUser)
 }
 
@@ -118,20 +205,20 @@ func CreateUser(c *gin.Context) {
 func UpdateCurrentUser(c *gin.Context) {
 	firebaseUID, exists := c.Get("uid")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		respondError(c, http.StatusUnauthorized, "error.unauthorized")
 		return
 	}
 
 	var req UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data"})
+		respondError(c, http.StatusBadRequest, "error.invalid_request_data")
 		return
 	}
 
 	// Get current user
-	user, err := getUser(firebaseUID.(string))
+	user, err := currentUser(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
@@ -141,74 +228,127 @@ func UpdateCurrentUser(c *gin.Context) {
 		updates["name"] = req.Name
 	}
 	if req.Phone != "" {
-		updates["phone"] = req.Phone
+		phone, ok := normalizePhone(req.Phone)
+		if !ok {
+			respondError(c, http.StatusBadRequest, "error.invalid_phone")
+			return
+		}
+		// A new number has to be verified again
+		if phone != user.Phone {
+			updates["phone"] = phone
+			updates["phone_verified_at"] = nil
+		}
 	}
 	if req.Gender != "" {
 		updates["gender"] = req.Gender
 	}
+	if req.Locale != "" {
+		if !isSupportedLocale(req.Locale) {
+			respondError(c, http.StatusBadRequest, "error.unsupported_locale", MessageArgs{"locale": req.Locale})
+			return
+		}
+		updates["locale"] = req.Locale
+		c.Set("locale", req.Locale)
+	}
+	for field, value := range map[string]string{
+		"name_visibility":   req.NameVisibility,
+		"gender_visibility": req.GenderVisibility,
+		"phone_visibility":  req.PhoneVisibility,
+	} {
+		if value == "" {
+			continue
+		}
+		if !validVisibility(field, value) {
+			respondError(c, http.StatusBadRequest, "error.invalid_visibility", MessageArgs{"field": field, "value": value})
+			return
+		}
+		updates[field] = value
+	}
 	updates["updated_at"] = time.Now()
 
-	// Perform update
-	if err := DB.Model(user).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+	// Perform the update and record it in one transaction. Updates changes user
+	// in place, so keep a copy of the old values for the audit log.
+	before := *user
+	var updatedUser User
+	err = dbFor(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("firebase_uid = ?", firebaseUID).First(&updatedUser).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, auditEntry{Action: "user_updated", TargetType: "user", TargetID: user.ID, Before: before, After: updatedUser})
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "error.update_user_failed")
 		return
 	}
 
 	// Return updated user
-	updatedUser, err := getUser(firebaseUID.(string))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated user"})
-		return
-	}
+	c.Set("user", &updatedUser)
 
 	c.JSON(http.StatusOK, updatedUser)
 }
 
-// GET /user/:userID
+// GET /user/:userID - Name and gender, as far as the user shares them with the caller
 func GetUserBasic(c *gin.Context) {
 	userID := c.Param("userID")
 
 	user, err := getUser(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		respondError(c, http.StatusNotFound, "error.user_not_found")
 		return
 	}
 
-	response := struct {
-		Name   string `json:"name"`
-		Gender string `json:"gender"`
-	}{
-		Name:   user.Name,
-		Gender: user.Gender,
+	coRider := false
+	if viewer, err := currentUser(c); err == nil {
+		coRider = sharesRide(dbFor(c), *viewer, *user)
+	}
+	fields := visibleProfile(*user, coRider, false)
+
+	response := UserBasicResponse{
+		Name:   fields.Name,
+		Gender: fields.Gender,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
-// GET /ride/:rideID/leader
+// GET /ride/:rideID/leader - The phone number is only included for the ride's
+// participants, within CONTACT_WINDOW of departure
 func GetRideLeader(c *gin.Context) {
 	rideID := c.Param("rideID")
+	uid := c.MustGet("uid").(string)
 
 	var ride Ride
-	if err := DB.First(&ride, "id = ?", rideID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ride not found"})
+	if err := dbFor(c).First(&ride, "id = ?", rideID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
+		return
+	}
+	// Organization rides are invisible to outsiders
+	if !canUseRide(dbFor(c), ride, uid) {
+		respondError(c, http.StatusNotFound, "error.ride_not_found")
 		return
 	}
 
 	var leader User
-	if err := DB.First(&leader, "id = ?", ride.LeaderID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Leader not found"})
+	if err := dbFor(c).First(&leader, "id = ?", ride.LeaderID).Error; err != nil {
+		respondError(c, http.StatusNotFound, "error.leader_not_found")
 		return
 	}
 
-	response := struct {
-		Name        string `json:"name"`
-		Gender      string `json:"gender"`
-		PhoneNumber string `json:"phone"`
-	}{
-		Name:        leader.Name,
-		Gender:      leader.Gender,
-		PhoneNumber: leader.Phone,
+	var fields profileFields
+	if leader.FirebaseUID == uid {
+		fields = profileFields{Name: leader.Name, Gender: leader.Gender, Phone: leader.Phone}
+	} else {
+		coRider := isParticipant(dbFor(c), ride.ID, uid)
+		fields = visibleProfile(leader, coRider, coRider && contactAllowed(ride, time.Now()))
+	}
+
+	response := RideLeaderResponse{
+		Name:        fields.Name,
+		Gender:      fields.Gender,
+		PhoneNumber: fields.Phone,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -222,3 +362,36 @@ func getUserByID(userID uint) (*User, error) {
 	}
 	return &user, nil
 }
+
+// usersByUID loads the users with the given Firebase UIDs in one query, keyed
+// by UID. UIDs without a user are missing from the map.
+func usersByUID(db *gorm.DB, uids []string) (map[string]User, error) {
+	byUID := make(map[string]User, len(uids))
+	if len(uids) == 0 {
+		return byUID, nil
+	}
+	var users []User
+	if err := db.Where("firebase_uid IN ?", uids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		byUID[user.FirebaseUID] = user
+	}
+	return byUID, nil
+}
+
+// usersByID loads the users with the given database IDs in one query, keyed by ID
+func usersByID(db *gorm.DB, ids []uint) (map[uint]User, error) {
+	byID := make(map[uint]User, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+	var users []User
+	if err := db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	return byID, nil
+}